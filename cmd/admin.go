@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	deadNotificationsLimit = 20
+	quarantineLimit        = 20
+)
+
+// Every handler below is registered behind middleware.AdminOnly
+
+// /stats command
+func (b *telegramBot) handleStats(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	users, err := b.subscriptionService.FindActiveUsers(ctx)
+	if err != nil {
+		b.sendAdminError(ctx, chatID, "Failed to find active users", err)
+		return
+	}
+	subs := 0
+	for _, user := range users {
+		subs += user.Subscriptions
+	}
+
+	slots, err := b.notifService.CountSlots(ctx)
+	if err != nil {
+		b.sendAdminError(ctx, chatID, "Failed to count slots", err)
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.StatsMsg(len(users), subs, slots, b.pollingService.Mode(), time.Since(b.startedAt)),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /mode normal|aggressive command
+func (b *telegramBot) handlePollingMode(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.Text)[1:]
+
+	if len(args) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      presentation.PollingModeSetMsg(b.pollingService.Mode()),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	mode := config.PollingMode(args[0])
+	if mode != config.ModeNormal && mode != config.ModeAggressive {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      presentation.ValidationErrorMsg("Режим должен быть normal или aggressive"),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	b.pollingService.SetMode(mode)
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.PollingModeSetMsg(mode),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /broadcast <text> command. Messages go through the notification outbox, failed ones end up in /dead
+func (b *telegramBot) handleBroadcast(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	_, text, _ := strings.Cut(update.Message.Text, " ")
+	text = strings.TrimSpace(text)
+
+	if text == "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      presentation.BroadcastUsageMsg(),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	users, err := b.subscriptionService.FindActiveUsers(ctx)
+	if err != nil {
+		b.sendAdminError(ctx, chatID, "Failed to find active users", err)
+		return
+	}
+	userIDs := make([]int, len(users))
+	for idx, user := range users {
+		userIDs[idx] = user.UserID
+	}
+
+	queued, err := b.notifService.Broadcast(ctx, userIDs, text)
+	if err != nil {
+		slog.Error("Failed to queue broadcast", "error", err, "queued", queued, "service", logger.TelegramBot)
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.BroadcastQueuedMsg(queued, len(userIDs)),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /users command
+func (b *telegramBot) handleUsers(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	users, err := b.subscriptionService.FindActiveUsers(ctx)
+	if err != nil {
+		b.sendAdminError(ctx, chatID, "Failed to find active users", err)
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.UsersMsg(users),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /dead command
+func (b *telegramBot) handleDeadNotifications(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	msgs, err := b.notifService.ListDeadNotifications(ctx, deadNotificationsLimit)
+	if err != nil {
+		b.sendAdminError(ctx, chatID, "Failed to list dead notifications", err)
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.DeadNotificationsMsg(msgs, b.location),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /replay [id...] command. Without ids every dead notification is replayed
+func (b *telegramBot) handleReplayNotifications(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	args := strings.Fields(update.Message.Text)[1:]
+	ids := make([]int64, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID:    chatID,
+				Text:      presentation.ValidationErrorMsg("ID уведомления должен быть числом"),
+				ParseMode: models.ParseModeHTML,
+			})
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	replayed, err := b.notifService.ReplayDeadNotifications(ctx, ids...)
+	if err != nil {
+		b.sendAdminError(ctx, chatID, "Failed to replay dead notifications", err)
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.ReplayNotificationsMsg(replayed),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /quarantine command
+func (b *telegramBot) handleQuarantine(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	entries, err := b.quarantineService.List(ctx, quarantineLimit)
+	if err != nil {
+		b.sendAdminError(ctx, chatID, "Failed to list quarantined entries", err)
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.QuarantineMsg(entries, b.location),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /parsetest <master name> [| <service name>] command, checks a sample against the parsing rules
+func (b *telegramBot) handleParseTest(ctx context.Context, api *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	_, sample, _ := strings.Cut(update.Message.Text, " ")
+	username, serviceName, _ := strings.Cut(sample, "|")
+	username, serviceName = strings.TrimSpace(username), strings.TrimSpace(serviceName)
+
+	if username == "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      presentation.ParseTestUsageMsg(),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	slot, err := b.parsingRules.Parse(username, serviceName)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.ParseTestMsg(slot, b.parsingRules.Domains(), err),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+func (b *telegramBot) sendAdminError(ctx context.Context, chatID int64, msg string, err error) {
+	slog.Error(msg,
+		"error", err,
+		"service", logger.TelegramBot)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      presentation.GenericServiceErrorMsg(),
+		ParseMode: models.ParseModeHTML,
+	})
+}
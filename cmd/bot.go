@@ -4,11 +4,18 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/Ademun/mining-lab-bot/cmd/fsm"
 	"github.com/Ademun/mining-lab-bot/cmd/internal/middleware"
 	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
+	"github.com/Ademun/mining-lab-bot/internal/alerting"
+	"github.com/Ademun/mining-lab-bot/internal/catalog"
+	"github.com/Ademun/mining-lab-bot/internal/history"
 	"github.com/Ademun/mining-lab-bot/internal/notification"
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/quarantine"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
 	"github.com/Ademun/mining-lab-bot/internal/subscription"
 	"github.com/Ademun/mining-lab-bot/pkg/config"
 	"github.com/Ademun/mining-lab-bot/pkg/logger"
@@ -20,8 +27,18 @@ import (
 type Bot interface {
 	Start(ctx context.Context)
 	SetNotificationService(svc notification.Service)
+	SetPollingService(svc polling.Service)
+	SetQuarantineService(svc quarantine.Service)
+	SetHistoryService(svc history.Service)
+	SetCatalogService(svc catalog.Service)
 	SendMessage(ctx context.Context, params *bot.SendMessageParams)
-	SendNotification(ctx context.Context, notif notification.Notification)
+	// Ping checks the bot token and the Telegram API with a getMe call
+	Ping(ctx context.Context) error
+	SendNotification(ctx context.Context, notif notification.Notification) error
+	SendDigest(ctx context.Context, digest notification.Digest) error
+	SendBroadcast(ctx context.Context, broadcast notification.Broadcast) error
+	SendAlert(ctx context.Context, alert alerting.Alert) error
+	SendCatalogDiff(ctx context.Context, diff polling.CatalogDiff) error
 	AnswerCallbackQuery(ctx context.Context, params *bot.AnswerCallbackQueryParams)
 	EditMessageReplyMarkup(ctx context.Context, params *bot.EditMessageReplyMarkupParams)
 	EditMessageText(ctx context.Context, params *bot.EditMessageTextParams)
@@ -31,12 +48,21 @@ type Bot interface {
 type telegramBot struct {
 	subscriptionService subscription.Service
 	notifService        notification.Service
+	pollingService      polling.Service
+	quarantineService   quarantine.Service
+	historyService      history.Service
+	catalogService      catalog.Service
+	schedule            schedule.Schedule
+	parsingRules        polling.Rules
+	location            *time.Location
 	api                 *bot.Bot
 	router              *fsm.Router
+	companies           []config.CompanyConfig
 	options             *config.TelegramConfig
+	startedAt           time.Time
 }
 
-func NewBot(subService subscription.Service, opts *config.TelegramConfig, redis *redis.Client) (Bot, error) {
+func NewBot(subService subscription.Service, sched schedule.Schedule, rules polling.Rules, loc *time.Location, companies []config.CompanyConfig, opts *config.TelegramConfig, redis *redis.Client) (Bot, error) {
 	router := fsm.NewRouter(fsm.NewFSM(redis))
 	botOpts := []bot.Option{
 		bot.WithMiddlewares(middleware.CommandLoggingMiddleware, router.Middleware),
@@ -50,13 +76,18 @@ func NewBot(subService subscription.Service, opts *config.TelegramConfig, redis
 
 	return &telegramBot{
 		subscriptionService: subService,
+		schedule:            sched,
+		parsingRules:        rules,
+		location:            loc,
 		api:                 b,
 		router:              router,
+		companies:           companies,
 		options:             opts,
 	}, nil
 }
 
 func (b *telegramBot) Start(ctx context.Context) {
+	b.startedAt = time.Now()
 	b.api.RegisterHandler(bot.HandlerTypeMessageText, "start",
 		bot.MatchTypeCommandStartOnly, b.handleStart)
 	b.api.RegisterHandler(bot.HandlerTypeMessageText, "help",
@@ -71,7 +102,32 @@ func (b *telegramBot) Start(ctx context.Context) {
 		bot.MatchTypeCommandStartOnly, b.handleFeedbackMsg)
 	b.api.RegisterHandler(bot.HandlerTypeMessageText, "teacher",
 		bot.MatchTypeCommandStartOnly, b.handleTeacherReport)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "quiet",
+		bot.MatchTypeCommandStartOnly, b.handleQuietHours)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "digest",
+		bot.MatchTypeCommandStartOnly, b.handleDeliveryMode)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "forecast",
+		bot.MatchTypeCommandStartOnly, b.handleForecast)
 
+	adminOnly := middleware.AdminOnly(b.options.AdminID)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "stats",
+		bot.MatchTypeCommandStartOnly, b.handleStats, adminOnly)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "mode",
+		bot.MatchTypeCommandStartOnly, b.handlePollingMode, adminOnly)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "broadcast",
+		bot.MatchTypeCommandStartOnly, b.handleBroadcast, adminOnly)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "users",
+		bot.MatchTypeCommandStartOnly, b.handleUsers, adminOnly)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "dead",
+		bot.MatchTypeCommandStartOnly, b.handleDeadNotifications, adminOnly)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "replay",
+		bot.MatchTypeCommandStartOnly, b.handleReplayNotifications, adminOnly)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "quarantine",
+		bot.MatchTypeCommandStartOnly, b.handleQuarantine, adminOnly)
+	b.api.RegisterHandler(bot.HandlerTypeMessageText, "parsetest",
+		bot.MatchTypeCommandStartOnly, b.handleParseTest, adminOnly)
+
+	b.router.RegisterHandler(fsm.StepAwaitingLabCompany, b.handleLabCompany)
 	b.router.RegisterHandler(fsm.StepAwaitingLabType, b.handleLabType)
 	b.router.RegisterHandler(fsm.StepAwaitingLabNumber, b.handleLabNumber)
 	b.router.RegisterHandler(fsm.StepAwaitingLabAuditorium, b.handleLabAuditorium)
@@ -97,6 +153,48 @@ func (b *telegramBot) SetNotificationService(svc notification.Service) {
 	b.notifService = svc
 }
 
+func (b *telegramBot) SetPollingService(svc polling.Service) {
+	b.pollingService = svc
+}
+
+func (b *telegramBot) SetQuarantineService(svc quarantine.Service) {
+	b.quarantineService = svc
+}
+
+func (b *telegramBot) SetHistoryService(svc history.Service) {
+	b.historyService = svc
+}
+
+func (b *telegramBot) SetCatalogService(svc catalog.Service) {
+	b.catalogService = svc
+}
+
+// companyName returns a display name of the company, or an empty string when there is nothing to tell apart
+func (b *telegramBot) companyName(companyID *int) string {
+	if companyID == nil || len(b.companies) < 2 {
+		return ""
+	}
+	for _, company := range b.companies {
+		if company.ID == *companyID {
+			return company.Name
+		}
+	}
+	return ""
+}
+
+func (b *telegramBot) Ping(ctx context.Context) error {
+	_, err := b.api.GetMe(ctx)
+	return err
+}
+
+// weekdayLessons returns the regular lessons of the weekday, nil when any weekday goes
+func (b *telegramBot) weekdayLessons(weekday *int) []schedule.Lesson {
+	if weekday == nil {
+		return nil
+	}
+	return b.schedule.WeekdayLessons(time.Weekday(*weekday))
+}
+
 func (b *telegramBot) SendMessage(ctx context.Context, params *bot.SendMessageParams) {
 	if _, err := b.api.SendChatAction(ctx, &bot.SendChatActionParams{
 		ChatID: params.ChatID,
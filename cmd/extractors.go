@@ -11,6 +11,17 @@ import (
 	"github.com/google/uuid"
 )
 
+func extractCompany(update *models.Update) *int {
+	companyStr := update.CallbackQuery.Data
+	companyStr = strings.TrimPrefix(companyStr, "company:")
+
+	if companyStr == "skip" {
+		return nil
+	}
+	companyID, _ := strconv.Atoi(companyStr)
+	return &companyID
+}
+
 func extractLabType(update *models.Update) polling.LabType {
 	labTypeStr := update.CallbackQuery.Data
 	labTypeStr = strings.TrimPrefix(labTypeStr, "type:")
@@ -25,18 +36,27 @@ func extractLabType(update *models.Update) polling.LabType {
 	return labType
 }
 
-func extractLabDomain(update *models.Update) *polling.LabDomain {
-	labDomainStr := update.CallbackQuery.Data
-	labDomainStr = strings.TrimPrefix(labDomainStr, "domain:")
-
-	var labDomain polling.LabDomain
-	switch labDomainStr {
-	case "mechanics":
-		labDomain = polling.LabDomainMechanics
-	case "virtual":
-		labDomain = polling.LabDomainVirtual
-	case "electricity":
-		labDomain = polling.LabDomainElectricity
+// extractLabNumber returns the number picked on the catalog keyboard or typed in
+func extractLabNumber(update *models.Update) string {
+	if update.CallbackQuery != nil {
+		return strings.TrimPrefix(update.CallbackQuery.Data, "number:")
+	}
+	return update.Message.Text
+}
+
+// extractLabAuditorium returns the auditorium picked on the catalog keyboard or typed in
+func extractLabAuditorium(update *models.Update) string {
+	if update.CallbackQuery != nil {
+		return strings.TrimPrefix(update.CallbackQuery.Data, "auditorium:")
+	}
+	return update.Message.Text
+}
+
+// extractLabDomain returns nil for domains that aren't configured, e.g. ones pressed on an outdated keyboard
+func extractLabDomain(update *models.Update, domains polling.Domains) *polling.LabDomain {
+	labDomain := polling.LabDomain(strings.TrimPrefix(update.CallbackQuery.Data, "domain:"))
+	if _, ok := domains.Find(labDomain); !ok {
+		return nil
 	}
 	return &labDomain
 }
@@ -78,6 +98,23 @@ func extractListingData(update *models.Update) (*int, *uuid.UUID) {
 	return nil, nil
 }
 
+// extractEditedSub returns the sub uuid if the selected listing action was "edit"
+func extractEditedSub(update *models.Update) *uuid.UUID {
+	subUUIDStr, ok := strings.CutPrefix(update.CallbackQuery.Data, "edit:")
+	if !ok {
+		return nil
+	}
+	subUUID, err := uuid.Parse(subUUIDStr)
+	if err != nil {
+		slog.Error("Failed to parse sub uuid",
+			"uuid", subUUIDStr,
+			"error", err,
+			"service", logger.TelegramBot)
+		return nil
+	}
+	return &subUUID
+}
+
 func extractLesson(update *models.Update) *int {
 	labLessonStr := update.CallbackQuery.Data
 	labLessonStr = strings.TrimPrefix(labLessonStr, "lesson:")
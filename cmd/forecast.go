@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Ademun/mining-lab-bot/cmd/fsm"
+	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// /forecast command, the lab is picked with the same steps as in /sub
+func (b *telegramBot) handleForecast(ctx context.Context, api *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+	userID := update.Message.From.ID
+
+	b.startLabWizard(ctx, userID, &fsm.SubscriptionCreationFlowData{
+		UserID:   int(userID),
+		Forecast: true,
+	})
+}
+
+func (b *telegramBot) sendForecast(ctx context.Context, userID int64, data *fsm.SubscriptionCreationFlowData) {
+	b.TryTransition(ctx, userID, fsm.StepIdle, &fsm.IdleData{})
+
+	lab := flowLabDemand(data)
+	if b.historyService == nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.GenericServiceErrorMsg(),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+	forecast, err := b.historyService.Forecast(ctx, lab)
+	if err != nil {
+		slog.Error("Failed to forecast lab", "error", err, "lab", lab, "service", logger.TelegramBot)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.GenericServiceErrorMsg(),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    userID,
+		Text:      presentation.ForecastMsg(lab, b.parsingRules.Domains(), b.companyName(data.CompanyID), forecast),
+		ParseMode: models.ParseModeHTML,
+	})
+}
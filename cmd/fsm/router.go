@@ -75,7 +75,7 @@ func (r *Router) Middleware(next bot.HandlerFunc) bot.HandlerFunc {
 
 func (r *Router) Transition(ctx context.Context, userID int64, nextStep ConversationStep, data StateData) error {
 	if err := r.fsm.SetStep(ctx, userID, nextStep); err != nil {
-		slog.Error("Failed to update conversation step", "error", err, logger.TelegramBot)
+		slog.Error("Failed to update conversation step", "error", err, "service", logger.TelegramBot)
 		if err := r.fsm.ResetState(ctx, userID); err != nil {
 			slog.Error("Fatal redis error when clearing conversation state", "error", err, "service", logger.TelegramBot)
 		}
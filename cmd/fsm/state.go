@@ -3,12 +3,14 @@ package fsm
 import (
 	"github.com/Ademun/mining-lab-bot/internal/polling"
 	"github.com/Ademun/mining-lab-bot/internal/subscription"
+	"github.com/google/uuid"
 )
 
 type ConversationStep string
 
 const (
 	StepIdle                            ConversationStep = "idle"
+	StepAwaitingLabCompany              ConversationStep = "awaiting_lab_company"
 	StepAwaitingLabType                 ConversationStep = "awaiting_lab_type"
 	StepAwaitingLabNumber               ConversationStep = "awaiting_lab_number"
 	StepAwaitingLabAuditorium           ConversationStep = "awaiting_lab_auditorium"
@@ -36,12 +38,17 @@ func (data *IdleData) StateData() {}
 
 type SubscriptionCreationFlowData struct {
 	UserID        int
+	CompanyID     *int
 	LabType       polling.LabType
 	LabNumber     int
 	LabAuditorium *int
 	LabDomain     *polling.LabDomain
 	Weekday       *int
 	Lessons       []int
+	// Forecast means the wizard only picks a lab for /forecast
+	Forecast bool
+	// EditUUID is set when the wizard changes an existing subscription, it is saved in place
+	EditUUID *uuid.UUID
 }
 
 func (d *SubscriptionCreationFlowData) StateData() {}
@@ -67,7 +74,8 @@ func dataTypeForStep(step ConversationStep) StateData {
 	switch step {
 	case StepIdle, StepAwaitingFeedbackMsg, StepAwaitingFeedbackReaction:
 		return &IdleData{}
-	case StepAwaitingLabType,
+	case StepAwaitingLabCompany,
+		StepAwaitingLabType,
 		StepAwaitingLabNumber,
 		StepAwaitingLabAuditorium,
 		StepAwaitingLabDomain,
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
@@ -22,3 +23,26 @@ func CommandLoggingMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
 		next(ctx, b, update)
 	}
 }
+
+// AdminOnly lets only the admin through, everyone else gets a refusal
+func AdminOnly(adminID int) bot.Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+			if update.Message == nil || update.Message.From == nil {
+				return
+			}
+			if update.Message.From.ID != int64(adminID) {
+				slog.Warn("Rejected admin command",
+					"text", update.Message.Text,
+					"user_id", update.Message.From.ID)
+				b.SendMessage(ctx, &bot.SendMessageParams{
+					ChatID:    update.Message.Chat.ID,
+					Text:      presentation.AdminOnlyMsg(),
+					ParseMode: models.ParseModeHTML,
+				})
+				return
+			}
+			next(ctx, b, update)
+		}
+	}
+}
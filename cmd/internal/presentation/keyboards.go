@@ -2,8 +2,14 @@ package presentation
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/Ademun/mining-lab-bot/cmd/internal/utils"
+	"github.com/Ademun/mining-lab-bot/internal/catalog"
+	"github.com/Ademun/mining-lab-bot/internal/notification"
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
 	"github.com/go-telegram/bot/models"
 	"github.com/google/uuid"
 )
@@ -44,13 +50,13 @@ func SelectWeekdayKbd(withSkip bool) *models.InlineKeyboardMarkup {
 	return keyboard
 }
 
-func SelectLessonKbd(lessons []utils.Lesson, multi bool) *models.InlineKeyboardMarkup {
+func SelectLessonKbd(lessons []schedule.Lesson, multi bool) *models.InlineKeyboardMarkup {
 	keyboard := &models.InlineKeyboardMarkup{
 		InlineKeyboard: make([][]models.InlineKeyboardButton, len(lessons)),
 	}
 	for idx, lesson := range lessons {
 		keyboard.InlineKeyboard[idx] = []models.InlineKeyboardButton{
-			{Text: lesson.Text, CallbackData: lesson.CallbackData},
+			{Text: utils.FormatLessonLong(lesson), CallbackData: fmt.Sprintf("lesson:%d", lesson.Number)},
 		}
 	}
 
@@ -69,6 +75,26 @@ func SelectLessonKbd(lessons []utils.Lesson, multi bool) *models.InlineKeyboardM
 
 // Subscription creation keyboards
 
+func SelectCompanyKbd(companies []config.CompanyConfig) *models.InlineKeyboardMarkup {
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: make([][]models.InlineKeyboardButton, 0, len(companies)+2),
+	}
+	for _, company := range companies {
+		text := company.Name
+		if text == "" {
+			text = fmt.Sprintf("Компания %d", company.ID)
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: text, CallbackData: fmt.Sprintf("company:%d", company.ID)},
+		})
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, [][]models.InlineKeyboardButton{
+		{{Text: "⏭️ Пропустить", CallbackData: "company:skip"}},
+		{{Text: "❌ Отменить создание", CallbackData: "cancel"}},
+	}...)
+	return keyboard
+}
+
 func SelectLabTypeKbd() *models.InlineKeyboardMarkup {
 	return &models.InlineKeyboardMarkup{
 		InlineKeyboard: [][]models.InlineKeyboardButton{
@@ -79,22 +105,111 @@ func SelectLabTypeKbd() *models.InlineKeyboardMarkup {
 	}
 }
 
-func SelectLabDomainKbd() *models.InlineKeyboardMarkup {
+// SelectLabNumberKbd lists the numbers known to the catalog, named labs get a row of their own
+func SelectLabNumberKbd(labs []catalog.Lab) *models.InlineKeyboardMarkup {
+	names := make(map[int]string)
+	numbers := make([]int, 0)
+	for _, lab := range labs {
+		name, ok := names[lab.Number]
+		if !ok {
+			numbers = append(numbers, lab.Number)
+		}
+		if name == "" {
+			names[lab.Number] = lab.Name
+		}
+	}
+
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: make([][]models.InlineKeyboardButton, 0, len(numbers)+1),
+	}
+	var row []models.InlineKeyboardButton
+	for _, number := range numbers {
+		button := models.InlineKeyboardButton{Text: strconv.Itoa(number), CallbackData: fmt.Sprintf("number:%d", number)}
+		if names[number] != "" {
+			button.Text = fmt.Sprintf("%d. %s", number, names[number])
+			keyboard.InlineKeyboard = appendKbdRow(keyboard.InlineKeyboard, row)
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{button})
+			row = nil
+			continue
+		}
+		row = append(row, button)
+		if len(row) == catalogKbdRowSize {
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+			row = nil
+		}
+	}
+	keyboard.InlineKeyboard = appendKbdRow(keyboard.InlineKeyboard, row)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+		{Text: "❌ Отменить", CallbackData: "cancel"},
+	})
+	return keyboard
+}
+
+// SelectLabAuditoriumKbd lists the auditoriums the catalog knows for the lab
+func SelectLabAuditoriumKbd(labs []catalog.Lab) *models.InlineKeyboardMarkup {
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: make([][]models.InlineKeyboardButton, 0, len(labs)/catalogKbdRowSize+2),
+	}
+	seen := make(map[int]bool)
+	var row []models.InlineKeyboardButton
+	for _, lab := range labs {
+		if seen[lab.Auditorium] {
+			continue
+		}
+		seen[lab.Auditorium] = true
+		row = append(row, models.InlineKeyboardButton{Text: strconv.Itoa(lab.Auditorium), CallbackData: fmt.Sprintf("auditorium:%d", lab.Auditorium)})
+		if len(row) == catalogKbdRowSize {
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+			row = nil
+		}
+	}
+	keyboard.InlineKeyboard = appendKbdRow(keyboard.InlineKeyboard, row)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+		{Text: "❌ Отменить", CallbackData: "cancel"},
+	})
+	return keyboard
+}
+
+const catalogKbdRowSize = 4
+
+func appendKbdRow(rows [][]models.InlineKeyboardButton, row []models.InlineKeyboardButton) [][]models.InlineKeyboardButton {
+	if len(row) == 0 {
+		return rows
+	}
+	return append(rows, row)
+}
+
+// SelectLabDomainKbd offers the configured domains in config order
+func SelectLabDomainKbd(domains polling.Domains) *models.InlineKeyboardMarkup {
+	rows := make([][]models.InlineKeyboardButton, 0, len(domains)+1)
+	for _, domain := range domains {
+		rows = append(rows, []models.InlineKeyboardButton{{Text: domain.Label, CallbackData: "domain:" + string(domain.Key)}})
+	}
+	rows = append(rows, []models.InlineKeyboardButton{{Text: "❌ Отменить создание", CallbackData: "cancel"}})
+	return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func AskSubCreationConfirmationKbd() *models.InlineKeyboardMarkup {
 	return &models.InlineKeyboardMarkup{
 		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{{Text: "Механика", CallbackData: "domain:mechanics"}},
-			{{Text: "Виртуалка", CallbackData: "domain:virtual"}},
-			{{Text: "Электричество", CallbackData: "domain:electricity"}},
-			{{Text: "❌ Отменить создание", CallbackData: "cancel"}},
+			{
+				{Text: "✅ Создать", CallbackData: "confirm:create"},
+				{Text: "❌ Отменить", CallbackData: "cancel"},
+			},
 		},
 	}
 }
 
-func AskSubCreationConfirmationKbd() *models.InlineKeyboardMarkup {
+// AskSubEditConfirmationKbd lets the user save the subscription or go back to the lab or time steps
+func AskSubEditConfirmationKbd() *models.InlineKeyboardMarkup {
 	return &models.InlineKeyboardMarkup{
 		InlineKeyboard: [][]models.InlineKeyboardButton{
 			{
-				{Text: "✅ Создать", CallbackData: "confirm:create"},
+				{Text: "📚 Изменить лабу", CallbackData: "edit:lab"},
+				{Text: "📅 Изменить время", CallbackData: "edit:time"},
+			},
+			{
+				{Text: "✅ Сохранить", CallbackData: "confirm:save"},
 				{Text: "❌ Отменить", CallbackData: "cancel"},
 			},
 		},
@@ -123,6 +238,9 @@ func ListSubsKbd(subUUID uuid.UUID, subIdx, totalSubs int) *models.InlineKeyboar
 	}
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, paginationRow)
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+		{
+			Text: "✏️ Изменить", CallbackData: fmt.Sprintf("edit:%s", subUUID.String()),
+		},
 		{
 			Text: "🗑️ Удалить", CallbackData: fmt.Sprintf("delete:%s", subUUID.String()),
 		},
@@ -140,6 +258,30 @@ func LinkKbd(url string) *models.InlineKeyboardMarkup {
 	}
 }
 
+const digestMaxLinks = 10
+
+// DigestKbd has a booking link for every distinct slot of the digest
+func DigestKbd(digest *notification.Digest) *models.InlineKeyboardMarkup {
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: make([][]models.InlineKeyboardButton, 0),
+	}
+	seen := make(map[string]bool)
+	for _, notif := range digest.Notifications {
+		slot := &notif.Slot
+		if notif.Kind == notification.KindTimesTaken || seen[slot.URL] {
+			continue
+		}
+		seen[slot.URL] = true
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("🔗 Лаба №%d, 🚪 %d", slot.Number, slot.Auditorium), URL: slot.URL},
+		})
+		if len(keyboard.InlineKeyboard) == digestMaxLinks {
+			break
+		}
+	}
+	return keyboard
+}
+
 // Teacher report keyboards
 
 func SelectWeekParityKbd() *models.InlineKeyboardMarkup {
@@ -2,13 +2,20 @@ package presentation
 
 import (
 	"fmt"
+	"html"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/Ademun/mining-lab-bot/cmd/internal/utils"
+	"github.com/Ademun/mining-lab-bot/internal/alerting"
+	"github.com/Ademun/mining-lab-bot/internal/history"
 	"github.com/Ademun/mining-lab-bot/internal/notification"
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/quarantine"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
 	"github.com/Ademun/mining-lab-bot/internal/subscription"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
 )
 
 func HelpCmdMsg() string {
@@ -24,9 +31,15 @@ func HelpCmdMsg() string {
 	sb.WriteString("<b>/unsub - удалить подписку</b>")
 	sb.WriteString(repeatLineBreaks(2))
 	sb.WriteString("<b>/list - посмотреть подписки</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("<b>/quiet - тихие часы</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("<b>/digest - сводка уведомлений</b>")
 	sb.WriteString(repeatLineBreaks(3))
 	sb.WriteString("<b>👨‍🏫 Информация:</b>")
 	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("<b>/forecast - когда обычно появляются записи на лабу</b>")
+	sb.WriteString(repeatLineBreaks(2))
 	sb.WriteString("<b>/teacher - сообщить о том, какой преподаватель был на вашей лабе</b>")
 	return sb.String()
 }
@@ -77,22 +90,38 @@ func ValidationErrorMsg(cause string) string {
 
 // Subscription creation flow
 
+func AskCompanyMsg() string {
+	var sb strings.Builder
+	sb.WriteString("<b>🏛️ Выберите кафедру</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("Или пропустите, если кафедра не важна")
+	return sb.String()
+}
+
 func AskLabTypeMsg() string {
 	return "<b>📝 Выберите тип лабораторной работы</b>"
 }
 
-func AskLabNumberMsg() string {
+func AskLabNumberMsg(picker bool) string {
 	var sb strings.Builder
 	sb.WriteString("<b>📚 Введите номер лабораторной работы</b>")
 	sb.WriteString(repeatLineBreaks(2))
+	if picker {
+		sb.WriteString("Или выберите из тех, что уже были на странице записи")
+		return sb.String()
+	}
 	sb.WriteString("Например: 7")
 	return sb.String()
 }
 
-func AskLabAuditoriumMsg() string {
+func AskLabAuditoriumMsg(picker bool) string {
 	var sb strings.Builder
 	sb.WriteString("<b>🚪 Введите номер аудитории</b>")
 	sb.WriteString(repeatLineBreaks(2))
+	if picker {
+		sb.WriteString("Или выберите из тех, где эта лаба уже была")
+		return sb.String()
+	}
 	sb.WriteString("Например: 233")
 	return sb.String()
 }
@@ -118,22 +147,40 @@ func AskLessonsMsg(lessons []int) string {
 		slices.Sort(lessons)
 		for _, lesson := range lessons {
 			sb.WriteString(repeatLineBreaks(1))
-			sb.WriteString(fmt.Sprintf("<b>%s</b>", utils.LessonNumberToLessonName[lesson]))
+			sb.WriteString(fmt.Sprintf("<b>%s</b>", utils.FormatLessonName(lesson)))
 		}
 	}
 	return sb.String()
 }
 
-func AskSubCreationConfirmationMsg(sub *subscription.RequestSubscription) string {
+// AskSubCreationConfirmationMsg takes the lessons of the subscription weekday to show the chosen lesson times.
+// Labs the catalog has never seen are flagged, they are likely mistyped
+func AskSubCreationConfirmationMsg(sub *subscription.RequestSubscription, domains polling.Domains, companyName, labName string, unknownLab bool, lessons []schedule.Lesson) string {
+	return subConfirmationMsg("✅ Создать подписку?", sub, domains, companyName, labName, unknownLab, lessons)
+}
+
+func AskSubEditConfirmationMsg(sub *subscription.RequestSubscription, domains polling.Domains, companyName, labName string, unknownLab bool, lessons []schedule.Lesson) string {
+	return subConfirmationMsg("✏️ Сохранить подписку?", sub, domains, companyName, labName, unknownLab, lessons)
+}
+
+func subConfirmationMsg(title string, sub *subscription.RequestSubscription, domains polling.Domains, companyName, labName string, unknownLab bool, lessons []schedule.Lesson) string {
 	var sb strings.Builder
-	sb.WriteString("<b>✅ Создать подписку?</b>")
+	sb.WriteString(fmt.Sprintf("<b>%s</b>", title))
 	sb.WriteString(repeatLineBreaks(2))
-	sb.WriteString(fmt.Sprintf("<b>📚 Лаба: %d. %s</b>", sub.LabNumber, sub.Type.String()))
+	if companyName != "" {
+		sb.WriteString(fmt.Sprintf("<b>🏛️ %s</b>", html.EscapeString(companyName)))
+		sb.WriteString(repeatLineBreaks(2))
+	}
+	sb.WriteString(fmt.Sprintf("<b>📚 Лаба: %d. %s</b>", sub.LabNumber, labTitle(labName, sub.Type)))
 	sb.WriteString(repeatLineBreaks(2))
+	if unknownLab {
+		sb.WriteString("<b>⚠️ Такой лабы ещё не было на странице записи, проверьте номер</b>")
+		sb.WriteString(repeatLineBreaks(2))
+	}
 	if sub.LabAuditorium != nil {
 		sb.WriteString(fmt.Sprintf("<b>🚪 Аудитория:</b> %d", *sub.LabAuditorium))
 	} else if sub.LabDomain != nil {
-		sb.WriteString(fmt.Sprintf("<b>⚛️ %s</b>", sub.LabDomain))
+		sb.WriteString(fmt.Sprintf("<b>⚛️ %s</b>", domainLabel(domains, *sub.LabDomain)))
 	}
 	sb.WriteString(repeatLineBreaks(2))
 
@@ -146,7 +193,7 @@ func AskSubCreationConfirmationMsg(sub *subscription.RequestSubscription) string
 		sb.WriteString(fmt.Sprintf("<b>🕐 Время:</b>"))
 		sb.WriteString(repeatLineBreaks(2))
 		for _, lesson := range sub.Lessons {
-			sb.WriteString(fmt.Sprintf("<b>%s</b>", utils.DefaultLessons[lesson-1].Text))
+			sb.WriteString(fmt.Sprintf("<b>%s</b>", lessonText(lessons, lesson)))
 			sb.WriteString(repeatLineBreaks(1))
 		}
 	}
@@ -166,6 +213,22 @@ func SubCreationSuccessMsg() string {
 	return sb.String()
 }
 
+func SubEditCancelledMsg() string {
+	return "<b>❌ Изменение подписки отменено</b>"
+}
+
+func SubEditSuccessMsg() string {
+	return "<b>✅ Подписка изменена!</b>"
+}
+
+func SubExistsMsg() string {
+	var sb strings.Builder
+	sb.WriteString("<b>⚠️ У вас уже есть такая подписка</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("Посмотреть подписки можно командой /list")
+	return sb.String()
+}
+
 // ===
 
 // Subscription listing flow
@@ -178,14 +241,33 @@ func EmptySubListMsg() string {
 	return sb.String()
 }
 
-func SubViewMsg(sub *subscription.ResponseSubscription) string {
+// labTitle is the human-readable name of the lab, or its type when the name is unknown.
+// Names come from dikidi and are escaped for HTML messages
+func labTitle(name string, labType polling.LabType) string {
+	if name != "" {
+		return html.EscapeString(name)
+	}
+	return labType.String()
+}
+
+// domainLabel is the label of the domain, escaped since labels come from the config
+func domainLabel(domains polling.Domains, domain polling.LabDomain) string {
+	return html.EscapeString(domains.Label(domain))
+}
+
+// SubViewMsg takes the regular lessons of the subscription weekday to name its lessons
+func SubViewMsg(sub *subscription.ResponseSubscription, domains polling.Domains, companyName, labName string, lessons []schedule.Lesson) string {
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("<b>📚 Лаба: %d. %s</b>", sub.LabNumber, sub.LabType.String()))
+	if companyName != "" {
+		sb.WriteString(fmt.Sprintf("<b>🏛️ %s</b>", html.EscapeString(companyName)))
+		sb.WriteString(repeatLineBreaks(2))
+	}
+	sb.WriteString(fmt.Sprintf("<b>📚 Лаба: %d. %s</b>", sub.LabNumber, labTitle(labName, sub.LabType)))
 	sb.WriteString(repeatLineBreaks(2))
 	if sub.LabAuditorium != nil {
 		sb.WriteString(fmt.Sprintf("<b>🚪 Аудитория:</b> %d", *sub.LabAuditorium))
 	} else if sub.LabDomain != nil {
-		sb.WriteString(fmt.Sprintf("<b>⚛️ %s</b>", sub.LabDomain))
+		sb.WriteString(fmt.Sprintf("<b>⚛️ %s</b>", domainLabel(domains, *sub.LabDomain)))
 	}
 	sb.WriteString(repeatLineBreaks(2))
 
@@ -194,11 +276,11 @@ func SubViewMsg(sub *subscription.ResponseSubscription) string {
 		sb.WriteString(repeatLineBreaks(2))
 	}
 
-	if len(sub.PreferredTimes) > 0 {
+	if len(sub.Lessons) > 0 {
 		sb.WriteString(fmt.Sprintf("<b>🕐 Время:</b>"))
 		sb.WriteString(repeatLineBreaks(2))
-		for _, prefTime := range sub.PreferredTimes {
-			sb.WriteString(fmt.Sprintf("<b>%s</b>", utils.TimeStartToLongLessonTime[prefTime.TimeStart]))
+		for _, lesson := range sub.Lessons {
+			sb.WriteString(fmt.Sprintf("<b>%s</b>", lessonText(lessons, lesson)))
 			sb.WriteString(repeatLineBreaks(1))
 		}
 	}
@@ -211,6 +293,51 @@ func UnsubSuccessMsg() string {
 
 // ==
 
+// Forecast flow
+
+func ForecastCancelledMsg() string {
+	return "<b>❌ Прогноз отменён</b>"
+}
+
+func ForecastMsg(lab polling.LabDemand, domains polling.Domains, companyName string, forecast *history.Forecast) string {
+	var sb strings.Builder
+	sb.WriteString("<b>🔮 Прогноз</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	if companyName != "" {
+		sb.WriteString(fmt.Sprintf("<b>🏛️ %s</b>", html.EscapeString(companyName)))
+		sb.WriteString(repeatLineBreaks(2))
+	}
+	sb.WriteString(fmt.Sprintf("<b>📚 Лаба: %d. %s</b>", lab.Number, lab.Type.String()))
+	sb.WriteString(repeatLineBreaks(2))
+	if lab.Auditorium != nil {
+		sb.WriteString(fmt.Sprintf("<b>🚪 Аудитория:</b> %d", *lab.Auditorium))
+		sb.WriteString(repeatLineBreaks(2))
+	} else if lab.Domain != nil {
+		sb.WriteString(fmt.Sprintf("<b>⚛️ %s</b>", domainLabel(domains, *lab.Domain)))
+		sb.WriteString(repeatLineBreaks(2))
+	}
+
+	if forecast == nil {
+		sb.WriteString("<b>🤷 Записей на эту лабу пока не видели</b>")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("<b>🕐 Обычно появляются:</b> %s, %02d:00–%02d:00",
+		utils.WeekdayLocale[int(forecast.Weekday)], forecast.Hour, (forecast.Hour+1)%24))
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString(fmt.Sprintf("<b>📅 Публикуют за:</b> %s до лабы", utils.FormatDuration(forecast.LeadTime)))
+	sb.WriteString(repeatLineBreaks(1))
+	if forecast.Taken > 0 {
+		sb.WriteString(fmt.Sprintf("<b>⏳ Разбирают за:</b> %s", utils.FormatDuration(forecast.TimeToTaken)))
+	} else {
+		sb.WriteString("<b>⏳ Разбирают за:</b> пока неизвестно")
+	}
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString(fmt.Sprintf("<i>По %d наблюдаемым записям</i>", forecast.Observations))
+	return sb.String()
+}
+
+// ==
+
 // Teacher report flow
 
 func AskWeekParityMsg() string {
@@ -245,7 +372,7 @@ func TeacherReportSuccessMsg() string {
 	return sb.String()
 }
 
-func TeacherReportAdminMsg(userID int64, auditorium int, weekParity string, weekday int, lessonNum int, surname string) string {
+func TeacherReportAdminMsg(userID int64, auditorium int, weekParity string, weekday int, lessonNum int, lessons []schedule.Lesson, surname string) string {
 	var sb strings.Builder
 	sb.WriteString("<b>👨‍🏫 Информация о преподавателе</b>")
 	sb.WriteString(repeatLineBreaks(2))
@@ -264,7 +391,7 @@ func TeacherReportAdminMsg(userID int64, auditorium int, weekParity string, week
 	sb.WriteString(fmt.Sprintf("<b>📅 День:</b> %s", utils.WeekdayLocale[weekday]))
 	sb.WriteString(repeatLineBreaks(2))
 
-	sb.WriteString(fmt.Sprintf("<b>🕐 Пара:</b> %s", utils.DefaultLessons[lessonNum-1].Text))
+	sb.WriteString(fmt.Sprintf("<b>🕐 Пара:</b> %s", lessonText(lessons, lessonNum)))
 	sb.WriteString(repeatLineBreaks(2))
 	sb.WriteString(fmt.Sprintf("<b>👨‍🏫 Преподаватель:</b> %s", surname))
 
@@ -273,14 +400,28 @@ func TeacherReportAdminMsg(userID int64, auditorium int, weekParity string, week
 
 // ==
 
-func NotifyMsg(notif *notification.Notification) string {
+// NotifyMsg renders slot times in the campus location, whatever location they were decoded in
+func NotifyMsg(notif *notification.Notification, domains polling.Domains, sched schedule.Schedule, loc *time.Location) string {
 	slot := &notif.Slot
 	var sb strings.Builder
-	sb.WriteString("<b>🔥 Появилась запись!</b>")
+	switch notif.Kind {
+	case notification.KindNewTimes:
+		sb.WriteString("<b>🆕 Появилось новое время!</b>")
+	case notification.KindTimesTaken:
+		sb.WriteString("<b>🚫 Время уже занято</b>")
+	case notification.KindLabPublished:
+		sb.WriteString("<b>📢 Лаба появилась на странице записи!</b>")
+	default:
+		sb.WriteString("<b>🔥 Появилась запись!</b>")
+	}
 	sb.WriteString(repeatLineBreaks(3))
-	sb.WriteString(fmt.Sprintf("<b>⚛️ %s</b>", slot.Domain))
+	if slot.CompanyName != "" {
+		sb.WriteString(fmt.Sprintf("<b>🏛️ %s</b>", html.EscapeString(slot.CompanyName)))
+		sb.WriteString(repeatLineBreaks(2))
+	}
+	sb.WriteString(fmt.Sprintf("<b>⚛️ %s</b>", domainLabel(domains, slot.Domain)))
 	sb.WriteString(repeatLineBreaks(2))
-	longName := slot.Name
+	longName := labTitle(slot.Name, slot.Type)
 	if slot.Order != nil {
 		longName += fmt.Sprintf(" (%d-ое место)", *slot.Order)
 	}
@@ -288,11 +429,317 @@ func NotifyMsg(notif *notification.Notification) string {
 	sb.WriteString(repeatLineBreaks(2))
 	sb.WriteString(fmt.Sprintf("<b>🚪 Аудитория №%d</b>", slot.Auditorium))
 	sb.WriteString(repeatLineBreaks(2))
+	if notif.Kind == notification.KindLabPublished {
+		sb.WriteString("<b>Свободного времени пока нет, сообщу, как только появится</b>")
+		return sb.String()
+	}
 	sb.WriteString("<b>🗓️ Когда:</b>")
 	sb.WriteString(repeatLineBreaks(1))
-	slotTimes := make([]time.Time, 0)
-	for t := range slot.TimesTeachers {
-		slotTimes = append(slotTimes, t)
+	writeSlotTimes(&sb, slot.TimesTeachers, &notif.PreferredTimes, sched, loc)
+	return sb.String()
+}
+
+const digestMaxSlots = 15
+
+// DigestMsg lists merged slots of the digest, each with its times grouped by date
+func DigestMsg(digest *notification.Digest, sched schedule.Schedule, loc *time.Location) string {
+	var sb strings.Builder
+	sb.WriteString("<b>📬 Сводка уведомлений</b>")
+	sb.WriteString(repeatLineBreaks(3))
+	for idx, notif := range digest.Notifications {
+		if idx == digestMaxSlots {
+			sb.WriteString(fmt.Sprintf("<b>...и ещё %d</b>", len(digest.Notifications)-idx))
+			break
+		}
+		slot := &notif.Slot
+		if slot.CompanyName != "" {
+			sb.WriteString(fmt.Sprintf("<b>🏛️ %s</b>", html.EscapeString(slot.CompanyName)))
+			sb.WriteString(repeatLineBreaks(1))
+		}
+		sb.WriteString(fmt.Sprintf("<b>📚 Лаба №%d. %s</b>", slot.Number, labTitle(slot.Name, slot.Type)))
+		sb.WriteString(repeatLineBreaks(1))
+		sb.WriteString(fmt.Sprintf("<b>🚪 Аудитория №%d</b>", slot.Auditorium))
+		sb.WriteString(repeatLineBreaks(1))
+		if notif.Kind == notification.KindLabPublished {
+			sb.WriteString("<b>📢 Появилась на странице записи</b>")
+			sb.WriteString(repeatLineBreaks(2))
+			continue
+		}
+		writeSlotTimes(&sb, slot.TimesTeachers, &notif.PreferredTimes, sched, loc)
+	}
+	return sb.String()
+}
+
+// ==
+
+// Quiet hours
+
+func QuietHoursMsg(prefs *notification.Preferences) string {
+	var sb strings.Builder
+	if quiet := prefs.QuietHours(); quiet != nil {
+		sb.WriteString(fmt.Sprintf("<b>🌙 Тихие часы: %s - %s (%s)</b>", quiet.Start, quiet.End, quiet.Timezone))
+	} else {
+		sb.WriteString("<b>🔔 Тихие часы выключены</b>")
+	}
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("В тихие часы уведомления откладываются и приходят одной сводкой, когда они закончатся")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("<b>/quiet 23:00-08:00 - включить</b>")
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString("<b>/quiet 23:00-08:00 Asia/Yekaterinburg - включить в другом часовом поясе</b>")
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString("<b>/quiet off - выключить</b>")
+	return sb.String()
+}
+
+// ==
+
+// Delivery mode
+
+var deliveryModeLocale = map[notification.DeliveryMode]string{
+	notification.DeliveryInstant: "сразу",
+	notification.DeliveryHourly:  "раз в час",
+	notification.DeliveryDaily:   "раз в день",
+}
+
+func DeliveryModeMsg(prefs *notification.Preferences) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>📬 Уведомления приходят: %s</b>", deliveryModeLocale[prefs.DeliveryMode]))
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("В режиме сводки уведомления собираются в одно сообщение, сгруппированное по лабам и датам")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("<b>/digest instant - сразу</b>")
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString("<b>/digest hourly - раз в час</b>")
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString("<b>/digest daily - раз в день</b>")
+	return sb.String()
+}
+
+func DeliveryModeSetMsg(mode notification.DeliveryMode) string {
+	return fmt.Sprintf("<b>✅ Теперь уведомления будут приходить %s</b>", deliveryModeLocale[mode])
+}
+
+func QuietHoursSetMsg(quiet *notification.QuietHours) string {
+	if quiet == nil {
+		return "<b>🔔 Тихие часы выключены</b>"
+	}
+	return fmt.Sprintf("<b>🌙 Тихие часы включены: %s - %s</b>", quiet.Start, quiet.End)
+}
+
+// ==
+
+// Admin commands
+
+func AdminOnlyMsg() string {
+	return "<b>⛔ Команда доступна только администратору</b>"
+}
+
+func StatsMsg(users, subs, slots int, mode config.PollingMode, uptime time.Duration) string {
+	var sb strings.Builder
+	sb.WriteString("<b>📊 Статистика</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString(fmt.Sprintf("<b>👥 Пользователей:</b> %d", users))
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString(fmt.Sprintf("<b>📝 Подписок:</b> %d", subs))
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString(fmt.Sprintf("<b>🔥 Открытых записей:</b> %d", slots))
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString(fmt.Sprintf("<b>⚙️ Режим опроса:</b> %s", utils.FormatPollingMode(mode)))
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString(fmt.Sprintf("<b>⏱️ Работает:</b> %s", utils.FormatDuration(uptime)))
+	return sb.String()
+}
+
+// alertSource names the company of the alert, unnamed companies are told apart by ID
+func alertSource(alert alerting.Alert) string {
+	if alert.Source == "" {
+		return fmt.Sprintf("Компания %d", alert.CompanyID)
+	}
+	return html.EscapeString(alert.Source)
+}
+
+func AlertMsg(alert alerting.Alert, loc *time.Location) string {
+	var sb strings.Builder
+	if alert.Recovered {
+		sb.WriteString(fmt.Sprintf("<b>✅ %s: %s</b>", alertSource(alert), utils.FormatAlertKind(alert.Kind)))
+		sb.WriteString(repeatLineBreaks(2))
+		sb.WriteString(fmt.Sprintf("Всё снова в порядке, проблема длилась %s", utils.FormatDuration(time.Since(alert.Since))))
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("<b>🚨 %s: %s</b>", alertSource(alert), utils.FormatAlertKind(alert.Kind)))
+	sb.WriteString(repeatLineBreaks(2))
+	switch alert.Kind {
+	case alerting.AlertOutage:
+		sb.WriteString(fmt.Sprintf("Ни одного ответа за %d опросов подряд", alert.Current))
+	case alerting.AlertParserDrift:
+		sb.WriteString(fmt.Sprintf("Не удалось разобрать %d из %d ответов, похоже, изменилась разметка", alert.Current, alert.Baseline))
+	case alerting.AlertServiceDrop:
+		sb.WriteString(fmt.Sprintf("Услуг стало %d вместо %d", alert.Current, alert.Baseline))
+	case alerting.AlertSlotDrop:
+		sb.WriteString(fmt.Sprintf("Записей стало %d вместо %d", alert.Current, alert.Baseline))
+	}
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString(fmt.Sprintf("<i>С %s</i>", alert.Since.In(loc).Format("02.01 15:04")))
+	return sb.String()
+}
+
+func CatalogDiffMsg(diff polling.CatalogDiff) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>📋 %s: изменилась страница записи</b>", html.EscapeString(diff.CompanyName)))
+	writeCatalogServices(&sb, "🆕 Новые", diff.Added)
+	writeCatalogServices(&sb, "♻️ Вернулись", diff.Returned)
+	writeCatalogServices(&sb, "🗑️ Убраны", diff.Removed)
+	writeCatalogLabs(&sb, "📢 Опубликованы лабы", diff.Published)
+	writeCatalogLabs(&sb, "🚫 Сняты лабы", diff.Withdrawn)
+	return sb.String()
+}
+
+func writeCatalogLabs(sb *strings.Builder, title string, labs []polling.Slot) {
+	if len(labs) == 0 {
+		return
+	}
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString(fmt.Sprintf("<b>%s: %d</b>", title, len(labs)))
+	for _, lab := range labs {
+		sb.WriteString(repeatLineBreaks(1))
+		sb.WriteString(fmt.Sprintf("№%d. %s, ауд. %d", lab.Number, labTitle(lab.Name, lab.Type), lab.Auditorium))
+	}
+}
+
+func writeCatalogServices(sb *strings.Builder, title string, services []polling.CatalogService) {
+	if len(services) == 0 {
+		return
+	}
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString(fmt.Sprintf("<b>%s: %d</b>", title, len(services)))
+	for _, service := range services {
+		sb.WriteString(repeatLineBreaks(1))
+		sb.WriteString(fmt.Sprintf("%d. %s", service.ServiceID, html.EscapeString(service.Name)))
+	}
+}
+
+func PollingModeSetMsg(mode config.PollingMode) string {
+	return fmt.Sprintf("<b>✅ Режим опроса: %s</b>", utils.FormatPollingMode(mode))
+}
+
+const usersMaxLines = 50
+
+func UsersMsg(users []subscription.UserStats) string {
+	if len(users) == 0 {
+		return "<b>👥 Подписчиков нет</b>"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>👥 Подписчики: %d</b>", len(users)))
+	sb.WriteString(repeatLineBreaks(2))
+	for idx, user := range users {
+		if idx == usersMaxLines {
+			sb.WriteString(fmt.Sprintf("...и ещё %d", len(users)-idx))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("<code>%d</code> - подписок: %d", user.UserID, user.Subscriptions))
+		sb.WriteString(repeatLineBreaks(1))
+	}
+	return sb.String()
+}
+
+func BroadcastUsageMsg() string {
+	var sb strings.Builder
+	sb.WriteString("<b>📢 Использование: /broadcast текст сообщения</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("Текст отправляется как есть, без форматирования")
+	return sb.String()
+}
+
+// BroadcastQueuedMsg reports how many of the users got the broadcast queued
+func BroadcastQueuedMsg(queued, total int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>📢 Рассылка поставлена в очередь: %d из %d</b>", queued, total))
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString("Недоставленные сообщения появятся в /dead")
+	return sb.String()
+}
+
+func DeadNotificationsMsg(msgs []notification.OutboxMessage, loc *time.Location) string {
+	if len(msgs) == 0 {
+		return "<b>✅ Недоставленных уведомлений нет</b>"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>💀 Недоставленные уведомления: %d</b>", len(msgs)))
+	sb.WriteString(repeatLineBreaks(2))
+	for _, msg := range msgs {
+		sb.WriteString(fmt.Sprintf("<b>#%d</b> пользователь %d, попыток: %d, создано %s",
+			msg.ID, msg.UserID, msg.Attempts, msg.CreatedAt.In(loc).Format("02.01 15:04")))
+		sb.WriteString(repeatLineBreaks(1))
+		if msg.LastError != nil {
+			sb.WriteString(fmt.Sprintf("<i>%s</i>", html.EscapeString(*msg.LastError)))
+			sb.WriteString(repeatLineBreaks(1))
+		}
+		sb.WriteString(repeatLineBreaks(1))
+	}
+	sb.WriteString("Используйте /replay [id...] для повторной отправки")
+	return sb.String()
+}
+
+func QuarantineMsg(entries []quarantine.Entry, loc *time.Location) string {
+	if len(entries) == 0 {
+		return "<b>✅ Все записи разбираются</b>"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>🧪 Неразобранные записи: %d</b>", len(entries)))
+	sb.WriteString(repeatLineBreaks(2))
+	for _, entry := range entries {
+		sb.WriteString(fmt.Sprintf("<code>%s</code>", html.EscapeString(entry.Username)))
+		sb.WriteString(repeatLineBreaks(1))
+		sb.WriteString(fmt.Sprintf("<code>%s</code>", html.EscapeString(entry.ServiceName)))
+		sb.WriteString(repeatLineBreaks(1))
+		sb.WriteString(fmt.Sprintf("Услуга %d, встречалась %d раз, с %s по %s",
+			entry.ServiceID, entry.Occurrences, entry.FirstSeen.In(loc).Format("02.01 15:04"), entry.LastSeen.In(loc).Format("02.01 15:04")))
+		sb.WriteString(repeatLineBreaks(1))
+		sb.WriteString(fmt.Sprintf("<i>%s</i>", html.EscapeString(entry.Reason)))
+		sb.WriteString(repeatLineBreaks(2))
+	}
+	return sb.String()
+}
+
+func ParseTestUsageMsg() string {
+	return "<b>🧪 Использование: /parsetest имя мастера | название услуги</b>"
+}
+
+func ParseTestMsg(slot *polling.Slot, domains polling.Domains, err error) string {
+	if err != nil {
+		return fmt.Sprintf("<b>❌ Не разобрано:</b> <i>%s</i>", html.EscapeString(err.Error()))
+	}
+	var sb strings.Builder
+	sb.WriteString("<b>✅ Разобрано</b>")
+	sb.WriteString(repeatLineBreaks(2))
+	sb.WriteString(fmt.Sprintf("<b>📚 Лаба:</b> %d. %s", slot.Number, html.EscapeString(slot.Name)))
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString(fmt.Sprintf("<b>🎯 Тип:</b> %s", slot.Type))
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString(fmt.Sprintf("<b>🚪 Аудитория:</b> %d", slot.Auditorium))
+	sb.WriteString(repeatLineBreaks(1))
+	sb.WriteString(fmt.Sprintf("<b>⚛️ Направление:</b> %s (%s)", domainLabel(domains, slot.Domain), html.EscapeString(string(slot.Domain))))
+	if slot.Order != nil {
+		sb.WriteString(repeatLineBreaks(1))
+		sb.WriteString(fmt.Sprintf("<b>🔢 Место:</b> %d", *slot.Order))
+	}
+	return sb.String()
+}
+
+func ReplayNotificationsMsg(replayed int64) string {
+	return fmt.Sprintf("<b>🔁 Поставлено в очередь повторно: %d</b>", replayed)
+}
+
+// writeSlotTimes lists slot times grouped by date, marking the ones the user prefers
+func writeSlotTimes(sb *strings.Builder, slotTimesTeachers map[time.Time][]string, prefTimes *notification.PreferredTimes, sched schedule.Schedule, loc *time.Location) {
+	slotTimes := make([]time.Time, 0, len(slotTimesTeachers))
+	teachersByTime := make(map[time.Time][]string, len(slotTimesTeachers))
+	for t, teachers := range slotTimesTeachers {
+		local := t.In(loc)
+		slotTimes = append(slotTimes, local)
+		teachersByTime[local] = teachers
 	}
 	grouped := utils.GroupTimesByDate(slotTimes)
 	sortedDates := make([]time.Time, 0, len(grouped))
@@ -303,7 +750,7 @@ func NotifyMsg(notif *notification.Notification) string {
 		return a.Compare(b)
 	})
 	for _, date := range sortedDates {
-		dateRelative := utils.FormatDateRelative(date, time.Now())
+		dateRelative := utils.FormatDateRelative(date, time.Now().In(loc))
 		sb.WriteString(fmt.Sprintf("<b>⠀⠀%s:</b>", dateRelative))
 		sb.WriteString(repeatLineBreaks(1))
 		times := grouped[date]
@@ -312,14 +759,16 @@ func NotifyMsg(notif *notification.Notification) string {
 		})
 		for _, t := range times {
 			stringParts := make([]string, 0)
-			timeStart := t.Format("15:04")
-			lessonTime := utils.TimeStartToShortLessonTime[timeStart]
+			lessonTime := t.Format("15:04")
+			if lesson, ok := sched.LessonAt(t); ok {
+				lessonTime = utils.FormatLessonShort(lesson)
+			}
 			stringParts = append(stringParts, lessonTime)
-			if teachers, ok := slot.TimesTeachers[t]; ok {
+			if teachers, ok := teachersByTime[t]; ok {
 				teachersStr := strings.Join(teachers, ", ")
 				stringParts = append(stringParts, teachersStr)
 			}
-			if utils.IsTimeInPreferredTimes(t, &notif.PreferredTimes) {
+			if utils.IsTimeInPreferredTimes(t, prefTimes) {
 				stringParts = append(stringParts, "⭐️ Ваше время")
 			}
 			sb.WriteString(fmt.Sprintf("<b>⠀⠀%s</b>", strings.Join(stringParts, " ")))
@@ -327,7 +776,16 @@ func NotifyMsg(notif *notification.Notification) string {
 		}
 		sb.WriteString(repeatLineBreaks(1))
 	}
-	return sb.String()
+}
+
+// lessonText describes a lesson by its number, with times when the lesson is in the schedule
+func lessonText(lessons []schedule.Lesson, number int) string {
+	for _, lesson := range lessons {
+		if lesson.Number == number {
+			return utils.FormatLessonLong(lesson)
+		}
+	}
+	return utils.FormatLessonName(number)
 }
 
 func repeatLineBreaks(breaks int) string {
@@ -2,8 +2,11 @@ package utils
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/Ademun/mining-lab-bot/internal/alerting"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
 	"github.com/Ademun/mining-lab-bot/pkg/config"
 )
 
@@ -17,39 +20,6 @@ var WeekdayLocale = map[int]string{
 	6: "Суббота",
 }
 
-var TimeStartToLongLessonTime = map[string]string{
-	"08:50": "08:50 - 10:20 - 1️⃣ пара",
-	"10:35": "10:35 - 12:05 - 2️⃣ пара",
-	"12:35": "12:35 - 14:05 - 3️⃣ пара",
-	"14:15": "14:15 - 15:45 - 4️⃣ пара",
-	"15:55": "15:55 - 17:20 - 5️⃣ пара",
-	"17:30": "17:30 - 19:00 - 6️⃣ пара",
-	"19:10": "19:10 - 20:30 - 7️⃣ пара",
-	"20:40": "20:40 - 22:00 - 8️⃣ пара",
-}
-
-var TimeStartToShortLessonTime = map[string]string{
-	"08:50": "1️⃣ 08:50 - 10:20",
-	"10:35": "2️⃣ 10:35 - 12:05",
-	"12:35": "3️⃣ 12:35 - 14:05",
-	"14:15": "4️⃣ 14:15 - 15:45",
-	"15:55": "5️⃣ 15:55 - 17:20",
-	"17:30": "6️⃣ 17:30 - 19:00",
-	"19:10": "7️⃣ 19:10 - 20:30",
-	"20:40": "8️⃣ 20:40 - 22:00",
-}
-
-var LessonNumberToLessonName = map[int]string{
-	1: "1️⃣ пара",
-	2: "2️⃣ пара",
-	3: "3️⃣ пара",
-	4: "4️⃣ пара",
-	5: "5️⃣ пара",
-	6: "6️⃣ пара",
-	7: "7️⃣ пара",
-	8: "8️⃣ пара",
-}
-
 var Months = []string{
 	"января", "февраля", "марта", "апреля", "мая", "июня",
 	"июля", "августа", "сентября", "октября", "ноября", "декабря",
@@ -102,3 +72,41 @@ func FormatPollingMode(mode config.PollingMode) string {
 		return "неизвестный"
 	}
 }
+
+func FormatAlertKind(kind alerting.AlertKind) string {
+	switch kind {
+	case alerting.AlertOutage:
+		return "источник недоступен"
+	case alerting.AlertParserDrift:
+		return "ошибки разбора"
+	case alerting.AlertServiceDrop:
+		return "пропали услуги"
+	case alerting.AlertSlotDrop:
+		return "пропали записи"
+	default:
+		return string(kind)
+	}
+}
+
+// FormatLessonName renders a lesson number like "1️⃣ пара"
+func FormatLessonName(number int) string {
+	return fmt.Sprintf("%s пара", lessonNumberEmoji(number))
+}
+
+// FormatLessonLong renders a lesson like "08:50 - 10:20 - 1️⃣ пара"
+func FormatLessonLong(lesson schedule.Lesson) string {
+	return fmt.Sprintf("%s - %s - %s", lesson.Start, lesson.End, FormatLessonName(lesson.Number))
+}
+
+// FormatLessonShort renders a lesson like "1️⃣ 08:50 - 10:20"
+func FormatLessonShort(lesson schedule.Lesson) string {
+	return fmt.Sprintf("%s %s - %s", lessonNumberEmoji(lesson.Number), lesson.Start, lesson.End)
+}
+
+// lessonNumberEmoji turns single digits into keycap emojis, longer numbers stay as they are
+func lessonNumberEmoji(number int) string {
+	if number < 0 || number > 9 {
+		return strconv.Itoa(number)
+	}
+	return strconv.Itoa(number) + "\uFE0F\u20E3"
+}
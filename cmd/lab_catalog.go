@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Ademun/mining-lab-bot/cmd/fsm"
+	"github.com/Ademun/mining-lab-bot/internal/catalog"
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/subscription"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+)
+
+// catalogLabs returns the known labs of the type, nil when there is no catalog to ask
+func (b *telegramBot) catalogLabs(ctx context.Context, companyID *int, labType polling.LabType) []catalog.Lab {
+	if b.catalogService == nil {
+		return nil
+	}
+	labs, err := b.catalogService.Labs(ctx, companyID, labType)
+	if err != nil {
+		slog.Error("Failed to read lab catalog", "error", err, "service", logger.TelegramBot)
+		return nil
+	}
+	return labs
+}
+
+// labNumberLabs keeps the labs with the number, they differ only by auditorium or domain
+func labNumberLabs(labs []catalog.Lab, number int) []catalog.Lab {
+	numberLabs := make([]catalog.Lab, 0)
+	for _, lab := range labs {
+		if lab.Number == number {
+			numberLabs = append(numberLabs, lab)
+		}
+	}
+	return numberLabs
+}
+
+// catalogLab returns the name of the lab and whether the catalog has never seen it.
+// Labs are reported unknown only when the catalog knows some labs of the type, an empty catalog proves nothing
+func (b *telegramBot) catalogLab(ctx context.Context, demand polling.LabDemand) (string, bool) {
+	labs := b.catalogLabs(ctx, demand.CompanyID, demand.Type)
+	if len(labs) == 0 {
+		return "", false
+	}
+	lab, err := b.catalogService.FindLab(ctx, demand)
+	if err != nil {
+		slog.Error("Failed to find lab in catalog", "error", err, "lab", demand, "service", logger.TelegramBot)
+		return "", false
+	}
+	if lab == nil {
+		return "", true
+	}
+	return lab.Name, false
+}
+
+func flowLabDemand(data *fsm.SubscriptionCreationFlowData) polling.LabDemand {
+	return polling.LabDemand{
+		CompanyID:  data.CompanyID,
+		Type:       data.LabType,
+		Number:     data.LabNumber,
+		Auditorium: data.LabAuditorium,
+		Domain:     data.LabDomain,
+	}
+}
+
+func subLabDemand(sub *subscription.ResponseSubscription) polling.LabDemand {
+	return polling.LabDemand{
+		CompanyID:  sub.CompanyID,
+		Type:       sub.LabType,
+		Number:     sub.LabNumber,
+		Auditorium: sub.LabAuditorium,
+		Domain:     sub.LabDomain,
+	}
+}
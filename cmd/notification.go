@@ -2,23 +2,93 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/Ademun/mining-lab-bot/cmd/fsm"
 	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
+	"github.com/Ademun/mining-lab-bot/internal/alerting"
 	"github.com/Ademun/mining-lab-bot/internal/notification"
+	"github.com/Ademun/mining-lab-bot/internal/polling"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
-func (b *telegramBot) SendNotification(ctx context.Context, notif notification.Notification) {
+// SendNotification is called by the notification outbox, so errors are translated into what the outbox understands
+func (b *telegramBot) SendNotification(ctx context.Context, notif notification.Notification) error {
 	userID := notif.UserID
 
-	b.TryTransition(ctx, int64(userID), fsm.StepAwaitingFeedbackReaction, &fsm.IdleData{})
-
-	b.SendMessage(ctx, &bot.SendMessageParams{
+	_, err := b.api.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userID,
-		Text:        presentation.NotifyMsg(&notif),
+		Text:        presentation.NotifyMsg(&notif, b.parsingRules.Domains(), b.schedule, b.location),
 		ReplyMarkup: presentation.LinkKbd(notif.Slot.URL),
 		ParseMode:   models.ParseModeHTML,
 	})
+	if err != nil {
+		return translateSendError(err)
+	}
+
+	b.TryTransition(ctx, int64(userID), fsm.StepAwaitingFeedbackReaction, &fsm.IdleData{})
+	return nil
+}
+
+// SendAlert tells the admin about problems with the slot sources
+func (b *telegramBot) SendAlert(ctx context.Context, alert alerting.Alert) error {
+	_, err := b.api.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    b.options.AdminID,
+		Text:      presentation.AlertMsg(alert, b.location),
+		ParseMode: models.ParseModeHTML,
+	})
+	return err
+}
+
+// SendCatalogDiff tells the admin about services published on or removed from a booking page
+func (b *telegramBot) SendCatalogDiff(ctx context.Context, diff polling.CatalogDiff) error {
+	_, err := b.api.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    b.options.AdminID,
+		Text:      presentation.CatalogDiffMsg(diff),
+		ParseMode: models.ParseModeHTML,
+	})
+	return err
+}
+
+func (b *telegramBot) SendDigest(ctx context.Context, digest notification.Digest) error {
+	_, err := b.api.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      digest.UserID,
+		Text:        presentation.DigestMsg(&digest, b.schedule, b.location),
+		ReplyMarkup: presentation.DigestKbd(&digest),
+		ParseMode:   models.ParseModeHTML,
+	})
+	if err != nil {
+		return translateSendError(err)
+	}
+	return nil
+}
+
+// SendBroadcast sends the admin text without a parse mode, so that markup characters can't get it rejected
+func (b *telegramBot) SendBroadcast(ctx context.Context, broadcast notification.Broadcast) error {
+	_, err := b.api.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: broadcast.UserID,
+		Text:   broadcast.Text,
+	})
+	if err != nil {
+		return translateSendError(err)
+	}
+	return nil
+}
+
+// translateSendError tells the outbox whether the failed message should be retried later or dropped
+func translateSendError(err error) error {
+	var tooManyRequests *bot.TooManyRequestsError
+	switch {
+	case errors.As(err, &tooManyRequests):
+		return &notification.ErrRetryAfter{
+			RetryAfter: time.Duration(tooManyRequests.RetryAfter) * time.Second,
+			Err:        err,
+		}
+	case errors.Is(err, bot.ErrorForbidden), errors.Is(err, bot.ErrorBadRequest):
+		return fmt.Errorf("%w: %w", notification.ErrUndeliverable, err)
+	}
+	return err
 }
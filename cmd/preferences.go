@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
+	"github.com/Ademun/mining-lab-bot/internal/notification"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// /quiet command. Without arguments shows the current setting, "off" disables quiet hours
+func (b *telegramBot) handleQuietHours(ctx context.Context, api *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+	userID := update.Message.From.ID
+	args := strings.Fields(update.Message.Text)[1:]
+
+	if len(args) == 0 {
+		prefs, err := b.notifService.GetPreferences(ctx, int(userID))
+		if err != nil {
+			slog.Error("Failed to get preferences",
+				"error", err,
+				"user_id", userID,
+				"service", logger.TelegramBot)
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID:    userID,
+				Text:      presentation.GenericServiceErrorMsg(),
+				ParseMode: models.ParseModeHTML,
+			})
+			return
+		}
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.QuietHoursMsg(prefs),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	var quiet *notification.QuietHours
+	if !(len(args) == 1 && args[0] == "off") {
+		var errMsg string
+		quiet, errMsg = validateQuietHours(args)
+		if errMsg != "" {
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID:    userID,
+				Text:      presentation.ValidationErrorMsg(errMsg),
+				ParseMode: models.ParseModeHTML,
+			})
+			return
+		}
+	}
+
+	if err := b.notifService.SetQuietHours(ctx, int(userID), quiet); err != nil {
+		slog.Error("Failed to set quiet hours",
+			"error", err,
+			"user_id", userID,
+			"service", logger.TelegramBot)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.GenericServiceErrorMsg(),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    userID,
+		Text:      presentation.QuietHoursSetMsg(quiet),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// /digest command. Without arguments shows the current delivery mode
+func (b *telegramBot) handleDeliveryMode(ctx context.Context, api *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+	userID := update.Message.From.ID
+	args := strings.Fields(update.Message.Text)[1:]
+
+	if len(args) == 0 {
+		prefs, err := b.notifService.GetPreferences(ctx, int(userID))
+		if err != nil {
+			slog.Error("Failed to get preferences",
+				"error", err,
+				"user_id", userID,
+				"service", logger.TelegramBot)
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID:    userID,
+				Text:      presentation.GenericServiceErrorMsg(),
+				ParseMode: models.ParseModeHTML,
+			})
+			return
+		}
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.DeliveryModeMsg(prefs),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	mode, errMsg := validateDeliveryMode(args[0])
+	if errMsg != "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.ValidationErrorMsg(errMsg),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	if err := b.notifService.SetDeliveryMode(ctx, int(userID), mode); err != nil {
+		slog.Error("Failed to set delivery mode",
+			"error", err,
+			"user_id", userID,
+			"service", logger.TelegramBot)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.GenericServiceErrorMsg(),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    userID,
+		Text:      presentation.DeliveryModeSetMsg(mode),
+		ParseMode: models.ParseModeHTML,
+	})
+}
@@ -2,13 +2,15 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
 	"github.com/Ademun/mining-lab-bot/cmd/fsm"
 	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
-	"github.com/Ademun/mining-lab-bot/cmd/internal/utils"
 	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
 	"github.com/Ademun/mining-lab-bot/internal/subscription"
+	"github.com/Ademun/mining-lab-bot/pkg/errs"
 	"github.com/Ademun/mining-lab-bot/pkg/logger"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -20,8 +22,23 @@ func (b *telegramBot) handleSubscriptionCreation(ctx context.Context, api *bot.B
 	}
 	userID := update.Message.From.ID
 
-	newData := &fsm.SubscriptionCreationFlowData{
+	b.startLabWizard(ctx, userID, &fsm.SubscriptionCreationFlowData{
 		UserID: int(userID),
+	})
+}
+
+// startLabWizard asks for the lab, the steps after it depend on what the wizard was started for
+func (b *telegramBot) startLabWizard(ctx context.Context, userID int64, newData *fsm.SubscriptionCreationFlowData) {
+	// Company is asked only when there are several of them to choose from
+	if len(b.companies) > 1 {
+		b.TryTransition(ctx, userID, fsm.StepAwaitingLabCompany, newData)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      userID,
+			Text:        presentation.AskCompanyMsg(),
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: presentation.SelectCompanyKbd(b.companies),
+		})
+		return
 	}
 
 	b.TryTransition(ctx, userID, fsm.StepAwaitingLabType, newData)
@@ -34,8 +51,46 @@ func (b *telegramBot) handleSubscriptionCreation(ctx context.Context, api *bot.B
 	})
 }
 
+func (b *telegramBot) handleLabCompany(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
+		return
+	}
+	if update.CallbackQuery == nil {
+		return
+	}
+	userID := update.CallbackQuery.From.ID
+	companyID := extractCompany(update)
+
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	newData, ok := data.(*fsm.SubscriptionCreationFlowData)
+	if !ok {
+		slog.Error("Critical error: unable to assert flow data",
+			"data", data,
+			"service", logger.TelegramBot)
+		b.TryTransition(ctx, userID, fsm.StepIdle, &fsm.IdleData{})
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    userID,
+			Text:      presentation.GenericServiceErrorMsg(),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+	newData.CompanyID = companyID
+
+	b.TryTransition(ctx, userID, fsm.StepAwaitingLabType, newData)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        presentation.AskLabTypeMsg(),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: presentation.SelectLabTypeKbd(),
+	})
+}
+
 func (b *telegramBot) handleLabType(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
-	if handleSubCreationCancellation(ctx, b, update) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
 		return
 	}
 	if update.CallbackQuery == nil {
@@ -62,25 +117,34 @@ func (b *telegramBot) handleLabType(ctx context.Context, api *bot.Bot, update *m
 		return
 	}
 	newData.LabType = labType
+	labs := b.catalogLabs(ctx, newData.CompanyID, labType)
 
 	b.TryTransition(ctx, userID, fsm.StepAwaitingLabNumber, newData)
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userID,
-		Text:        presentation.AskLabNumberMsg(),
+		Text:        presentation.AskLabNumberMsg(len(labs) > 0),
 		ParseMode:   models.ParseModeHTML,
-		ReplyMarkup: presentation.CancelKbd(),
+		ReplyMarkup: presentation.SelectLabNumberKbd(labs),
 	})
 }
 
 func (b *telegramBot) handleLabNumber(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
-	if handleSubCreationCancellation(ctx, b, update) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
 		return
 	}
-	if update.Message == nil {
+	var userID int64
+	switch {
+	case update.CallbackQuery != nil:
+		userID = update.CallbackQuery.From.ID
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+		})
+	case update.Message != nil:
+		userID = update.Message.From.ID
+	default:
 		return
 	}
-	userID := update.Message.From.ID
-	labNumberStr := update.Message.Text
+	labNumberStr := extractLabNumber(update)
 
 	labNumber, cause := validateLabNumber(labNumberStr)
 	if cause != "" {
@@ -109,33 +173,44 @@ func (b *telegramBot) handleLabNumber(ctx context.Context, api *bot.Bot, update
 
 	switch newData.LabType {
 	case polling.LabTypePerformance:
+		newData.LabDomain = nil
+		labs := labNumberLabs(b.catalogLabs(ctx, newData.CompanyID, newData.LabType), labNumber)
 		b.TryTransition(ctx, userID, fsm.StepAwaitingLabAuditorium, newData)
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      userID,
-			Text:        presentation.AskLabAuditoriumMsg(),
+			Text:        presentation.AskLabAuditoriumMsg(len(labs) > 0),
 			ParseMode:   models.ParseModeHTML,
-			ReplyMarkup: presentation.CancelKbd(),
+			ReplyMarkup: presentation.SelectLabAuditoriumKbd(labs),
 		})
 	case polling.LabTypeDefence:
+		newData.LabAuditorium = nil
 		b.TryTransition(ctx, userID, fsm.StepAwaitingLabDomain, newData)
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      userID,
 			Text:        presentation.AskLabDomainMsg(),
 			ParseMode:   models.ParseModeHTML,
-			ReplyMarkup: presentation.SelectLabDomainKbd(),
+			ReplyMarkup: presentation.SelectLabDomainKbd(b.parsingRules.Domains()),
 		})
 	}
 }
 
 func (b *telegramBot) handleLabAuditorium(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
-	if handleSubCreationCancellation(ctx, b, update) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
 		return
 	}
-	if update.Message == nil {
+	var userID int64
+	switch {
+	case update.CallbackQuery != nil:
+		userID = update.CallbackQuery.From.ID
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+		})
+	case update.Message != nil:
+		userID = update.Message.From.ID
+	default:
 		return
 	}
-	userID := update.Message.From.ID
-	labAuditoriumStr := update.Message.Text
+	labAuditoriumStr := extractLabAuditorium(update)
 
 	labAuditorium, cause := validateLabAuditorium(labAuditoriumStr)
 	if cause != "" {
@@ -158,8 +233,18 @@ func (b *telegramBot) handleLabAuditorium(ctx context.Context, api *bot.Bot, upd
 			Text:      presentation.GenericServiceErrorMsg(),
 			ParseMode: models.ParseModeHTML,
 		})
+		return
 	}
 	newData.LabAuditorium = &labAuditorium
+	if newData.Forecast {
+		b.sendForecast(ctx, userID, newData)
+		return
+	}
+	// Day and time are already known when editing
+	if newData.EditUUID != nil {
+		b.askSubConfirmation(ctx, userID, newData)
+		return
+	}
 
 	b.TryTransition(ctx, userID, fsm.StepAwaitingLabWeekday, newData)
 	b.SendMessage(ctx, &bot.SendMessageParams{
@@ -171,18 +256,21 @@ func (b *telegramBot) handleLabAuditorium(ctx context.Context, api *bot.Bot, upd
 }
 
 func (b *telegramBot) handleLabDomain(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
-	if handleSubCreationCancellation(ctx, b, update) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
 		return
 	}
 	if update.CallbackQuery == nil {
 		return
 	}
 	userID := update.CallbackQuery.From.ID
-	labDomain := extractLabDomain(update)
+	labDomain := extractLabDomain(update, b.parsingRules.Domains())
 
 	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
+	if labDomain == nil {
+		return
+	}
 
 	newData, ok := data.(*fsm.SubscriptionCreationFlowData)
 	if !ok {
@@ -198,6 +286,14 @@ func (b *telegramBot) handleLabDomain(ctx context.Context, api *bot.Bot, update
 		return
 	}
 	newData.LabDomain = labDomain
+	if newData.Forecast {
+		b.sendForecast(ctx, userID, newData)
+		return
+	}
+	if newData.EditUUID != nil {
+		b.askSubConfirmation(ctx, userID, newData)
+		return
+	}
 
 	b.TryTransition(ctx, userID, fsm.StepAwaitingLabWeekday, newData)
 	b.SendMessage(ctx, &bot.SendMessageParams{
@@ -209,7 +305,7 @@ func (b *telegramBot) handleLabDomain(ctx context.Context, api *bot.Bot, update
 }
 
 func (b *telegramBot) handleWeekday(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
-	if handleSubCreationCancellation(ctx, b, update) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
 		return
 	}
 	if update.CallbackQuery == nil {
@@ -236,15 +332,11 @@ func (b *telegramBot) handleWeekday(ctx context.Context, api *bot.Bot, update *m
 		return
 	}
 	newData.Weekday = weekday
+	// Lessons of the previous weekday, prefilled when editing, mean nothing on another one
+	newData.Lessons = nil
 
 	if weekday == nil {
-		b.TryTransition(ctx, userID, fsm.StepAwaitingSubCreationConfirmation, newData)
-		b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:      userID,
-			Text:        presentation.AskSubCreationConfirmationMsg(parseFlowData(newData)),
-			ParseMode:   models.ParseModeHTML,
-			ReplyMarkup: presentation.AskSubCreationConfirmationKbd(),
-		})
+		b.askSubConfirmation(ctx, userID, newData)
 		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
 		})
@@ -256,7 +348,7 @@ func (b *telegramBot) handleWeekday(ctx context.Context, api *bot.Bot, update *m
 		ChatID:      userID,
 		Text:        presentation.AskLessonsMsg(nil),
 		ParseMode:   models.ParseModeHTML,
-		ReplyMarkup: presentation.SelectLessonKbd(utils.DefaultLessons, true),
+		ReplyMarkup: presentation.SelectLessonKbd(b.weekdayLessons(weekday), true),
 	})
 	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
@@ -264,7 +356,7 @@ func (b *telegramBot) handleWeekday(ctx context.Context, api *bot.Bot, update *m
 }
 
 func (b *telegramBot) handleLessons(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
-	if handleSubCreationCancellation(ctx, b, update) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
 		return
 	}
 	if update.CallbackQuery == nil {
@@ -292,15 +384,7 @@ func (b *telegramBot) handleLessons(ctx context.Context, api *bot.Bot, update *m
 	}
 
 	if lesson == nil {
-		sub := parseFlowData(newData)
-		b.TryTransition(ctx, userID, fsm.StepAwaitingSubCreationConfirmation, newData)
-
-		b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:      userID,
-			Text:        presentation.AskSubCreationConfirmationMsg(sub),
-			ParseMode:   models.ParseModeHTML,
-			ReplyMarkup: presentation.AskSubCreationConfirmationKbd(),
-		})
+		b.askSubConfirmation(ctx, userID, newData)
 		return
 	}
 
@@ -311,10 +395,10 @@ func (b *telegramBot) handleLessons(ctx context.Context, api *bot.Bot, update *m
 		existingLessonsMap[lesson] = true
 	}
 
-	kbdLessons := make([]utils.Lesson, 0, len(utils.DefaultLessons))
-	for i, lesson := range utils.DefaultLessons {
-		lessonNum := i + 1
-		if !existingLessonsMap[lessonNum] {
+	lessons := b.weekdayLessons(newData.Weekday)
+	kbdLessons := make([]schedule.Lesson, 0, len(lessons))
+	for _, lesson := range lessons {
+		if !existingLessonsMap[lesson.Number] {
 			kbdLessons = append(kbdLessons, lesson)
 		}
 	}
@@ -334,7 +418,7 @@ func (b *telegramBot) handleLessons(ctx context.Context, api *bot.Bot, update *m
 }
 
 func (b *telegramBot) handleSubCreationConfirmation(ctx context.Context, api *bot.Bot, update *models.Update, data fsm.StateData) {
-	if handleSubCreationCancellation(ctx, b, update) {
+	if handleSubCreationCancellation(ctx, b, update, data) {
 		return
 	}
 	if update.CallbackQuery == nil {
@@ -360,14 +444,44 @@ func (b *telegramBot) handleSubCreationConfirmation(ctx context.Context, api *bo
 		return
 	}
 
+	switch update.CallbackQuery.Data {
+	case "edit:lab":
+		b.startLabWizard(ctx, userID, newData)
+		return
+	case "edit:time":
+		b.TryTransition(ctx, userID, fsm.StepAwaitingLabWeekday, newData)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      userID,
+			Text:        presentation.AskWeekdayMsg(),
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: presentation.SelectWeekdayKbd(true),
+		})
+		return
+	case "confirm:create", "confirm:save":
+	default:
+		// Buttons of older messages, like the listing the edit was started from
+		return
+	}
+
 	sub := parseFlowData(newData)
 	b.TryTransition(ctx, userID, fsm.StepIdle, &fsm.IdleData{})
 
-	err := b.subscriptionService.Subscribe(ctx, *sub)
+	var err error
+	successMsg := presentation.SubCreationSuccessMsg()
+	if newData.EditUUID != nil {
+		err = b.subscriptionService.Update(ctx, *newData.EditUUID, *sub)
+		successMsg = presentation.SubEditSuccessMsg()
+	} else {
+		err = b.subscriptionService.Subscribe(ctx, *sub)
+	}
 	if err != nil {
+		text := presentation.GenericServiceErrorMsg()
+		if errors.Is(err, errs.ErrSubscriptionExists) {
+			text = presentation.SubExistsMsg()
+		}
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:    userID,
-			Text:      presentation.GenericServiceErrorMsg(),
+			Text:      text,
 			ParseMode: models.ParseModeHTML,
 		})
 		return
@@ -375,14 +489,35 @@ func (b *telegramBot) handleSubCreationConfirmation(ctx context.Context, api *bo
 
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    userID,
-		Text:      presentation.SubCreationSuccessMsg(),
+		Text:      successMsg,
 		ParseMode: models.ParseModeHTML,
 	})
+	// An edited subscription may match slots that are already open, same as a new one
 	b.notifService.NotifyNewSubscription(ctx, *sub)
 	return
 }
 
-func handleSubCreationCancellation(ctx context.Context, b *telegramBot, update *models.Update) bool {
+// askSubConfirmation shows the subscription to be saved, with editing actions when it already exists
+func (b *telegramBot) askSubConfirmation(ctx context.Context, userID int64, data *fsm.SubscriptionCreationFlowData) {
+	sub := parseFlowData(data)
+	labName, unknownLab := b.catalogLab(ctx, flowLabDemand(data))
+	text := presentation.AskSubCreationConfirmationMsg(sub, b.parsingRules.Domains(), b.companyName(sub.CompanyID), labName, unknownLab, b.weekdayLessons(sub.Weekday))
+	kbd := presentation.AskSubCreationConfirmationKbd()
+	if data.EditUUID != nil {
+		text = presentation.AskSubEditConfirmationMsg(sub, b.parsingRules.Domains(), b.companyName(sub.CompanyID), labName, unknownLab, b.weekdayLessons(sub.Weekday))
+		kbd = presentation.AskSubEditConfirmationKbd()
+	}
+
+	b.TryTransition(ctx, userID, fsm.StepAwaitingSubCreationConfirmation, data)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: kbd,
+	})
+}
+
+func handleSubCreationCancellation(ctx context.Context, b *telegramBot, update *models.Update, data fsm.StateData) bool {
 	if update.CallbackQuery == nil {
 		return false
 	}
@@ -396,10 +531,19 @@ func handleSubCreationCancellation(ctx context.Context, b *telegramBot, update *
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
 
+	text := presentation.SubCreationCancelledMsg()
+	if flowData, ok := data.(*fsm.SubscriptionCreationFlowData); ok {
+		switch {
+		case flowData.Forecast:
+			text = presentation.ForecastCancelledMsg()
+		case flowData.EditUUID != nil:
+			text = presentation.SubEditCancelledMsg()
+		}
+	}
 	b.TryTransition(ctx, userID, fsm.StepIdle, nil)
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    userID,
-		Text:      presentation.SubCreationCancelledMsg(),
+		Text:      text,
 		ParseMode: models.ParseModeHTML,
 	})
 
@@ -409,6 +553,7 @@ func handleSubCreationCancellation(ctx context.Context, b *telegramBot, update *
 func parseFlowData(data *fsm.SubscriptionCreationFlowData) *subscription.RequestSubscription {
 	return &subscription.RequestSubscription{
 		UserID:        data.UserID,
+		CompanyID:     data.CompanyID,
 		Type:          data.LabType,
 		LabNumber:     data.LabNumber,
 		LabAuditorium: data.LabAuditorium,
@@ -6,6 +6,7 @@ import (
 
 	"github.com/Ademun/mining-lab-bot/cmd/fsm"
 	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
+	"github.com/Ademun/mining-lab-bot/internal/subscription"
 	"github.com/Ademun/mining-lab-bot/pkg/logger"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -45,7 +46,7 @@ func (b *telegramBot) handleListingSubscriptions(ctx context.Context, api *bot.B
 	b.TryTransition(ctx, userID, fsm.StepAwaitingListingSubsAction, newData)
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userID,
-		Text:        presentation.SubViewMsg(&userSubs[0]),
+		Text:        b.subViewMsg(ctx, &userSubs[0]),
 		ParseMode:   models.ParseModeHTML,
 		ReplyMarkup: presentation.ListSubsKbd(userSubs[0].UUID, 0, len(userSubs)),
 	})
@@ -76,12 +77,22 @@ func (b *telegramBot) handleListingSubsAction(ctx context.Context, api *bot.Bot,
 		return
 	}
 
+	if editedUUID := extractEditedSub(update); editedUUID != nil {
+		for _, sub := range newData.UserSubs {
+			if sub.UUID == *editedUUID {
+				b.startSubEditing(ctx, userID, sub)
+				return
+			}
+		}
+		return
+	}
+
 	newIndex, subUUID := extractListingData(update)
 	if newIndex != nil && *newIndex >= 0 && *newIndex < len(newData.UserSubs) {
 		b.api.EditMessageText(ctx, &bot.EditMessageTextParams{
 			ChatID:    userID,
 			MessageID: messageID,
-			Text:      presentation.SubViewMsg(&newData.UserSubs[*newIndex]),
+			Text:      b.subViewMsg(ctx, &newData.UserSubs[*newIndex]),
 			ParseMode: models.ParseModeHTML,
 		})
 		b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
@@ -128,7 +139,7 @@ func (b *telegramBot) handleListingSubsAction(ctx context.Context, api *bot.Bot,
 		b.EditMessageText(ctx, &bot.EditMessageTextParams{
 			ChatID:    userID,
 			MessageID: messageID,
-			Text:      presentation.SubViewMsg(&newData.UserSubs[newIdx]),
+			Text:      b.subViewMsg(ctx, &newData.UserSubs[newIdx]),
 			ParseMode: models.ParseModeHTML,
 		})
 		b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
@@ -144,3 +155,23 @@ func (b *telegramBot) handleListingSubsAction(ctx context.Context, api *bot.Bot,
 	}
 	b.TryTransition(ctx, userID, fsm.StepAwaitingListingSubsAction, newData)
 }
+
+// startSubEditing prefills the creation steps with the subscription and asks what to change
+func (b *telegramBot) startSubEditing(ctx context.Context, userID int64, sub subscription.ResponseSubscription) {
+	b.askSubConfirmation(ctx, userID, &fsm.SubscriptionCreationFlowData{
+		UserID:        sub.UserID,
+		CompanyID:     sub.CompanyID,
+		LabType:       sub.LabType,
+		LabNumber:     sub.LabNumber,
+		LabAuditorium: sub.LabAuditorium,
+		LabDomain:     sub.LabDomain,
+		Weekday:       sub.Weekday,
+		Lessons:       sub.Lessons,
+		EditUUID:      &sub.UUID,
+	})
+}
+
+func (b *telegramBot) subViewMsg(ctx context.Context, sub *subscription.ResponseSubscription) string {
+	labName, _ := b.catalogLab(ctx, subLabDemand(sub))
+	return presentation.SubViewMsg(sub, b.parsingRules.Domains(), b.companyName(sub.CompanyID), labName, b.weekdayLessons(sub.Weekday))
+}
@@ -3,10 +3,10 @@ package cmd
 import (
 	"context"
 	"log/slog"
+	"time"
 
 	"github.com/Ademun/mining-lab-bot/cmd/fsm"
 	"github.com/Ademun/mining-lab-bot/cmd/internal/presentation"
-	"github.com/Ademun/mining-lab-bot/cmd/internal/utils"
 	"github.com/Ademun/mining-lab-bot/pkg/logger"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -26,7 +26,7 @@ func (b *telegramBot) handleTeacherReport(ctx context.Context, api *bot.Bot, upd
 
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userID,
-		Text:        presentation.AskLabAuditoriumMsg(),
+		Text:        presentation.AskLabAuditoriumMsg(false),
 		ParseMode:   models.ParseModeHTML,
 		ReplyMarkup: presentation.CancelKbd(),
 	})
@@ -150,7 +150,7 @@ func (b *telegramBot) handleTeacherWeekday(ctx context.Context, api *bot.Bot, up
 		ChatID:      userID,
 		Text:        presentation.AskTeacherLessonMsg(),
 		ParseMode:   models.ParseModeHTML,
-		ReplyMarkup: presentation.SelectLessonKbd(utils.DefaultLessons, false),
+		ReplyMarkup: presentation.SelectLessonKbd(b.schedule.WeekdayLessons(time.Weekday(newData.Weekday)), false),
 	})
 }
 
@@ -247,6 +247,7 @@ func (b *telegramBot) handleTeacherSurname(ctx context.Context, api *bot.Bot, up
 			newData.WeekParity,
 			newData.Weekday,
 			newData.LessonNum,
+			b.schedule.WeekdayLessons(time.Weekday(newData.Weekday)),
 			newData.Surname,
 		),
 		ParseMode: models.ParseModeHTML,
@@ -3,7 +3,10 @@ package cmd
 import (
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
+
+	"github.com/Ademun/mining-lab-bot/internal/notification"
 )
 
 func validateLabNumber(labNumberStr string) (int, string) {
@@ -41,3 +44,42 @@ func validateTeacherSurname(surname string) (string, string) {
 
 	return surname, ""
 }
+
+// validateQuietHours parses "HH:MM-HH:MM [time zone]" arguments of the /quiet command
+func validateQuietHours(args []string) (*notification.QuietHours, string) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, "Укажите тихие часы в формате 23:00-08:00"
+	}
+	start, end, ok := strings.Cut(args[0], "-")
+	if !ok {
+		return nil, "Укажите тихие часы в формате 23:00-08:00"
+	}
+	startTime, errStart := time.Parse("15:04", start)
+	endTime, errEnd := time.Parse("15:04", end)
+	if errStart != nil || errEnd != nil {
+		return nil, "Время должно быть в формате ЧЧ:ММ"
+	}
+	if startTime.Equal(endTime) {
+		return nil, "Начало и конец тихих часов должны отличаться"
+	}
+
+	quiet := &notification.QuietHours{
+		Start: startTime.Format("15:04"),
+		End:   endTime.Format("15:04"),
+	}
+	if len(args) == 2 {
+		if _, err := time.LoadLocation(args[1]); err != nil {
+			return nil, "Неизвестный часовой пояс, например: Europe/Moscow"
+		}
+		quiet.Timezone = args[1]
+	}
+	return quiet, ""
+}
+
+func validateDeliveryMode(arg string) (notification.DeliveryMode, string) {
+	switch mode := notification.DeliveryMode(arg); mode {
+	case notification.DeliveryInstant, notification.DeliveryHourly, notification.DeliveryDaily:
+		return mode, ""
+	}
+	return "", "Режим должен быть одним из: instant, hourly, daily"
+}
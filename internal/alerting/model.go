@@ -0,0 +1,35 @@
+package alerting
+
+import (
+	"context"
+	"time"
+)
+
+type AlertKind string
+
+const (
+	// AlertOutage means the source didn't answer a single request for several polls
+	AlertOutage AlertKind = "outage"
+	// AlertParserDrift means too many responses failed to parse, likely the markup changed
+	AlertParserDrift AlertKind = "parser_drift"
+	AlertServiceDrop AlertKind = "service_drop"
+	AlertSlotDrop    AlertKind = "slot_drop"
+)
+
+// Alert describes a problem with a source, or its end when Recovered is set.
+// Current and Baseline depend on the kind: failed polls and the threshold for outages,
+// parse errors and responses for parser drift, current and normal counts for drops
+type Alert struct {
+	Kind      AlertKind
+	CompanyID int
+	// Source is the display name of the company, it may be empty
+	Source    string
+	Current   int
+	Baseline  int
+	Since     time.Time
+	Recovered bool
+}
+
+type Notifier interface {
+	SendAlert(ctx context.Context, alert Alert) error
+}
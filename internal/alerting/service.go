@@ -0,0 +1,173 @@
+package alerting
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"golang.org/x/time/rate"
+)
+
+type Service interface {
+	ObservePoll(ctx context.Context, stats []polling.PollStats)
+}
+
+// conditionKey identifies a firing alert by its kind and source. Sources are keyed by company ID, names may be empty or repeat
+type conditionKey struct {
+	kind      AlertKind
+	companyID int
+}
+
+// condition is a firing alert
+type condition struct {
+	since    time.Time
+	lastSent time.Time
+}
+
+// sourceState is what the rules remember about a source between polls
+type sourceState struct {
+	failedPolls     int
+	serviceBaseline int
+	slotBaseline    int
+}
+
+// signal is the verdict of a single rule on a poll
+type signal struct {
+	alert  Alert
+	firing bool
+}
+
+type alertingService struct {
+	notifier   Notifier
+	options    config.AlertingConfig
+	limiter    *rate.Limiter
+	sources    map[int]*sourceState
+	conditions map[conditionKey]*condition
+	mu         sync.Mutex
+}
+
+func New(notifier Notifier, opts *config.AlertingConfig) Service {
+	return &alertingService{
+		notifier:   notifier,
+		options:    *opts,
+		limiter:    rate.NewLimiter(rate.Every(opts.MinInterval), 1),
+		sources:    make(map[int]*sourceState),
+		conditions: make(map[conditionKey]*condition),
+		mu:         sync.Mutex{},
+	}
+}
+
+func (s *alertingService) ObservePoll(ctx context.Context, stats []polling.PollStats) {
+	for _, alert := range s.observe(stats, time.Now()) {
+		if err := s.notifier.SendAlert(ctx, alert); err != nil {
+			slog.Error("Failed to send alert", "error", err, "alert", alert, "service", logger.ServiceAlerting)
+			continue
+		}
+		slog.Info("Alert sent", "alert", alert, "service", logger.ServiceAlerting)
+	}
+}
+
+// observe runs the rules on a poll and returns the alerts to send
+func (s *alertingService) observe(stats []polling.PollStats, now time.Time) []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alerts := make([]Alert, 0)
+	for _, sourceStats := range stats {
+		state, ok := s.sources[sourceStats.CompanyID]
+		if !ok {
+			state = &sourceState{}
+			s.sources[sourceStats.CompanyID] = state
+		}
+		for _, sig := range evaluate(state, sourceStats, &s.options) {
+			if alert, ok := s.update(sig, now); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts
+}
+
+// update applies a verdict to its condition. Firing alerts go out once and then every RepeatInterval,
+// recoveries go out only for alerts the admin has seen
+func (s *alertingService) update(sig signal, now time.Time) (Alert, bool) {
+	key := conditionKey{kind: sig.alert.Kind, companyID: sig.alert.CompanyID}
+	cond, ok := s.conditions[key]
+	if !sig.firing {
+		if !ok {
+			return Alert{}, false
+		}
+		delete(s.conditions, key)
+		if cond.lastSent.IsZero() {
+			return Alert{}, false
+		}
+		alert := sig.alert
+		alert.Since = cond.since
+		alert.Recovered = true
+		return alert, true
+	}
+
+	if !ok {
+		cond = &condition{since: now}
+		s.conditions[key] = cond
+	}
+	if !cond.lastSent.IsZero() && (s.options.RepeatInterval <= 0 || now.Sub(cond.lastSent) < s.options.RepeatInterval) {
+		return Alert{}, false
+	}
+	if !s.limiter.AllowN(now, 1) {
+		slog.Warn("Alert suppressed by rate limit", "alert", sig.alert, "service", logger.ServiceAlerting)
+		return Alert{}, false
+	}
+	cond.lastSent = now
+	alert := sig.alert
+	alert.Since = cond.since
+	return alert, true
+}
+
+// evaluate runs every rule on a poll of the source and moves the source baselines
+func evaluate(state *sourceState, stats polling.PollStats, opts *config.AlertingConfig) []signal {
+	failed := stats.Responses == 0 && stats.FetchErrors > 0
+	if failed {
+		state.failedPolls++
+	} else {
+		state.failedPolls = 0
+	}
+	signals := []signal{{
+		alert:  Alert{Kind: AlertOutage, CompanyID: stats.CompanyID, Source: stats.Source, Current: state.failedPolls, Baseline: opts.FetchFailureThreshold},
+		firing: opts.FetchFailureThreshold > 0 && state.failedPolls >= opts.FetchFailureThreshold,
+	}}
+	// Nothing else can be judged without responses
+	if failed {
+		return signals
+	}
+
+	if opts.ParseErrorRatio > 0 && stats.Responses >= max(opts.MinResponses, 1) {
+		ratio := float64(stats.ParseErrors) / float64(stats.Responses)
+		signals = append(signals, signal{
+			alert:  Alert{Kind: AlertParserDrift, CompanyID: stats.CompanyID, Source: stats.Source, Current: stats.ParseErrors, Baseline: stats.Responses},
+			firing: ratio >= opts.ParseErrorRatio,
+		})
+	}
+
+	signals = append(signals,
+		dropSignal(AlertServiceDrop, stats, stats.ServiceIDs, &state.serviceBaseline, opts.ServiceDropRatio, opts.MinBaseline),
+		dropSignal(AlertSlotDrop, stats, stats.Slots, &state.slotBaseline, opts.SlotDropRatio, opts.MinBaseline),
+	)
+	return signals
+}
+
+// dropSignal fires when the count shrank too much against the baseline.
+// The baseline follows the count while it's normal and stays put while the drop lasts
+func dropSignal(kind AlertKind, stats polling.PollStats, current int, baseline *int, ratio float64, minBaseline int) signal {
+	sig := signal{alert: Alert{Kind: kind, CompanyID: stats.CompanyID, Source: stats.Source, Current: current, Baseline: *baseline}}
+	if ratio > 0 && *baseline >= max(minBaseline, 1) && float64(current) < float64(*baseline)*(1-ratio) {
+		sig.firing = true
+		return sig
+	}
+	*baseline = current
+	return sig
+}
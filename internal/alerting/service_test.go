@@ -0,0 +1,159 @@
+package alerting
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig() *config.AlertingConfig {
+	return &config.AlertingConfig{
+		FetchFailureThreshold: 3,
+		ParseErrorRatio:       0.5,
+		MinResponses:          3,
+		ServiceDropRatio:      0.5,
+		SlotDropRatio:         0.9,
+		MinBaseline:           5,
+		RepeatInterval:        time.Hour,
+		MinInterval:           time.Minute,
+	}
+}
+
+func healthyPoll() polling.PollStats {
+	return polling.PollStats{Source: "dikidi", ServiceIDs: 10, Responses: 10, Slots: 100}
+}
+
+func failedPoll() polling.PollStats {
+	return polling.PollStats{Source: "dikidi", FetchErrors: 10}
+}
+
+func TestObserve(t *testing.T) {
+	type testCase struct {
+		polls    []polling.PollStats
+		expected []Alert
+	}
+
+	tests := []testCase{
+		{polls: []polling.PollStats{healthyPoll(), healthyPoll()}, expected: nil},
+		{polls: []polling.PollStats{failedPoll(), failedPoll()}, expected: nil},
+		// Third failed poll in a row is an outage, and it's reported once
+		{
+			polls:    []polling.PollStats{failedPoll(), failedPoll(), failedPoll(), failedPoll(), healthyPoll()},
+			expected: []Alert{{Kind: AlertOutage, Current: 3, Baseline: 3}, {Kind: AlertOutage, Current: 0, Baseline: 3, Recovered: true}},
+		},
+		// Recovering from an unreported outage is not news
+		{polls: []polling.PollStats{failedPoll(), failedPoll(), healthyPoll()}, expected: nil},
+		{
+			polls: []polling.PollStats{
+				healthyPoll(),
+				{Source: "dikidi", ServiceIDs: 10, Responses: 10, ParseErrors: 6, Slots: 100},
+			},
+			expected: []Alert{{Kind: AlertParserDrift, Current: 6, Baseline: 10}},
+		},
+		// Too few responses to judge the parser
+		{polls: []polling.PollStats{{Source: "dikidi", ServiceIDs: 2, Responses: 2, ParseErrors: 2}}, expected: nil},
+		// The baseline stays put while the drop lasts
+		{
+			polls: []polling.PollStats{
+				healthyPoll(),
+				{Source: "dikidi", ServiceIDs: 4, Responses: 4, Slots: 100},
+				{Source: "dikidi", ServiceIDs: 3, Responses: 3, Slots: 100},
+				healthyPoll(),
+			},
+			expected: []Alert{{Kind: AlertServiceDrop, Current: 4, Baseline: 10}, {Kind: AlertServiceDrop, Current: 10, Baseline: 10, Recovered: true}},
+		},
+		{
+			polls: []polling.PollStats{
+				healthyPoll(),
+				{Source: "dikidi", ServiceIDs: 10, Responses: 10, Slots: 5},
+			},
+			expected: []Alert{{Kind: AlertSlotDrop, Current: 5, Baseline: 100}},
+		},
+		// Slots running out gradually are not a drop
+		{
+			polls: []polling.PollStats{
+				healthyPoll(),
+				{Source: "dikidi", ServiceIDs: 10, Responses: 10, Slots: 50},
+				{Source: "dikidi", ServiceIDs: 10, Responses: 10, Slots: 20},
+				{Source: "dikidi", ServiceIDs: 10, Responses: 10, Slots: 10},
+			},
+			expected: nil,
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_observe_%d", i), func(t *testing.T) {
+			service := New(nil, testConfig()).(*alertingService)
+			start := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+
+			var alerts []Alert
+			for idx, poll := range tCase.polls {
+				for _, alert := range service.observe([]polling.PollStats{poll}, start.Add(time.Duration(idx)*time.Minute)) {
+					alert.Source = ""
+					alert.Since = time.Time{}
+					alerts = append(alerts, alert)
+				}
+			}
+			assert.Equal(t, tCase.expected, alerts)
+		})
+	}
+}
+
+func TestObserveRepeats(t *testing.T) {
+	type testCase struct {
+		repeatInterval time.Duration
+		expected       int
+	}
+
+	tests := []testCase{
+		{repeatInterval: 0, expected: 1},
+		{repeatInterval: 30 * time.Minute, expected: 3},
+		{repeatInterval: 2 * time.Hour, expected: 1},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_observe_repeats_%d", i), func(t *testing.T) {
+			opts := testConfig()
+			opts.FetchFailureThreshold = 1
+			opts.RepeatInterval = tCase.repeatInterval
+			service := New(nil, opts).(*alertingService)
+			start := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+
+			sent := 0
+			// An outage lasting an hour and a half, polled every 5 minutes
+			for at := start; at.Before(start.Add(90 * time.Minute)); at = at.Add(5 * time.Minute) {
+				sent += len(service.observe([]polling.PollStats{failedPoll()}, at))
+			}
+			assert.Equal(t, tCase.expected, sent)
+		})
+	}
+}
+
+func TestObserveUnnamedSources(t *testing.T) {
+	opts := testConfig()
+	opts.FetchFailureThreshold = 1
+	opts.MinInterval = 0
+	service := New(nil, opts).(*alertingService)
+	start := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	// Companies without names are still separate sources
+	first := polling.PollStats{CompanyID: 550001, FetchErrors: 10}
+	second := polling.PollStats{CompanyID: 550002, FetchErrors: 10}
+	alerts := service.observe([]polling.PollStats{first, second}, start)
+	if assert.Len(t, alerts, 2) {
+		assert.Equal(t, 550001, alerts[0].CompanyID)
+		assert.Equal(t, 550002, alerts[1].CompanyID)
+	}
+
+	// One of them recovering doesn't end the outage of the other
+	second.FetchErrors, second.Responses = 0, 10
+	alerts = service.observe([]polling.PollStats{first, second}, start.Add(time.Minute))
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, 550002, alerts[0].CompanyID)
+		assert.True(t, alerts[0].Recovered)
+	}
+}
@@ -0,0 +1,173 @@
+package calendar
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+)
+
+const dateLayout = "2006-01-02"
+
+type DayKind int
+
+const (
+	DayTeaching DayKind = iota
+	DayWeekend
+	DayHoliday
+	DayExams
+	// DayOutOfSemester is any day before the semester start or after its end
+	DayOutOfSemester
+)
+
+// Calendar answers questions about the academic semester. It has no mutable state,
+// so the answer for a date never depends on when the question is asked
+type Calendar interface {
+	// WeekNumber returns 1 or 2, the parity of the date's week in the semester
+	WeekNumber(date time.Time) int
+	DayKind(date time.Time) DayKind
+	IsTeachingDay(date time.Time) bool
+	// IsDayOff reports holidays and Sundays, days known to have no labs whatever the semester
+	IsDayOff(date time.Time) bool
+}
+
+type dateRange struct {
+	name string
+	from time.Time
+	to   time.Time
+}
+
+func (r *dateRange) contains(day time.Time) bool {
+	return !day.Before(r.from) && !day.After(r.to)
+}
+
+type academicCalendar struct {
+	semesterStart time.Time
+	semesterEnd   *time.Time
+	startingWeek  int
+	holidays      []dateRange
+	exams         []dateRange
+	location      *time.Location
+}
+
+// New parses the calendar dates in the campus location
+func New(opts *config.CalendarConfig, loc *time.Location) (Calendar, error) {
+	c := &academicCalendar{
+		startingWeek: opts.StartingWeek,
+		location:     loc,
+	}
+	if c.startingWeek != 2 {
+		c.startingWeek = 1
+	}
+
+	start, err := time.ParseInLocation(dateLayout, opts.SemesterStart, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid semester start: %w", err)
+	}
+	c.semesterStart = start
+
+	if opts.SemesterEnd != "" {
+		end, err := time.ParseInLocation(dateLayout, opts.SemesterEnd, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semester end: %w", err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("semester ends before it starts")
+		}
+		c.semesterEnd = &end
+	}
+
+	if c.holidays, err = parseRanges(opts.Holidays, loc); err != nil {
+		return nil, fmt.Errorf("invalid holidays: %w", err)
+	}
+	if c.exams, err = parseRanges(opts.Exams, loc); err != nil {
+		return nil, fmt.Errorf("invalid exams: %w", err)
+	}
+	return c, nil
+}
+
+func parseRanges(ranges []config.DateRangeConfig, loc *time.Location) ([]dateRange, error) {
+	result := make([]dateRange, 0, len(ranges))
+	for _, r := range ranges {
+		from, err := time.ParseInLocation(dateLayout, r.From, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		to, err := time.ParseInLocation(dateLayout, r.To, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		if to.Before(from) {
+			return nil, fmt.Errorf("%s: range ends before it starts", r.Name)
+		}
+		result = append(result, dateRange{name: r.Name, from: from, to: to})
+	}
+	return result, nil
+}
+
+func (c *academicCalendar) WeekNumber(date time.Time) int {
+	weeks := weeksBetween(weekMonday(c.semesterStart), weekMonday(c.day(date)))
+	if weeks%2 == 0 {
+		return c.startingWeek
+	}
+	return 3 - c.startingWeek
+}
+
+func (c *academicCalendar) DayKind(date time.Time) DayKind {
+	day := c.day(date)
+	if day.Before(c.semesterStart) || (c.semesterEnd != nil && day.After(*c.semesterEnd)) {
+		return DayOutOfSemester
+	}
+	for _, r := range c.exams {
+		if r.contains(day) {
+			return DayExams
+		}
+	}
+	for _, r := range c.holidays {
+		if r.contains(day) {
+			return DayHoliday
+		}
+	}
+	if day.Weekday() == time.Sunday {
+		return DayWeekend
+	}
+	return DayTeaching
+}
+
+func (c *academicCalendar) IsTeachingDay(date time.Time) bool {
+	return c.DayKind(date) == DayTeaching
+}
+
+func (c *academicCalendar) IsDayOff(date time.Time) bool {
+	day := c.day(date)
+	if day.Weekday() == time.Sunday {
+		return true
+	}
+	for _, r := range c.holidays {
+		if r.contains(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// day returns the start of the date's day in the calendar location
+func (c *academicCalendar) day(date time.Time) time.Time {
+	local := date.In(c.location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+}
+
+func weekMonday(day time.Time) time.Time {
+	weekday := int(day.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return time.Date(day.Year(), day.Month(), day.Day()-(weekday-1), 0, 0, 0, 0, day.Location())
+}
+
+// weeksBetween counts whole weeks between two Mondays by calendar days, so DST shifts don't matter
+func weeksBetween(from, to time.Time) int {
+	fromDays := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+	toDays := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+	return int((toDays - fromDays) / 7)
+}
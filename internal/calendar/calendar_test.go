@@ -0,0 +1,139 @@
+package calendar
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeekNumber(t *testing.T) {
+	type testCase struct {
+		semesterStart string
+		startingWeek  int
+		date          string
+		expectedWeek  int
+	}
+
+	tests := []testCase{
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-11-17", expectedWeek: 1},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-11-20", expectedWeek: 1},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-11-23", expectedWeek: 1},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-11-24", expectedWeek: 2},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-11-30", expectedWeek: 2},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-12-01", expectedWeek: 1},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-12-08", expectedWeek: 2},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-12-15", expectedWeek: 1},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-12-20", expectedWeek: 1},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-12-22", expectedWeek: 2},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2026-01-05", expectedWeek: 2},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2026-01-12", expectedWeek: 1},
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2026-01-19", expectedWeek: 2},
+		{semesterStart: "2025-11-19", startingWeek: 2, date: "2025-11-17", expectedWeek: 2},
+		{semesterStart: "2025-11-19", startingWeek: 2, date: "2025-11-24", expectedWeek: 1},
+		{semesterStart: "2025-11-19", startingWeek: 2, date: "2025-12-01", expectedWeek: 2},
+		// Before the semester start the parity still alternates
+		{semesterStart: "2025-11-17", startingWeek: 1, date: "2025-11-10", expectedWeek: 2},
+		// The autumn DST switch must not shift weeks
+		{semesterStart: "2025-09-01", startingWeek: 1, date: "2025-11-03", expectedWeek: 2},
+	}
+
+	campus := testutil.Campus(t)
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_week_number_%d", i), func(t *testing.T) {
+			cal, err := New(&config.CalendarConfig{SemesterStart: tCase.semesterStart, StartingWeek: tCase.startingWeek}, campus)
+			require.NoError(t, err)
+			date, err := time.ParseInLocation(dateLayout, tCase.date, campus)
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expectedWeek, cal.WeekNumber(date))
+		})
+	}
+}
+
+func TestDayKind(t *testing.T) {
+	campus := testutil.Campus(t)
+	cal, err := New(&config.CalendarConfig{
+		SemesterStart: "2025-09-01",
+		SemesterEnd:   "2025-12-28",
+		StartingWeek:  1,
+		Holidays:      []config.DateRangeConfig{{Name: "Holiday", From: "2025-11-03", To: "2025-11-04"}},
+		Exams:         []config.DateRangeConfig{{Name: "Exams", From: "2025-12-22", To: "2026-01-25"}},
+	}, campus)
+	require.NoError(t, err)
+
+	type testCase struct {
+		date     string
+		expected DayKind
+		dayOff   bool
+	}
+
+	tests := []testCase{
+		{date: "2025-08-31", expected: DayOutOfSemester, dayOff: true},
+		{date: "2025-09-01", expected: DayTeaching},
+		{date: "2025-09-06", expected: DayTeaching},
+		{date: "2025-09-07", expected: DayWeekend, dayOff: true},
+		{date: "2025-11-03", expected: DayHoliday, dayOff: true},
+		{date: "2025-11-04", expected: DayHoliday, dayOff: true},
+		{date: "2025-11-05", expected: DayTeaching},
+		{date: "2025-12-22", expected: DayExams},
+		{date: "2025-12-29", expected: DayOutOfSemester},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_day_kind_%d", i), func(t *testing.T) {
+			date, err := time.ParseInLocation(dateLayout, tCase.date, campus)
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, cal.DayKind(date.Add(13*time.Hour)))
+			assert.Equal(t, tCase.dayOff, cal.IsDayOff(date.Add(13*time.Hour)))
+		})
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []config.CalendarConfig{
+		{SemesterStart: ""},
+		{SemesterStart: "2025-09-01", SemesterEnd: "2025-08-01"},
+		{SemesterStart: "2025-09-01", Holidays: []config.DateRangeConfig{{From: "2025-11-04", To: "2025-11-03"}}},
+		{SemesterStart: "2025-09-01", Exams: []config.DateRangeConfig{{From: "tomorrow", To: "2025-11-03"}}},
+	}
+
+	for i, opts := range tests {
+		t.Run(fmt.Sprintf("test_new_rejects_invalid_config_%d", i), func(t *testing.T) {
+			_, err := New(&opts, time.UTC)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCalendarIgnoresHostTimezone(t *testing.T) {
+	campus := testutil.Campus(t)
+	cal, err := New(&config.CalendarConfig{SemesterStart: "2025-11-17", StartingWeek: 1}, campus)
+	require.NoError(t, err)
+
+	type testCase struct {
+		hostTimezone string
+		// 00:30 on Monday 2025-11-24 at the campus, still Sunday in UTC and west of it
+		moment       time.Time
+		expectedWeek int
+		expectedKind DayKind
+	}
+
+	moment := time.Date(2025, 11, 24, 0, 30, 0, 0, campus)
+	tests := []testCase{
+		{hostTimezone: "UTC", moment: moment, expectedWeek: 2, expectedKind: DayTeaching},
+		{hostTimezone: "America/Los_Angeles", moment: moment.UTC(), expectedWeek: 2, expectedKind: DayTeaching},
+		{hostTimezone: "Asia/Tokyo", moment: moment.Add(-time.Hour).UTC(), expectedWeek: 1, expectedKind: DayWeekend},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_host_timezone_%d", i), func(t *testing.T) {
+			testutil.SetHostTimezone(t, tCase.hostTimezone)
+			assert.Equal(t, tCase.expectedWeek, cal.WeekNumber(tCase.moment.Local()))
+			assert.Equal(t, tCase.expectedKind, cal.DayKind(tCase.moment.Local()))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package catalog
+
+import (
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+)
+
+// Entry is a service ever listed on a booking page. RemovedAt is set while the service is off the page
+type Entry struct {
+	CompanyID int        `db:"company_id"`
+	ServiceID int        `db:"service_id"`
+	Name      string     `db:"name"`
+	FirstSeen time.Time  `db:"first_seen"`
+	LastSeen  time.Time  `db:"last_seen"`
+	RemovedAt *time.Time `db:"removed_at"`
+}
+
+// Lab is a lab ever parsed from a booking page
+type Lab struct {
+	CompanyID  int               `db:"company_id"`
+	Type       polling.LabType   `db:"lab_type"`
+	Number     int               `db:"lab_number"`
+	Auditorium int               `db:"lab_auditorium"`
+	Domain     polling.LabDomain `db:"lab_domain"`
+	Name       string            `db:"lab_name"`
+	FirstSeen  time.Time         `db:"first_seen"`
+}
+
+type labKey struct {
+	companyID  int
+	labType    polling.LabType
+	number     int
+	auditorium int
+	domain     polling.LabDomain
+}
+
+func (l *Lab) key() labKey {
+	return labKey{companyID: l.CompanyID, labType: l.Type, number: l.Number, auditorium: l.Auditorium, domain: l.Domain}
+}
+
+// slot returns the lab as a slot without times, so that it can be matched against demands
+func (l *Lab) slot() polling.Slot {
+	return polling.Slot{
+		CompanyID:  l.CompanyID,
+		Type:       l.Type,
+		Number:     l.Number,
+		Auditorium: l.Auditorium,
+		Domain:     l.Domain,
+		Name:       l.Name,
+	}
+}
@@ -0,0 +1,187 @@
+package catalog
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+)
+
+type Service interface {
+	polling.Catalog
+	// Labs returns the known labs of the type, ordered by number and auditorium. A nil company means any company
+	Labs(ctx context.Context, companyID *int, labType polling.LabType) ([]Lab, error)
+	// FindLab returns a known lab the demand matches, preferring the named ones. Nil when there is none
+	FindLab(ctx context.Context, lab polling.LabDemand) (*Lab, error)
+}
+
+type catalogService struct {
+	repo Repo
+	mu   sync.Mutex
+	// labs are loaded on first use and kept in sync with the repo, so polling writes only the unseen ones
+	labs map[labKey]Lab
+}
+
+func New(repo Repo) Service {
+	return &catalogService{repo: repo}
+}
+
+func (s *catalogService) Sync(ctx context.Context, companyID int, services []polling.CatalogService) (added, returned, removed []polling.CatalogService, err error) {
+	known, err := s.repo.FindByCompany(ctx, companyID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := time.Now()
+	listed, removedEntries := make([]Entry, 0, len(services)), make([]Entry, 0)
+	knownByID := make(map[int]Entry, len(known))
+	for _, entry := range known {
+		knownByID[entry.ServiceID] = entry
+	}
+	listedIDs := make(map[int]bool, len(services))
+	for _, service := range services {
+		// A service may be listed in several categories
+		if listedIDs[service.ServiceID] {
+			continue
+		}
+		listedIDs[service.ServiceID] = true
+		listed = append(listed, Entry{CompanyID: companyID, ServiceID: service.ServiceID, Name: service.Name, FirstSeen: now, LastSeen: now})
+
+		entry, ok := knownByID[service.ServiceID]
+		switch {
+		case !ok:
+			added = append(added, service)
+		case entry.RemovedAt != nil:
+			returned = append(returned, service)
+		}
+	}
+	for _, entry := range known {
+		if entry.RemovedAt == nil && !listedIDs[entry.ServiceID] {
+			removedEntries = append(removedEntries, entry)
+			removed = append(removed, polling.CatalogService{CompanyID: companyID, ServiceID: entry.ServiceID, Name: entry.Name})
+		}
+	}
+
+	if err := s.repo.Save(ctx, listed, removedEntries, now); err != nil {
+		return nil, nil, nil, err
+	}
+	// Nothing to compare the first list with, every service would look new
+	if len(known) == 0 {
+		return nil, nil, nil, nil
+	}
+	return added, returned, removed, nil
+}
+
+func (s *catalogService) AddLabs(ctx context.Context, slots []polling.Slot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLabs(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	fresh := make(map[labKey]Lab)
+	for _, slot := range slots {
+		lab := Lab{
+			CompanyID:  slot.CompanyID,
+			Type:       slot.Type,
+			Number:     slot.Number,
+			Auditorium: slot.Auditorium,
+			Domain:     slot.Domain,
+			Name:       slot.Name,
+			FirstSeen:  now,
+		}
+		key := lab.key()
+		known, ok := fresh[key]
+		if !ok {
+			known, ok = s.labs[key]
+		}
+		if ok && (known.Name == lab.Name || lab.Name == "") {
+			continue
+		}
+		if ok {
+			lab.FirstSeen = known.FirstSeen
+		}
+		fresh[key] = lab
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	labs := make([]Lab, 0, len(fresh))
+	for _, lab := range fresh {
+		labs = append(labs, lab)
+	}
+	if err := s.repo.SaveLabs(ctx, labs); err != nil {
+		return err
+	}
+	for key, lab := range fresh {
+		s.labs[key] = lab
+	}
+	return nil
+}
+
+func (s *catalogService) Labs(ctx context.Context, companyID *int, labType polling.LabType) ([]Lab, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLabs(ctx); err != nil {
+		return nil, err
+	}
+
+	labs := make([]Lab, 0)
+	for _, lab := range s.labs {
+		if lab.Type != labType || (companyID != nil && *companyID != lab.CompanyID) {
+			continue
+		}
+		labs = append(labs, lab)
+	}
+	slices.SortFunc(labs, compareLabs)
+	return labs, nil
+}
+
+func (s *catalogService) FindLab(ctx context.Context, demand polling.LabDemand) (*Lab, error) {
+	labs, err := s.Labs(ctx, demand.CompanyID, demand.Type)
+	if err != nil {
+		return nil, err
+	}
+	var found *Lab
+	for _, lab := range labs {
+		if !demand.Matches(lab.slot()) {
+			continue
+		}
+		if lab.Name != "" {
+			return &lab, nil
+		}
+		if found == nil {
+			found = &lab
+		}
+	}
+	return found, nil
+}
+
+// loadLabs reads the catalog once, the caller must hold the lock
+func (s *catalogService) loadLabs(ctx context.Context) error {
+	if s.labs != nil {
+		return nil
+	}
+	labs, err := s.repo.FindLabs(ctx)
+	if err != nil {
+		return err
+	}
+	s.labs = make(map[labKey]Lab, len(labs))
+	for _, lab := range labs {
+		s.labs[lab.key()] = lab
+	}
+	return nil
+}
+
+func compareLabs(a, b Lab) int {
+	return cmp.Or(
+		cmp.Compare(a.Number, b.Number),
+		cmp.Compare(a.Auditorium, b.Auditorium),
+		cmp.Compare(a.Domain, b.Domain),
+		cmp.Compare(a.CompanyID, b.CompanyID),
+	)
+}
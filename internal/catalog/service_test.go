@@ -0,0 +1,105 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+
+	service := New(NewRepo(db))
+	company := 550001
+	electricity := polling.CatalogService{CompanyID: company, ServiceID: 101, Name: "Выполнение лабораторных работ. Электричество"}
+	mechanics := polling.CatalogService{CompanyID: company, ServiceID: 102, Name: "Защита лабораторных работ. Механика"}
+	virtual := polling.CatalogService{CompanyID: company, ServiceID: 103, Name: "Выполнение лабораторных работ. Виртуальная лаборатория"}
+
+	// The first list has nothing to compare with
+	added, returned, removed, err := service.Sync(ctx, company, []polling.CatalogService{electricity, mechanics})
+	require.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Empty(t, returned)
+	assert.Empty(t, removed)
+
+	added, returned, removed, err = service.Sync(ctx, company, []polling.CatalogService{electricity, virtual, virtual})
+	require.NoError(t, err)
+	assert.Equal(t, []polling.CatalogService{virtual}, added)
+	assert.Empty(t, returned)
+	assert.Equal(t, []polling.CatalogService{mechanics}, removed)
+
+	// Removed services stay removed until they are listed again
+	added, returned, removed, err = service.Sync(ctx, company, []polling.CatalogService{electricity, virtual})
+	require.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Empty(t, returned)
+	assert.Empty(t, removed)
+
+	added, returned, removed, err = service.Sync(ctx, company, []polling.CatalogService{electricity, mechanics, virtual})
+	require.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Equal(t, []polling.CatalogService{mechanics}, returned)
+	assert.Empty(t, removed)
+
+	// Other companies have catalogs of their own
+	added, _, _, err = service.Sync(ctx, 550002, []polling.CatalogService{{CompanyID: 550002, ServiceID: 201, Name: "Консультации"}})
+	require.NoError(t, err)
+	assert.Empty(t, added)
+}
+
+func TestLabs(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+
+	company := 550001
+	slots := []polling.Slot{
+		{CompanyID: company, Type: polling.LabTypePerformance, Number: 7, Auditorium: 233, Name: "Маятник Максвелла"},
+		{CompanyID: company, Type: polling.LabTypePerformance, Number: 7, Auditorium: 233},
+		{CompanyID: company, Type: polling.LabTypePerformance, Number: 7, Auditorium: 234},
+		{CompanyID: company, Type: polling.LabTypePerformance, Number: 3, Auditorium: 233},
+		{CompanyID: company, Type: polling.LabTypeDefence, Number: 5, Domain: polling.LabDomain("mechanics")},
+	}
+	require.NoError(t, New(NewRepo(db)).AddLabs(ctx, slots))
+	// A name missing from a later response doesn't erase the known one
+	require.NoError(t, New(NewRepo(db)).AddLabs(ctx, slots[1:2]))
+
+	// A fresh service reads what the previous one stored
+	service := New(NewRepo(db))
+	labs, err := service.Labs(ctx, &company, polling.LabTypePerformance)
+	require.NoError(t, err)
+	require.Len(t, labs, 3)
+	assert.Equal(t, 3, labs[0].Number)
+	assert.Equal(t, "Маятник Максвелла", labs[1].Name)
+	assert.Equal(t, 234, labs[2].Auditorium)
+
+	auditorium := 234
+	tests := []struct {
+		demand polling.LabDemand
+		name   string
+		found  bool
+	}{
+		{demand: polling.LabDemand{Type: polling.LabTypePerformance, Number: 7}, name: "Маятник Максвелла", found: true},
+		{demand: polling.LabDemand{Type: polling.LabTypePerformance, Number: 7, Auditorium: &auditorium}, found: true},
+		{demand: polling.LabDemand{Type: polling.LabTypePerformance, Number: 8}},
+		{demand: polling.LabDemand{CompanyID: &auditorium, Type: polling.LabTypeDefence, Number: 5}},
+		{demand: polling.LabDemand{Type: polling.LabTypeDefence, Number: 5}, found: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("test_find_lab_%d", i), func(t *testing.T) {
+			lab, err := service.FindLab(ctx, tt.demand)
+			require.NoError(t, err)
+			if !tt.found {
+				assert.Nil(t, lab)
+				return
+			}
+			require.NotNil(t, lab)
+			assert.Equal(t, tt.name, lab.Name)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package catalog
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/errs"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repo interface {
+	FindByCompany(ctx context.Context, companyID int) ([]Entry, error)
+	// Save stores the listed entries, bringing back the removed ones, and marks the services gone from the page as removed
+	Save(ctx context.Context, listed []Entry, removed []Entry, at time.Time) error
+	FindLabs(ctx context.Context) ([]Lab, error)
+	// SaveLabs stores the labs, keeping the first time they were seen and updating the names
+	SaveLabs(ctx context.Context, labs []Lab) error
+}
+
+type catalogRepo struct {
+	db *sqlx.DB
+}
+
+func NewRepo(db *sqlx.DB) Repo {
+	return &catalogRepo{db: db}
+}
+
+func (r *catalogRepo) FindByCompany(ctx context.Context, companyID int) ([]Entry, error) {
+	query := `select * from service_catalog where company_id = ? order by service_id`
+	var entries []Entry
+	if err := r.db.SelectContext(ctx, &entries, query, companyID); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindByCompany", Query: query, Err: err}
+	}
+	return entries, nil
+}
+
+const catalogUpsert = `
+insert into service_catalog
+(company_id, service_id, name, first_seen, last_seen)
+values
+(:company_id, :service_id, :name, :first_seen, :last_seen)
+on conflict (company_id, service_id) do update
+set name = excluded.name, last_seen = excluded.last_seen, removed_at = null`
+
+func (r *catalogRepo) Save(ctx context.Context, listed []Entry, removed []Entry, at time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errs.ErrBeginTransaction
+	}
+	defer tx.Rollback()
+
+	for _, entry := range listed {
+		if _, err := tx.NamedExecContext(ctx, catalogUpsert, entry); err != nil {
+			return &errs.ErrQueryExecution{Operation: "Save", Query: catalogUpsert, Err: err}
+		}
+	}
+	query := `update service_catalog set removed_at = ? where company_id = ? and service_id = ?`
+	for _, entry := range removed {
+		if _, err := tx.ExecContext(ctx, query, at, entry.CompanyID, entry.ServiceID); err != nil {
+			return &errs.ErrQueryExecution{Operation: "Save", Query: query, Err: err}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *catalogRepo) FindLabs(ctx context.Context) ([]Lab, error) {
+	query := `select * from lab_catalog order by company_id, lab_type, lab_number, lab_auditorium, lab_domain`
+	var labs []Lab
+	if err := r.db.SelectContext(ctx, &labs, query); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindLabs", Query: query, Err: err}
+	}
+	return labs, nil
+}
+
+const labUpsert = `
+insert into lab_catalog
+(company_id, lab_type, lab_number, lab_auditorium, lab_domain, lab_name, first_seen)
+values
+(:company_id, :lab_type, :lab_number, :lab_auditorium, :lab_domain, :lab_name, :first_seen)
+on conflict (company_id, lab_type, lab_number, lab_auditorium, lab_domain) do update
+set lab_name = excluded.lab_name`
+
+func (r *catalogRepo) SaveLabs(ctx context.Context, labs []Lab) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errs.ErrBeginTransaction
+	}
+	defer tx.Rollback()
+
+	for _, lab := range labs {
+		if _, err := tx.NamedExecContext(ctx, labUpsert, lab); err != nil {
+			return &errs.ErrQueryExecution{Operation: "SaveLabs", Query: labUpsert, Err: err}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,172 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+)
+
+const checkTimeout = 5 * time.Second
+
+// Check reports whether a component the bot depends on is reachable
+type Check func(ctx context.Context) error
+
+type PollingStatus interface {
+	Status() polling.Status
+}
+
+type ComponentStatus struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type PollerStatus struct {
+	OK                bool       `json:"ok"`
+	LastPoll          *time.Time `json:"last_poll,omitempty"`
+	LastServiceUpdate *time.Time `json:"last_service_update,omitempty"`
+	FetchRate         string     `json:"fetch_rate"`
+}
+
+type Report struct {
+	OK         bool              `json:"ok"`
+	Components []ComponentStatus `json:"components,omitempty"`
+	Poller     *PollerStatus     `json:"poller,omitempty"`
+}
+
+// Checker serves /healthz and /readyz. Components and the poller are registered as they start,
+// so the endpoints can be served before the bot is fully up
+type Checker struct {
+	stallTimeout time.Duration
+	names        []string
+	checks       map[string]Check
+	poller       PollingStatus
+	mu           sync.RWMutex
+}
+
+func NewChecker(stallTimeout time.Duration) *Checker {
+	return &Checker{
+		stallTimeout: stallTimeout,
+		checks:       make(map[string]Check),
+	}
+}
+
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+func (c *Checker) SetPoller(poller PollingStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.poller = poller
+}
+
+// Healthz is the liveness probe. It fails only when the poller stalls, since restarting the bot
+// doesn't help when Redis or Telegram are down
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	poller := c.pollerStatus(time.Now())
+	report := Report{OK: poller == nil || poller.OK, Poller: poller}
+	writeReport(w, report)
+}
+
+// Readyz is the readiness probe, it checks every registered component along with the poller
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	report := Report{OK: true, Components: c.checkComponents(ctx)}
+	for _, component := range report.Components {
+		report.OK = report.OK && component.OK
+	}
+	report.Poller = c.pollerStatus(time.Now())
+	if report.Poller != nil {
+		report.OK = report.OK && report.Poller.OK
+	}
+	writeReport(w, report)
+}
+
+func (c *Checker) checkComponents(ctx context.Context) []ComponentStatus {
+	c.mu.RLock()
+	names := append([]string(nil), c.names...)
+	checks := make([]Check, len(names))
+	for idx, name := range names {
+		checks[idx] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	statuses := make([]ComponentStatus, len(names))
+	wg := sync.WaitGroup{}
+	for idx, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[idx] = ComponentStatus{Name: name, OK: true}
+			if err := checks[idx](ctx); err != nil {
+				statuses[idx].OK = false
+				statuses[idx].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return statuses
+}
+
+// pollerStatus returns nil until the poller is registered
+func (c *Checker) pollerStatus(now time.Time) *PollerStatus {
+	c.mu.RLock()
+	poller := c.poller
+	c.mu.RUnlock()
+	if poller == nil {
+		return nil
+	}
+
+	status := poller.Status()
+	result := &PollerStatus{
+		OK:        !isStalled(status, now, c.stallTimeout),
+		FetchRate: status.FetchRate.String(),
+	}
+	if !status.LastPoll.IsZero() {
+		result.LastPoll = &status.LastPoll
+	}
+	if !status.LastServiceUpdate.IsZero() {
+		result.LastServiceUpdate = &status.LastServiceUpdate
+	}
+	return result
+}
+
+// isStalled reports whether the poller went too long without a successful poll.
+// Until the first one, the time is counted from the poller start
+func isStalled(status polling.Status, now time.Time, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return false
+	}
+	since := status.LastPoll
+	if since.IsZero() {
+		since = status.StartedAt
+	}
+	if since.IsZero() {
+		return false
+	}
+	return now.Sub(since) > timeout
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("Failed to write health report", "error", err, "service", logger.ServiceHealth)
+	}
+}
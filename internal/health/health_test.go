@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePoller struct {
+	status polling.Status
+}
+
+func (p fakePoller) Status() polling.Status {
+	return p.status
+}
+
+func TestIsStalled(t *testing.T) {
+	now := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	type testCase struct {
+		status   polling.Status
+		timeout  time.Duration
+		expected bool
+	}
+
+	tests := []testCase{
+		{status: polling.Status{}, timeout: time.Minute, expected: false},
+		{status: polling.Status{StartedAt: now.Add(-30 * time.Second)}, timeout: time.Minute, expected: false},
+		// Never polled successfully since the start
+		{status: polling.Status{StartedAt: now.Add(-2 * time.Minute)}, timeout: time.Minute, expected: true},
+		{status: polling.Status{StartedAt: now.Add(-time.Hour), LastPoll: now.Add(-30 * time.Second)}, timeout: time.Minute, expected: false},
+		{status: polling.Status{StartedAt: now.Add(-time.Hour), LastPoll: now.Add(-2 * time.Minute)}, timeout: time.Minute, expected: true},
+		{status: polling.Status{StartedAt: now.Add(-time.Hour)}, timeout: 0, expected: false},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_is_stalled_%d", i), func(t *testing.T) {
+			assert.Equal(t, tCase.expected, isStalled(tCase.status, now, tCase.timeout))
+		})
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	healthyPoller := fakePoller{status: polling.Status{StartedAt: time.Now(), LastPoll: time.Now(), FetchRate: time.Second}}
+	stalledPoller := fakePoller{status: polling.Status{StartedAt: time.Now().Add(-time.Hour)}}
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	passing := func(ctx context.Context) error { return nil }
+
+	type testCase struct {
+		poller          PollingStatus
+		check           Check
+		expectedHealthz int
+		expectedReadyz  int
+	}
+
+	tests := []testCase{
+		{poller: healthyPoller, check: passing, expectedHealthz: http.StatusOK, expectedReadyz: http.StatusOK},
+		// A component outage doesn't call for a restart
+		{poller: healthyPoller, check: failing, expectedHealthz: http.StatusOK, expectedReadyz: http.StatusServiceUnavailable},
+		{poller: stalledPoller, check: passing, expectedHealthz: http.StatusServiceUnavailable, expectedReadyz: http.StatusServiceUnavailable},
+		{poller: nil, check: passing, expectedHealthz: http.StatusOK, expectedReadyz: http.StatusOK},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_endpoints_%d", i), func(t *testing.T) {
+			checker := NewChecker(time.Minute)
+			checker.Register("redis", tCase.check)
+			if tCase.poller != nil {
+				checker.SetPoller(tCase.poller)
+			}
+
+			rec := httptest.NewRecorder()
+			checker.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(t, tCase.expectedHealthz, rec.Code)
+
+			rec = httptest.NewRecorder()
+			checker.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tCase.expectedReadyz, rec.Code)
+
+			var report Report
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			require.Len(t, report.Components, 1)
+			assert.Equal(t, "redis", report.Components[0].Name)
+		})
+	}
+}
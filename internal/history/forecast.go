@@ -0,0 +1,85 @@
+package history
+
+import (
+	"slices"
+	"time"
+)
+
+// takenMargin tells taken times from the ones that ran out: dikidi hides times shortly before they start,
+// so a time that disappeared closer to its start than this wasn't necessarily booked
+const takenMargin = time.Hour
+
+// Forecast sums up how the times of a lab usually come and go
+type Forecast struct {
+	// Observations is the number of recorded times of the lab
+	Observations int
+	// Weekday and Hour are when new times usually appear, in campus time
+	Weekday time.Weekday
+	Hour    int
+	// LeadTime is the median of how long before the lab its times are published
+	LeadTime time.Duration
+	// Taken is the number of times that got booked, TimeToTaken is the median of how long they stayed open
+	Taken       int
+	TimeToTaken time.Duration
+}
+
+// forecast returns nil when there is nothing to forecast from
+func forecast(records []Record, loc *time.Location) *Forecast {
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Times published together are a single publication, so a big batch doesn't outweigh the rest
+	type publication struct {
+		slotKey string
+		at      time.Time
+	}
+	publications := make(map[publication]bool)
+	var counts [7][24]int
+	leadTimes := make([]time.Duration, 0, len(records))
+	openTimes := make([]time.Duration, 0, len(records))
+	for _, record := range records {
+		leadTimes = append(leadTimes, record.Time.Sub(record.FirstSeen))
+		if record.DisappearedAt != nil && record.Time.Sub(*record.DisappearedAt) > takenMargin {
+			openTimes = append(openTimes, record.OpenFor())
+		}
+
+		firstSeen := record.FirstSeen.In(loc)
+		pub := publication{slotKey: record.SlotKey, at: firstSeen.Truncate(time.Hour)}
+		if publications[pub] {
+			continue
+		}
+		publications[pub] = true
+		counts[firstSeen.Weekday()][firstSeen.Hour()]++
+	}
+
+	result := &Forecast{
+		Observations: len(records),
+		LeadTime:     median(leadTimes),
+		Taken:        len(openTimes),
+		TimeToTaken:  median(openTimes),
+	}
+	best := 0
+	for weekday := range counts {
+		for hour, count := range counts[weekday] {
+			if count > best {
+				best = count
+				result.Weekday = time.Weekday(weekday)
+				result.Hour = hour
+			}
+		}
+	}
+	return result
+}
+
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	slices.Sort(durations)
+	middle := len(durations) / 2
+	if len(durations)%2 == 0 {
+		return (durations[middle-1] + durations[middle]) / 2
+	}
+	return durations[middle]
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForecast(t *testing.T) {
+	campus := testutil.Campus(t)
+	// Monday morning
+	published := time.Date(2025, 11, 17, 10, 15, 0, 0, campus)
+
+	record := func(slotKey string, firstSeen time.Time, lead, openFor time.Duration, open bool) Record {
+		r := Record{SlotKey: slotKey, Time: firstSeen.Add(lead), FirstSeen: firstSeen, LastSeen: firstSeen.Add(openFor)}
+		if !open {
+			disappearedAt := r.LastSeen
+			r.DisappearedAt = &disappearedAt
+		}
+		return r
+	}
+
+	type testCase struct {
+		records  []Record
+		expected *Forecast
+	}
+
+	tests := []testCase{
+		{records: nil, expected: nil},
+		{
+			records: []Record{
+				record("a", published, 48*time.Hour, 20*time.Minute, false),
+				record("a", published, 50*time.Hour, 40*time.Minute, false),
+				record("a", published, 52*time.Hour, time.Hour, true),
+			},
+			expected: &Forecast{Observations: 3, Weekday: time.Monday, Hour: 10, LeadTime: 50 * time.Hour, Taken: 2, TimeToTaken: 30 * time.Minute},
+		},
+		// A batch of times is a single publication, and times left until their start weren't taken
+		{
+			records: []Record{
+				record("a", published, 24*time.Hour, 24*time.Hour, false),
+				record("a", published, 25*time.Hour, 25*time.Hour, false),
+				record("a", published, 26*time.Hour, 26*time.Hour, false),
+				record("a", published.AddDate(0, 0, 1).Add(4*time.Hour), 24*time.Hour, time.Hour, false),
+				record("b", published.AddDate(0, 0, 1).Add(4*time.Hour), 26*time.Hour, 3*time.Hour, false),
+			},
+			expected: &Forecast{Observations: 5, Weekday: time.Tuesday, Hour: 14, LeadTime: 25 * time.Hour, Taken: 2, TimeToTaken: 2 * time.Hour},
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_forecast_%d", i), func(t *testing.T) {
+			assert.Equal(t, tCase.expected, forecast(tCase.records, campus))
+		})
+	}
+}
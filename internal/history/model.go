@@ -0,0 +1,81 @@
+package history
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+)
+
+// Record is a single time of a slot while it stays open. DisappearedAt is set once the time is gone,
+// a time that opens again gets a new record
+type Record struct {
+	ID            int64             `db:"id"`
+	SlotKey       string            `db:"slot_key"`
+	CompanyID     int               `db:"company_id"`
+	Type          polling.LabType   `db:"lab_type"`
+	Number        int               `db:"lab_number"`
+	Auditorium    int               `db:"lab_auditorium"`
+	Domain        polling.LabDomain `db:"lab_domain"`
+	Name          string            `db:"lab_name"`
+	Time          time.Time         `db:"slot_time"`
+	Teachers      Teachers          `db:"teachers"`
+	FirstSeen     time.Time         `db:"first_seen"`
+	LastSeen      time.Time         `db:"last_seen"`
+	DisappearedAt *time.Time        `db:"disappeared_at"`
+}
+
+// Teachers are stored as a JSON array
+type Teachers []string
+
+func (t Teachers) Value() (driver.Value, error) {
+	if t == nil {
+		t = Teachers{}
+	}
+	data, err := json.Marshal([]string(t))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (t *Teachers) Scan(src any) error {
+	switch data := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(data), t)
+	case []byte:
+		return json.Unmarshal(data, t)
+	case nil:
+		*t = nil
+		return nil
+	default:
+		return fmt.Errorf("unsupported teachers type %T", src)
+	}
+}
+
+// slot returns the lab the record belongs to, without times
+func (r *Record) slot() polling.Slot {
+	return polling.Slot{
+		CompanyID:  r.CompanyID,
+		Type:       r.Type,
+		Number:     r.Number,
+		Auditorium: r.Auditorium,
+		Domain:     r.Domain,
+		Name:       r.Name,
+	}
+}
+
+// Open reports whether the time is still open
+func (r *Record) Open() bool {
+	return r.DisappearedAt == nil
+}
+
+// OpenFor is how long the time stayed open, or has been open so far
+func (r *Record) OpenFor() time.Duration {
+	if r.DisappearedAt != nil {
+		return r.DisappearedAt.Sub(r.FirstSeen)
+	}
+	return r.LastSeen.Sub(r.FirstSeen)
+}
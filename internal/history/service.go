@@ -0,0 +1,161 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"github.com/robfig/cron/v3"
+)
+
+type Service interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context)
+	// Record remembers every time of a freshly polled slot
+	Record(ctx context.Context, slot polling.Slot)
+	// Observe marks the times of the slots as seen, they are every slot the poller has after a successful poll
+	Observe(ctx context.Context, slots []polling.Slot)
+	FindBySlotKey(ctx context.Context, slotKey string) ([]Record, error)
+	// Forecast sums up the recorded times of the lab, nil when none were recorded
+	Forecast(ctx context.Context, lab polling.LabDemand) (*Forecast, error)
+}
+
+type historyService struct {
+	repo          Repo
+	location      *time.Location
+	options       config.HistoryConfig
+	cronScheduler *cron.Cron
+	// observedAt is when the poller last reported what it sees, zero until it does
+	observedAt time.Time
+	// mu serializes writes, SQLite takes one writer at a time anyway
+	mu sync.Mutex
+}
+
+func New(repo Repo, loc *time.Location, opts *config.HistoryConfig) Service {
+	return &historyService{
+		repo:     repo,
+		location: loc,
+		options:  *opts,
+		mu:       sync.Mutex{},
+	}
+}
+
+func (s *historyService) Start(ctx context.Context) error {
+	slog.Info("Starting", "service", logger.ServiceHistory)
+	c := cron.New()
+	if _, err := c.AddFunc("* * * * *", func() {
+		s.closeStale(ctx, time.Now())
+	}); err != nil {
+		return err
+	}
+	c.Start()
+	s.cronScheduler = c
+	slog.Info("Started", "service", logger.ServiceHistory)
+	return nil
+}
+
+func (s *historyService) Stop(ctx context.Context) {
+	if s.cronScheduler == nil {
+		return
+	}
+	select {
+	case <-s.cronScheduler.Stop().Done():
+		slog.Info("Stopped", "service", logger.ServiceHistory)
+	case <-ctx.Done():
+		slog.Info("Stopped from timeout", "service", logger.ServiceHistory)
+	}
+}
+
+func (s *historyService) Record(ctx context.Context, slot polling.Slot) {
+	if len(slot.TimesTeachers) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.repo.Upsert(ctx, slotRecords(slot, time.Now())); err != nil {
+		slog.Error("Failed to record slot history", "error", err, "slot", slot.Key(), "service", logger.ServiceHistory)
+	}
+}
+
+func (s *historyService) Observe(ctx context.Context, slots []polling.Slot) {
+	now := time.Now()
+	records := make([]Record, 0)
+	for _, slot := range slots {
+		records = append(records, slotRecords(slot, now)...)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.repo.Upsert(ctx, records); err != nil {
+		slog.Error("Failed to record observed slot history", "error", err, "service", logger.ServiceHistory)
+		return
+	}
+	s.observedAt = now
+}
+
+func (s *historyService) FindBySlotKey(ctx context.Context, slotKey string) ([]Record, error) {
+	return s.repo.FindBySlotKey(ctx, slotKey)
+}
+
+func (s *historyService) Forecast(ctx context.Context, lab polling.LabDemand) (*Forecast, error) {
+	records, err := s.repo.FindByLab(ctx, lab.Type, lab.Number)
+	if err != nil {
+		return nil, err
+	}
+	matching := make([]Record, 0, len(records))
+	for _, record := range records {
+		if lab.Matches(record.slot()) {
+			matching = append(matching, record)
+		}
+	}
+	return forecast(matching, s.location), nil
+}
+
+// closeStale closes the times the poller hasn't observed for StaleAfter. Polls don't report times that are gone,
+// so a time missing from every poll for that long is the only sign it's gone. Nothing is closed while
+// the poller hasn't observed anything for StaleAfter, an outage doesn't take the times away
+func (s *historyService) closeStale(ctx context.Context, now time.Time) {
+	if s.options.StaleAfter <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.observedAt.IsZero() || now.Sub(s.observedAt) > s.options.StaleAfter {
+		return
+	}
+	closed, err := s.repo.CloseStale(ctx, s.observedAt.Add(-s.options.StaleAfter))
+	if err != nil {
+		slog.Error("Failed to close stale slot history", "error", err, "service", logger.ServiceHistory)
+		return
+	}
+	if closed > 0 {
+		slog.Info("Closed disappeared slot times", "total", closed, "service", logger.ServiceHistory)
+	}
+}
+
+// slotRecords splits the slot into records of its times. Times are kept in UTC, so that the same instant
+// is always the same row and timestamps compare as stored
+func slotRecords(slot polling.Slot, seenAt time.Time) []Record {
+	seenAt = seenAt.UTC()
+	key := slot.Key()
+	records := make([]Record, 0, len(slot.TimesTeachers))
+	for slotTime, teachers := range slot.TimesTeachers {
+		records = append(records, Record{
+			SlotKey:    key,
+			CompanyID:  slot.CompanyID,
+			Type:       slot.Type,
+			Number:     slot.Number,
+			Auditorium: slot.Auditorium,
+			Domain:     slot.Domain,
+			Name:       slot.Name,
+			Time:       slotTime.UTC(),
+			Teachers:   teachers,
+			FirstSeen:  seenAt,
+			LastSeen:   seenAt,
+		})
+	}
+	return records
+}
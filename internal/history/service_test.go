@@ -0,0 +1,58 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseStale(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRepo(testutil.DB(t))
+	campus := testutil.Campus(t)
+	service := New(repo, campus, &config.HistoryConfig{StaleAfter: 30 * time.Minute}).(*historyService)
+
+	now := time.Now()
+	slotTime := now.Add(48 * time.Hour).Truncate(time.Minute)
+	observed := polling.Slot{
+		CompanyID:     550001,
+		Type:          polling.LabTypePerformance,
+		Number:        7,
+		Auditorium:    233,
+		TimesTeachers: map[time.Time][]string{slotTime: nil},
+	}
+	gone := observed
+	gone.Number = 8
+
+	require.NoError(t, repo.Upsert(ctx, slotRecords(observed, now.Add(-time.Hour))))
+	require.NoError(t, repo.Upsert(ctx, slotRecords(gone, now.Add(-time.Hour))))
+
+	isOpen := func(slot polling.Slot) bool {
+		records, err := repo.FindBySlotKey(ctx, slot.Key())
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		return records[0].Open()
+	}
+
+	// The poller hasn't observed anything yet, unseen times may still be there
+	service.closeStale(ctx, now)
+	assert.True(t, isOpen(observed))
+	assert.True(t, isOpen(gone))
+
+	service.Observe(ctx, []polling.Slot{observed})
+
+	// The poller stalled since, so nothing is closed
+	service.closeStale(ctx, now.Add(time.Hour))
+	assert.True(t, isOpen(observed))
+	assert.True(t, isOpen(gone))
+
+	service.closeStale(ctx, now.Add(time.Minute))
+	assert.True(t, isOpen(observed))
+	assert.False(t, isOpen(gone))
+}
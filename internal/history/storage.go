@@ -0,0 +1,79 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/pkg/errs"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repo interface {
+	// Upsert adds newly seen times and moves LastSeen of the open ones
+	Upsert(ctx context.Context, records []Record) error
+	// CloseStale marks open times not seen since before as disappeared at their LastSeen
+	CloseStale(ctx context.Context, before time.Time) (int64, error)
+	FindBySlotKey(ctx context.Context, slotKey string) ([]Record, error)
+	FindByLab(ctx context.Context, labType polling.LabType, number int) ([]Record, error)
+}
+
+type historyRepo struct {
+	db *sqlx.DB
+}
+
+func NewRepo(db *sqlx.DB) Repo {
+	return &historyRepo{db: db}
+}
+
+const historyUpsert = `
+insert into slot_history
+(slot_key, company_id, lab_type, lab_number, lab_auditorium, lab_domain, lab_name, slot_time, teachers, first_seen, last_seen)
+values
+(:slot_key, :company_id, :lab_type, :lab_number, :lab_auditorium, :lab_domain, :lab_name, :slot_time, :teachers, :first_seen, :last_seen)
+on conflict (slot_key, slot_time) where disappeared_at is null do update
+set teachers = excluded.teachers, last_seen = max(last_seen, excluded.last_seen)`
+
+func (r *historyRepo) Upsert(ctx context.Context, records []Record) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errs.ErrBeginTransaction
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		if _, err := tx.NamedExecContext(ctx, historyUpsert, record); err != nil {
+			return &errs.ErrQueryExecution{Operation: "Upsert", Query: historyUpsert, Err: err}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *historyRepo) CloseStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `update slot_history set disappeared_at = last_seen where disappeared_at is null and last_seen < ?`
+	// Times are kept in UTC, so they compare as stored
+	res, err := r.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, &errs.ErrQueryExecution{Operation: "CloseStale", Query: query, Err: err}
+	}
+	return res.RowsAffected()
+}
+
+func (r *historyRepo) FindBySlotKey(ctx context.Context, slotKey string) ([]Record, error) {
+	query := `select * from slot_history where slot_key = ? order by slot_time, first_seen`
+	var records []Record
+	if err := r.db.SelectContext(ctx, &records, query, slotKey); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindBySlotKey", Query: query, Err: err}
+	}
+	return records, nil
+}
+
+func (r *historyRepo) FindByLab(ctx context.Context, labType polling.LabType, number int) ([]Record, error) {
+	query := `select * from slot_history where lab_type = ? and lab_number = ? order by first_seen, id`
+	var records []Record
+	if err := r.db.SelectContext(ctx, &records, query, labType, number); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindByLab", Query: query, Err: err}
+	}
+	return records, nil
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRepo(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+
+	repo := NewRepo(db)
+	campus := testutil.Campus(t)
+	first := time.Date(2025, 11, 20, 12, 0, 0, 0, campus)
+	slotTime := time.Date(2025, 11, 25, 17, 30, 0, 0, campus)
+	slot := polling.Slot{
+		CompanyID:     550001,
+		Type:          polling.LabTypePerformance,
+		Number:        7,
+		Auditorium:    233,
+		TimesTeachers: map[time.Time][]string{slotTime: {"Иванов И.И."}},
+	}
+
+	require.NoError(t, repo.Upsert(ctx, slotRecords(slot, first)))
+	slot.TimesTeachers[slotTime] = []string{"Петров П.П."}
+	require.NoError(t, repo.Upsert(ctx, slotRecords(slot, first.Add(time.Minute))))
+
+	records, err := repo.FindBySlotKey(ctx, slot.Key())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.True(t, slotTime.Equal(records[0].Time))
+	assert.True(t, first.Equal(records[0].FirstSeen))
+	assert.True(t, first.Add(time.Minute).Equal(records[0].LastSeen))
+	assert.Equal(t, Teachers{"Петров П.П."}, records[0].Teachers)
+	assert.True(t, records[0].Open())
+
+	closed, err := repo.CloseStale(ctx, first.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), closed)
+	closed, err = repo.CloseStale(ctx, first.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), closed)
+
+	// The same time opening again is a new record
+	require.NoError(t, repo.Upsert(ctx, slotRecords(slot, first.Add(2*time.Hour))))
+	records, err = repo.FindBySlotKey(ctx, slot.Key())
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.NotNil(t, records[0].DisappearedAt)
+	assert.True(t, first.Add(time.Minute).Equal(*records[0].DisappearedAt))
+	assert.Equal(t, time.Minute, records[0].OpenFor())
+	assert.True(t, records[1].Open())
+}
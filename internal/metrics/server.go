@@ -4,12 +4,16 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/Ademun/mining-lab-bot/internal/health"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func Listen(addr string) {
+// Listen serves metrics along with the health endpoints of the checker
+func Listen(addr string, checker *health.Checker) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", checker.Healthz)
+	mux.HandleFunc("/readyz", checker.Readyz)
 
 	go func() {
 		err := http.ListenAndServe(addr, mux)
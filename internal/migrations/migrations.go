@@ -0,0 +1,204 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/errs"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+var fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+	Version   int       `db:"version"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Load reads embedded migrations sorted by version. Every version must have both up and down scripts
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(sqlFiles, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(sqlFiles, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("conflicting names for migration %d: %s, %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d is missing up or down script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+	return migrations, nil
+}
+
+// Up applies all pending migrations in order, each in its own transaction
+func Up(ctx context.Context, db *sqlx.DB) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := apply(ctx, db, m.Version, m.Up, true); err != nil {
+			return err
+		}
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name, "service", logger.ServiceMigrations)
+	}
+	return nil
+}
+
+// Down rolls back the given number of most recently applied migrations
+func Down(ctx context.Context, db *sqlx.DB, steps int) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := apply(ctx, db, m.Version, m.Down, false); err != nil {
+			return err
+		}
+		slog.Info("Rolled back migration", "version", m.Version, "name", m.Name, "service", logger.ServiceMigrations)
+		steps--
+	}
+	return nil
+}
+
+func Status(ctx context.Context, db *sqlx.DB) ([]MigrationStatus, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for idx, m := range migrations {
+		statuses[idx] = MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			statuses[idx].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+func appliedVersions(ctx context.Context, db *sqlx.DB) (map[int]time.Time, error) {
+	query := `
+create table if not exists schema_migrations
+(
+    version    integer primary key,
+    applied_at timestamp not null
+)`
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "appliedVersions", Query: query, Err: err}
+	}
+
+	query = `select version, applied_at from schema_migrations`
+	var rows []appliedMigration
+	if err := db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "appliedVersions", Query: query, Err: err}
+	}
+
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+func apply(ctx context.Context, db *sqlx.DB, version int, script string, up bool) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errs.ErrBeginTransaction
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return &errs.ErrQueryExecution{Operation: fmt.Sprintf("migration %d", version), Query: script, Err: err}
+	}
+
+	query := `insert into schema_migrations (version, applied_at) values (?, ?)`
+	args := []interface{}{version, time.Now().UTC()}
+	if !up {
+		query = `delete from schema_migrations where version = ?`
+		args = args[:1]
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return &errs.ErrQueryExecution{Operation: fmt.Sprintf("migration %d", version), Query: query, Err: err}
+	}
+
+	return tx.Commit()
+}
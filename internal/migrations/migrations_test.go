@@ -0,0 +1,154 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", ":memory:?_foreign_keys=on")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// downTo rolls the migrations back until the version is no longer applied
+func downTo(t *testing.T, ctx context.Context, db *sqlx.DB, version int) {
+	migrations, err := Load()
+	require.NoError(t, err)
+	steps := 0
+	for _, migration := range migrations {
+		if migration.Version >= version {
+			steps++
+		}
+	}
+	require.NoError(t, Down(ctx, db, steps))
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	require.NoError(t, Up(ctx, db))
+	// Applying twice must be a no-op
+	require.NoError(t, Up(ctx, db))
+
+	statuses, err := Status(ctx, db)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, "migration %d", status.Version)
+	}
+
+	require.NoError(t, Down(ctx, db, len(statuses)))
+	statuses, err = Status(ctx, db)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.Nil(t, status.AppliedAt, "migration %d", status.Version)
+	}
+
+	var tables int
+	require.NoError(t, db.GetContext(ctx, &tables, `select count(*) from sqlite_master where type = 'table' and name != 'schema_migrations'`))
+	assert.Equal(t, 0, tables)
+}
+
+func TestSubscriptionConstraints(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	require.NoError(t, Up(ctx, db))
+
+	insert := `insert into subscriptions (uuid, user_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday) values (?, 1, 0, 7, 233, null, null)`
+	_, err := db.ExecContext(ctx, insert, "a")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, insert, "b")
+	assert.Error(t, err, "duplicate subscription with NULL columns must be rejected")
+
+	_, err = db.ExecContext(ctx, `insert into subscription_lessons (subscription_uuid, lesson) values ('a', 1)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `insert into subscription_lessons (subscription_uuid, lesson) values ('missing', 1)`)
+	assert.Error(t, err, "lessons must reference an existing subscription")
+
+	_, err = db.ExecContext(ctx, `delete from subscriptions where uuid = 'a'`)
+	require.NoError(t, err)
+	var lessons int
+	require.NoError(t, db.GetContext(ctx, &lessons, `select count(*) from subscription_lessons`))
+	assert.Equal(t, 0, lessons)
+}
+
+func TestLegacySubscriptionTables(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	// Tables created by hand before migrations, without keys and constraints
+	_, err := db.ExecContext(ctx, `
+create table subscriptions (uuid text, user_id integer, lab_type integer, lab_number integer, lab_auditorium integer, lab_domain integer, weekday integer);
+create table subscription_times (subscription_uuid text, time_start text, time_end text);
+insert into subscriptions values ('a', 1, 0, 7, 233, null, null), ('b', 1, 0, 7, 233, null, null), ('c', 2, 1, 5, null, 1, 3);
+insert into subscription_times values ('a', '08:50', '10:20'), ('b', '10:35', '12:05'), ('c', '12:35', '14:05'), ('missing', '08:50', '10:20');`)
+	require.NoError(t, err)
+
+	require.NoError(t, Up(ctx, db))
+
+	var subs []string
+	require.NoError(t, db.SelectContext(ctx, &subs, `select uuid from subscriptions order by uuid`))
+	assert.Equal(t, []string{"a", "c"}, subs)
+
+	var lessons []string
+	require.NoError(t, db.SelectContext(ctx, &lessons, `select subscription_uuid || ':' || lesson from subscription_lessons order by subscription_uuid`))
+	assert.Equal(t, []string{"a:1", "c:3"}, lessons)
+
+	// The rebuilt tables have the constraints
+	_, err = db.ExecContext(ctx, `insert into subscriptions (uuid, user_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday) values ('d', 1, 0, 7, 233, null, null)`)
+	assert.Error(t, err, "duplicate subscription must be rejected")
+	_, err = db.ExecContext(ctx, `delete from subscriptions where uuid = 'a'`)
+	require.NoError(t, err)
+	var remaining int
+	require.NoError(t, db.GetContext(ctx, &remaining, `select count(*) from subscription_lessons`))
+	assert.Equal(t, 1, remaining)
+}
+
+func TestLabDomainKeys(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	require.NoError(t, Up(ctx, db))
+	downTo(t, ctx, db, 13)
+
+	insert := `insert into subscriptions (uuid, user_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday) values (?, 1, 1, 7, null, ?, null)`
+	_, err := db.ExecContext(ctx, insert, "a", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, insert, "b", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, Up(ctx, db))
+	var domains []sql.NullString
+	require.NoError(t, db.SelectContext(ctx, &domains, `select lab_domain from subscriptions order by uuid`))
+	assert.Equal(t, []sql.NullString{{String: "mechanics", Valid: true}, {}}, domains)
+}
+
+func TestSubscriptionLessons(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	require.NoError(t, Up(ctx, db))
+	downTo(t, ctx, db, 14)
+
+	_, err := db.ExecContext(ctx, `insert into subscriptions (uuid, user_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday) values ('a', 1, 0, 7, 233, null, 1)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `insert into subscription_times (subscription_uuid, time_start, time_end) values ('a', '10:35', '12:05'), ('a', '20:40', '22:00'), ('a', '11:00', '12:00')`)
+	require.NoError(t, err)
+
+	require.NoError(t, Up(ctx, db))
+	var lessons []int
+	require.NoError(t, db.SelectContext(ctx, &lessons, `select lesson from subscription_lessons where subscription_uuid = 'a' order by lesson`))
+	assert.Equal(t, []int{2, 8}, lessons)
+
+	require.NoError(t, Down(ctx, db, 1))
+	var times []string
+	require.NoError(t, db.SelectContext(ctx, &times, `select time_start || '-' || time_end from subscription_times where subscription_uuid = 'a' order by time_start`))
+	assert.Equal(t, []string{"10:35-12:05", "20:40-22:00"}, times)
+}
@@ -0,0 +1,2 @@
+drop table if exists subscription_times;
+drop table if exists subscriptions;
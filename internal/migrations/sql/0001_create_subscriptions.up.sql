@@ -0,0 +1,74 @@
+-- Databases from before migrations have hand-made tables, possibly without the keys and constraints below.
+-- Whatever is there gets rebuilt: rows are copied into fresh tables, duplicate subscriptions keep their
+-- first row, rows without required values and times of subscriptions that are gone are dropped.
+-- Hand-made tables missing one of the columns fail the migration and have to be fixed by hand.
+-- New databases rebuild empty tables
+create table if not exists subscriptions
+(
+    uuid           text,
+    user_id        integer,
+    lab_type       integer,
+    lab_number     integer,
+    lab_auditorium integer,
+    lab_domain     integer,
+    weekday        integer
+);
+
+create table if not exists subscription_times
+(
+    subscription_uuid text,
+    time_start        text,
+    time_end          text
+);
+
+create table subscriptions_rebuilt
+(
+    uuid           text primary key,
+    user_id        integer not null,
+    lab_type       integer not null,
+    lab_number     integer not null,
+    lab_auditorium integer,
+    lab_domain     integer,
+    weekday        integer
+);
+
+insert or ignore into subscriptions_rebuilt (uuid, user_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday)
+select uuid, user_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday
+from subscriptions
+where rowid in (select min(rowid)
+                from subscriptions
+                group by user_id, lab_type, lab_number, coalesce(lab_auditorium, -1), coalesce(lab_domain, -1),
+                         coalesce(weekday, -1));
+
+create table subscription_times_rebuilt as
+select subscription_uuid, time_start, time_end
+from subscription_times
+where subscription_uuid in (select uuid from subscriptions_rebuilt);
+
+drop table subscription_times;
+drop table subscriptions;
+alter table subscriptions_rebuilt rename to subscriptions;
+
+-- NULLs are distinct in SQLite unique indexes, so optional columns are coalesced
+-- to make "any weekday" subscriptions collide with each other as well
+create unique index if not exists subscriptions_unique_idx on subscriptions
+    (user_id, lab_type, lab_number, coalesce(lab_auditorium, -1), coalesce(lab_domain, -1), coalesce(weekday, -1));
+
+create index if not exists subscriptions_lab_idx on subscriptions (lab_type, lab_number);
+
+create table subscription_times
+(
+    subscription_uuid text not null references subscriptions (uuid) on delete cascade,
+    time_start        text not null,
+    time_end          text not null
+);
+
+insert into subscription_times (subscription_uuid, time_start, time_end)
+select subscription_uuid, time_start, time_end
+from subscription_times_rebuilt
+where time_start is not null
+  and time_end is not null;
+
+drop table subscription_times_rebuilt;
+
+create index if not exists subscription_times_uuid_idx on subscription_times (subscription_uuid);
@@ -0,0 +1,12 @@
+create table if not exists teachers
+(
+    name        text    not null,
+    auditorium  integer not null,
+    week_number integer not null,
+    weekday     integer not null,
+    time_start  text    not null,
+    time_end    text    not null,
+    difficulty  integer not null default 0
+);
+
+create index if not exists teachers_schedule_idx on teachers (auditorium, week_number, weekday);
@@ -0,0 +1,6 @@
+drop index if exists subscriptions_unique_idx;
+
+alter table subscriptions drop column company_id;
+
+create unique index if not exists subscriptions_unique_idx on subscriptions
+    (user_id, lab_type, lab_number, coalesce(lab_auditorium, -1), coalesce(lab_domain, -1), coalesce(weekday, -1));
@@ -0,0 +1,7 @@
+alter table subscriptions add column company_id integer;
+
+drop index if exists subscriptions_unique_idx;
+
+create unique index if not exists subscriptions_unique_idx on subscriptions
+    (user_id, coalesce(company_id, -1), lab_type, lab_number, coalesce(lab_auditorium, -1), coalesce(lab_domain, -1),
+     coalesce(weekday, -1));
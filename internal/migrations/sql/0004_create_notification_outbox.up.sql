@@ -0,0 +1,13 @@
+create table if not exists notification_outbox
+(
+    id              integer primary key,
+    user_id         integer   not null,
+    payload         text      not null,
+    status          text      not null default 'pending',
+    attempts        integer   not null default 0,
+    next_attempt_at timestamp not null,
+    last_error      text,
+    created_at      timestamp not null
+);
+
+create index if not exists notification_outbox_due_idx on notification_outbox (status, next_attempt_at);
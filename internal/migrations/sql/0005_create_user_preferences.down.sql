@@ -0,0 +1,3 @@
+drop table if exists held_notifications;
+
+drop table if exists user_preferences;
@@ -0,0 +1,18 @@
+create table if not exists user_preferences
+(
+    user_id     integer primary key,
+    quiet_start text,
+    quiet_end   text,
+    timezone    text not null
+);
+
+create table if not exists held_notifications
+(
+    id         integer primary key,
+    user_id    integer   not null,
+    payload    text      not null,
+    release_at timestamp not null,
+    created_at timestamp not null
+);
+
+create index if not exists held_notifications_release_idx on held_notifications (release_at);
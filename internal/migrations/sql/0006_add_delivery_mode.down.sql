@@ -0,0 +1 @@
+alter table user_preferences drop column delivery_mode;
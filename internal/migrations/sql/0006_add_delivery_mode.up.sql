@@ -0,0 +1 @@
+alter table user_preferences add column delivery_mode text not null default 'instant';
@@ -0,0 +1,2 @@
+drop index if exists parse_quarantine_last_seen_idx;
+drop table if exists parse_quarantine;
@@ -0,0 +1,15 @@
+create table if not exists parse_quarantine
+(
+    id           integer primary key,
+    company_id   integer   not null,
+    service_id   integer   not null,
+    username     text      not null,
+    service_name text      not null,
+    reason       text      not null,
+    occurrences  integer   not null default 1,
+    first_seen   timestamp not null,
+    last_seen    timestamp not null,
+    unique (company_id, username, service_name)
+);
+
+create index if not exists parse_quarantine_last_seen_idx on parse_quarantine (last_seen);
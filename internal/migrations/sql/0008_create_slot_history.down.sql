@@ -0,0 +1,3 @@
+drop index if exists slot_history_last_seen_idx;
+drop index if exists slot_history_open_idx;
+drop table if exists slot_history;
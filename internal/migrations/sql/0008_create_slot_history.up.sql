@@ -0,0 +1,20 @@
+create table if not exists slot_history
+(
+    id             integer primary key,
+    slot_key       text      not null,
+    company_id     integer   not null,
+    lab_type       integer   not null,
+    lab_number     integer   not null,
+    lab_auditorium integer   not null,
+    lab_domain     integer   not null,
+    lab_name       text      not null,
+    slot_time      timestamp not null,
+    teachers       text      not null,
+    first_seen     timestamp not null,
+    last_seen      timestamp not null,
+    disappeared_at timestamp
+);
+
+-- A time that disappears and opens again starts a new row
+create unique index if not exists slot_history_open_idx on slot_history (slot_key, slot_time) where disappeared_at is null;
+create index if not exists slot_history_last_seen_idx on slot_history (last_seen);
@@ -0,0 +1 @@
+drop index if exists slot_history_lab_idx;
@@ -0,0 +1 @@
+create index if not exists slot_history_lab_idx on slot_history (lab_type, lab_number);
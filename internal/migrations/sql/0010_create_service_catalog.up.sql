@@ -0,0 +1,10 @@
+create table if not exists service_catalog
+(
+    company_id integer   not null,
+    service_id integer   not null,
+    name       text      not null,
+    first_seen timestamp not null,
+    last_seen  timestamp not null,
+    removed_at timestamp,
+    primary key (company_id, service_id)
+);
@@ -0,0 +1,11 @@
+create table if not exists lab_catalog
+(
+    company_id     integer   not null,
+    lab_type       integer   not null,
+    lab_number     integer   not null,
+    lab_auditorium integer   not null,
+    lab_domain     integer   not null,
+    lab_name       text      not null,
+    first_seen     timestamp not null,
+    primary key (company_id, lab_type, lab_number, lab_auditorium, lab_domain)
+);
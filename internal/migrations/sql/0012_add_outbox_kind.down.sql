@@ -0,0 +1 @@
+alter table notification_outbox drop column kind;
@@ -0,0 +1 @@
+alter table notification_outbox add column kind text not null default 'notification';
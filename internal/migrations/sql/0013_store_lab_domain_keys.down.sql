@@ -0,0 +1,8 @@
+-- Domains added in the config have no number, they become unknown
+update subscriptions
+set lab_domain = case lab_domain when 'electricity' then 0 when 'mechanics' then 1 when 'virtual' then 2 else 3 end
+where lab_domain is not null;
+update slot_history
+set lab_domain = case lab_domain when 'electricity' then 0 when 'mechanics' then 1 when 'virtual' then 2 else 3 end;
+update lab_catalog
+set lab_domain = case lab_domain when 'electricity' then 0 when 'mechanics' then 1 when 'virtual' then 2 else 3 end;
@@ -0,0 +1,8 @@
+-- Domains are config keys now. The columns keep their integer affinity, SQLite stores the keys as text
+update subscriptions
+set lab_domain = case lab_domain when 0 then 'electricity' when 1 then 'mechanics' when 2 then 'virtual' else 'unknown' end
+where lab_domain is not null;
+update slot_history
+set lab_domain = case lab_domain when 0 then 'electricity' when 1 then 'mechanics' when 2 then 'virtual' else 'unknown' end;
+update lab_catalog
+set lab_domain = case lab_domain when 0 then 'electricity' when 1 then 'mechanics' when 2 then 'virtual' else 'unknown' end;
@@ -0,0 +1,24 @@
+-- Lessons are turned back into the times of the default bell schedule
+create table if not exists subscription_times
+(
+    subscription_uuid text not null references subscriptions (uuid) on delete cascade,
+    time_start        text not null,
+    time_end          text not null
+);
+
+create index if not exists subscription_times_uuid_idx on subscription_times (subscription_uuid);
+
+insert into subscription_times (subscription_uuid, time_start, time_end)
+select subscription_uuid,
+       case lesson
+           when 1 then '08:50' when 2 then '10:35' when 3 then '12:35' when 4 then '14:15'
+           when 5 then '15:55' when 6 then '17:30' when 7 then '19:10' when 8 then '20:40'
+           end,
+       case lesson
+           when 1 then '10:20' when 2 then '12:05' when 3 then '14:05' when 4 then '15:45'
+           when 5 then '17:20' when 6 then '19:00' when 7 then '20:30' when 8 then '22:00'
+           end
+from subscription_lessons
+where lesson between 1 and 8;
+
+drop table subscription_lessons;
@@ -0,0 +1,26 @@
+-- Subscriptions keep lesson numbers, the times of a lesson depend on the date and are resolved against the schedule.
+-- Stored times come from the default bell schedule, times that aren't its lessons are dropped
+create table if not exists subscription_lessons
+(
+    subscription_uuid text    not null references subscriptions (uuid) on delete cascade,
+    lesson            integer not null
+);
+
+create index if not exists subscription_lessons_uuid_idx on subscription_lessons (subscription_uuid);
+
+insert into subscription_lessons (subscription_uuid, lesson)
+select subscription_uuid,
+       case time_start
+           when '08:50' then 1
+           when '10:35' then 2
+           when '12:35' then 3
+           when '14:15' then 4
+           when '15:55' then 5
+           when '17:30' then 6
+           when '19:10' then 7
+           when '20:40' then 8
+           end
+from subscription_times
+where time_start in ('08:50', '10:35', '12:35', '14:15', '15:55', '17:30', '19:10', '20:40');
+
+drop table subscription_times;
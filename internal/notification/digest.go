@@ -0,0 +1,150 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"github.com/robfig/cron/v3"
+)
+
+func (s *notificationService) SetDeliveryMode(ctx context.Context, userID int, mode DeliveryMode) error {
+	prefs, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.prefs.SetDeliveryMode(ctx, userID, mode, prefs.Timezone)
+}
+
+// nextDigest returns when the next digest of the user goes out, following the cron spec of the delivery mode
+// in the user's time zone. Users with instant delivery have no digests
+func (s *notificationService) nextDigest(prefs *Preferences, now time.Time) (time.Time, bool) {
+	var spec string
+	switch prefs.DeliveryMode {
+	case DeliveryHourly:
+		spec = s.options.HourlyDigestSpec
+	case DeliveryDaily:
+		spec = s.options.DailyDigestSpec
+	default:
+		return time.Time{}, false
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		slog.Error("Cron error", "error", err, "spec", spec, "service", logger.ServiceNotification)
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return schedule.Next(now.In(loc)), true
+}
+
+// releaseHeld moves due held notifications to the outbox, as a digest when there are several of them
+func (s *notificationService) releaseHeld(ctx context.Context) {
+	now := outboxNow()
+	held, err := s.held.FindReleased(ctx, now)
+	if err != nil {
+		slog.Error("Failed to find held notifications", "error", err, "service", logger.ServiceNotification)
+		return
+	}
+
+	byUser := make(map[int][]HeldNotification)
+	for _, h := range held {
+		byUser[h.UserID] = append(byUser[h.UserID], h)
+	}
+
+	for userID, userHeld := range byUser {
+		ids := make([]int64, 0, len(userHeld))
+		digest := Digest{UserID: userID}
+		for _, h := range userHeld {
+			ids = append(ids, h.ID)
+			notif, err := h.Notification()
+			if err != nil {
+				slog.Error("Failed to decode held notification", "error", err, "id", h.ID, "service", logger.ServiceNotification)
+				continue
+			}
+			digest.Notifications = append(digest.Notifications, notif)
+		}
+
+		msg, err := releaseMessage(digest, now)
+		if err != nil {
+			slog.Error("Failed to build outbox message", "error", err, "user_id", userID, "service", logger.ServiceNotification)
+			continue
+		}
+		if err = s.held.Release(ctx, ids, msg); err != nil {
+			slog.Error("Failed to release held notifications", "error", err, "user_id", userID, "service", logger.ServiceNotification)
+		}
+	}
+}
+
+// releaseMessage builds the outbox message for released notifications, nil when nothing is left to tell about
+func releaseMessage(digest Digest, now time.Time) (*OutboxMessage, error) {
+	var msg OutboxMessage
+	var err error
+	switch len(digest.Notifications) {
+	case 0:
+		return nil, nil
+	case 1:
+		msg, err = newOutboxMessage(digest.Notifications[0], now)
+	default:
+		digest.Notifications = MergeDigest(digest.Notifications)
+		// Everything was taken while held
+		if len(digest.Notifications) == 0 {
+			return nil, nil
+		}
+		msg, err = newDigestOutboxMessage(digest, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// MergeDigest folds notifications about the same slot into one, in the order slots first appeared.
+// Taken times cancel the earlier opened ones, and slots with nothing left open are dropped
+func MergeDigest(notifs []Notification) []Notification {
+	merged := make([]Notification, 0, len(notifs))
+	indexByKey := make(map[string]int)
+	for _, notif := range notifs {
+		// Published labs have no times to merge
+		if notif.Kind == KindLabPublished {
+			merged = append(merged, notif)
+			continue
+		}
+		key := notif.Slot.Key()
+		idx, ok := indexByKey[key]
+		if !ok {
+			entry := notif
+			entry.Kind = KindNewSlot
+			entry.Slot.TimesTeachers = make(map[time.Time][]string)
+			merged = append(merged, entry)
+			idx = len(merged) - 1
+			indexByKey[key] = idx
+		}
+
+		times := merged[idx].Slot.TimesTeachers
+		for t, teachers := range notif.Slot.TimesTeachers {
+			// Times are compared by instant, since they lose their location after a round trip through JSON
+			for existing := range times {
+				if existing.Equal(t) {
+					delete(times, existing)
+				}
+			}
+			if notif.Kind != KindTimesTaken {
+				times[t] = teachers
+			}
+		}
+		merged[idx].PreferredTimes = notif.PreferredTimes
+	}
+
+	result := make([]Notification, 0, len(merged))
+	for _, notif := range merged {
+		if notif.Kind == KindLabPublished || len(notif.Slot.TimesTeachers) > 0 {
+			result = append(result, notif)
+		}
+	}
+	return result
+}
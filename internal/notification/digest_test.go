@@ -0,0 +1,109 @@
+package notification
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDigest(t *testing.T) {
+	first := time.Date(2025, 11, 20, 8, 50, 0, 0, time.UTC)
+	second := time.Date(2025, 11, 20, 10, 35, 0, 0, time.UTC)
+	third := time.Date(2025, 11, 21, 12, 35, 0, 0, time.UTC)
+
+	lab7 := polling.Slot{Number: 7, Auditorium: 233}
+	lab12 := polling.Slot{Number: 12, Auditorium: 512}
+	withTimes := func(slot polling.Slot, times ...time.Time) polling.Slot {
+		slot.TimesTeachers = make(map[time.Time][]string)
+		for _, t := range times {
+			slot.TimesTeachers[t] = nil
+		}
+		return slot
+	}
+
+	type testCase struct {
+		notifs   []Notification
+		expected map[int][]time.Time
+	}
+
+	tests := []testCase{
+		{
+			notifs: []Notification{
+				{Kind: KindNewSlot, Slot: withTimes(lab7, first)},
+				{Kind: KindNewTimes, Slot: withTimes(lab7, second)},
+				{Kind: KindNewSlot, Slot: withTimes(lab12, third)},
+			},
+			expected: map[int][]time.Time{7: {first, second}, 12: {third}},
+		},
+		{
+			notifs: []Notification{
+				{Kind: KindNewSlot, Slot: withTimes(lab7, first, second)},
+				// Decoded from JSON with a fixed zone, but the same instant
+				{Kind: KindTimesTaken, Slot: withTimes(lab7, first.In(time.FixedZone("", 3*60*60)))},
+			},
+			expected: map[int][]time.Time{7: {second}},
+		},
+		{
+			notifs: []Notification{
+				{Kind: KindNewSlot, Slot: withTimes(lab7, first)},
+				{Kind: KindTimesTaken, Slot: withTimes(lab7, first)},
+			},
+			expected: map[int][]time.Time{},
+		},
+		// Published labs have no times, but still make it to the digest
+		{
+			notifs: []Notification{
+				{Kind: KindLabPublished, Slot: lab12},
+				{Kind: KindNewSlot, Slot: withTimes(lab7, first)},
+			},
+			expected: map[int][]time.Time{7: {first}, 12: {}},
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_merge_digest_%d", i), func(t *testing.T) {
+			merged := MergeDigest(tCase.notifs)
+			require.Len(t, merged, len(tCase.expected))
+			for _, notif := range merged {
+				assert.ElementsMatch(t, tCase.expected[notif.Slot.Number], keys(notif.Slot.TimesTeachers))
+			}
+		})
+	}
+}
+
+func TestNextDigest(t *testing.T) {
+	s := &notificationService{options: config.NotificationConfig{
+		HourlyDigestSpec: "0 * * * *",
+		DailyDigestSpec:  "0 9 * * *",
+	}}
+	// 03:20 in Moscow
+	now := time.Date(2025, 11, 20, 0, 20, 0, 0, time.UTC)
+
+	type testCase struct {
+		mode       DeliveryMode
+		expected   time.Time
+		expectedOk bool
+	}
+
+	tests := []testCase{
+		{mode: DeliveryInstant},
+		{mode: DeliveryHourly, expected: time.Date(2025, 11, 20, 1, 0, 0, 0, time.UTC), expectedOk: true},
+		{mode: DeliveryDaily, expected: time.Date(2025, 11, 20, 6, 0, 0, 0, time.UTC), expectedOk: true},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_next_digest_%d", i), func(t *testing.T) {
+			prefs := &Preferences{Timezone: "Europe/Moscow", DeliveryMode: tCase.mode}
+			actual, ok := s.nextDigest(prefs, now)
+			assert.Equal(t, tCase.expectedOk, ok)
+			if tCase.expectedOk {
+				assert.True(t, tCase.expected.Equal(actual), "expected %v, got %v", tCase.expected, actual)
+			}
+		})
+	}
+}
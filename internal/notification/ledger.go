@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/redis/go-redis/v9"
+)
+
+// NotificationLedger remembers which slot times every user has already been told about.
+// Entries expire after the resend cooldown, so a still open time is announced again only after that
+type NotificationLedger struct {
+	client *redis.Client
+}
+
+func NewNotificationLedger(client *redis.Client) *NotificationLedger {
+	return &NotificationLedger{
+		client: client,
+	}
+}
+
+// FilterUnseen returns slot times the user wasn't notified about, or whose teachers changed since the last notification
+func (l *NotificationLedger) FilterUnseen(ctx context.Context, userID int, slot polling.Slot) (map[time.Time][]string, error) {
+	if len(slot.TimesTeachers) == 0 {
+		return nil, nil
+	}
+
+	times := make([]time.Time, 0, len(slot.TimesTeachers))
+	keys := make([]string, 0, len(slot.TimesTeachers))
+	for t := range slot.TimesTeachers {
+		times = append(times, t)
+		keys = append(keys, l.makeKey(userID, slot.Key(), t))
+	}
+
+	values, err := l.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	unseen := make(map[time.Time][]string)
+	for idx, value := range values {
+		teachers := slot.TimesTeachers[times[idx]]
+		fingerprint, ok := value.(string)
+		if !ok || fingerprint != teachersFingerprint(teachers) {
+			unseen[times[idx]] = teachers
+		}
+	}
+	return unseen, nil
+}
+
+func (l *NotificationLedger) Record(ctx context.Context, userID int, slot polling.Slot, cooldown time.Duration) error {
+	pipe := l.client.Pipeline()
+	for t, teachers := range slot.TimesTeachers {
+		pipe.Set(ctx, l.makeKey(userID, slot.Key(), t), teachersFingerprint(teachers), cooldown)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (l *NotificationLedger) makeKey(userID int, slotKey string, t time.Time) string {
+	return fmt.Sprintf("ledger:%d:%s:%d", userID, slotKey, t.Unix())
+}
+
+func teachersFingerprint(teachers []string) string {
+	sorted := slices.Clone(teachers)
+	slices.Sort(sorted)
+	return strings.Join(sorted, ",")
+}
@@ -12,6 +12,26 @@ var (
 		Help: "Number of notifications sent",
 	})
 
+	notificationsDeduplicatedMetrics = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "notifications_deduplicated",
+		Help: "Number of notifications skipped because the user was already notified",
+	})
+
+	notificationsRetriedMetrics = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "notifications_retried",
+		Help: "Number of notification deliveries postponed for a retry",
+	})
+
+	notificationsDeadMetrics = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "notifications_dead",
+		Help: "Number of notifications moved to dead letters",
+	})
+
+	notificationsHeldMetrics = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "notifications_held",
+		Help: "Number of notifications held back until the end of quiet hours",
+	})
+
 	uniqueSlotsMetrics = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "notifications_unique_slots",
 		Help: "Slot count by type",
@@ -22,6 +42,22 @@ func recordNotification() {
 	notificationsSentMetics.Inc()
 }
 
+func recordDeduplicated() {
+	notificationsDeduplicatedMetrics.Inc()
+}
+
+func recordRetry() {
+	notificationsRetriedMetrics.Inc()
+}
+
+func recordDeadLetter() {
+	notificationsDeadMetrics.Inc()
+}
+
+func recordHeld() {
+	notificationsHeldMetrics.Inc()
+}
+
 func recordSlot(slotType polling.LabType) {
 	var enType string
 	switch slotType {
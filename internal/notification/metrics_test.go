@@ -0,0 +1,16 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordSlot(t *testing.T) {
+	// Counters are registered once, recording must not register them again
+	assert.NotPanics(t, func() {
+		recordSlot(polling.LabTypePerformance)
+		recordSlot(polling.LabTypeDefence)
+	})
+}
@@ -2,6 +2,9 @@ package notification
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Ademun/mining-lab-bot/internal/polling"
@@ -10,12 +13,213 @@ import (
 
 type PreferredTimes map[time.Weekday][]subscription.TimeRange
 
+type NotificationKind int
+
+const (
+	// KindNewSlot is sent when a slot is seen for the first time
+	KindNewSlot NotificationKind = iota
+	// KindNewTimes is sent when an already known slot gets new times. Slot contains only the added times
+	KindNewTimes
+	// KindTimesTaken is sent when times of a known slot disappear. Slot contains only the removed times
+	KindTimesTaken
+	// KindLabPublished is sent when a lab appears on the booking page for the first time. Slot has no times yet
+	KindLabPublished
+)
+
 type Notification struct {
+	Kind           NotificationKind
 	UserID         int
 	PreferredTimes PreferredTimes
 	Slot           polling.Slot
 }
 
+// Digest combines notifications held for the user into a single message
+type Digest struct {
+	UserID        int
+	Notifications []Notification
+}
+
+// Broadcast is an admin message for a user, the text is sent as is without any formatting
+type Broadcast struct {
+	UserID int
+	Text   string
+}
+
+// SlotNotifier delivers notifications to users. Returned ErrUndeliverable and *ErrRetryAfter
+// tell the outbox to give up on the notification or to postpone it respectively
 type SlotNotifier interface {
-	SendNotification(ctx context.Context, notif Notification)
+	SendNotification(ctx context.Context, notif Notification) error
+	SendDigest(ctx context.Context, digest Digest) error
+	SendBroadcast(ctx context.Context, broadcast Broadcast) error
+	// SendCatalogDiff gives the admin a summary of the booking page changes
+	SendCatalogDiff(ctx context.Context, diff polling.CatalogDiff) error
+}
+
+// SlotHistory remembers every slot the poller reports, before any filtering
+type SlotHistory interface {
+	Record(ctx context.Context, slot polling.Slot)
+	// Observe marks the times of the slots as still there
+	Observe(ctx context.Context, slots []polling.Slot)
+}
+
+// QuietHours is a daily window in the user's time zone, when notifications are held back.
+// The window may span midnight, e.g. 23:00-08:00
+type QuietHours struct {
+	Start    string
+	End      string
+	Timezone string
+}
+
+// DeliveryMode tells whether notifications are sent right away or batched into periodic digests
+type DeliveryMode string
+
+const (
+	DeliveryInstant DeliveryMode = "instant"
+	DeliveryHourly  DeliveryMode = "hourly"
+	DeliveryDaily   DeliveryMode = "daily"
+)
+
+type Preferences struct {
+	UserID       int          `db:"user_id"`
+	QuietStart   *string      `db:"quiet_start"`
+	QuietEnd     *string      `db:"quiet_end"`
+	Timezone     string       `db:"timezone"`
+	DeliveryMode DeliveryMode `db:"delivery_mode"`
+}
+
+func (p *Preferences) QuietHours() *QuietHours {
+	if p.QuietStart == nil || p.QuietEnd == nil {
+		return nil
+	}
+	return &QuietHours{
+		Start:    *p.QuietStart,
+		End:      *p.QuietEnd,
+		Timezone: p.Timezone,
+	}
+}
+
+// HeldNotification waits for the end of the user's quiet hours or for the next digest
+type HeldNotification struct {
+	ID        int64     `db:"id"`
+	UserID    int       `db:"user_id"`
+	Payload   string    `db:"payload"`
+	ReleaseAt time.Time `db:"release_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func newHeldNotification(notif Notification, releaseAt, now time.Time) (HeldNotification, error) {
+	payload, err := json.Marshal(notif)
+	if err != nil {
+		return HeldNotification{}, ErrMarshal
+	}
+	return HeldNotification{
+		UserID:    notif.UserID,
+		Payload:   string(payload),
+		ReleaseAt: releaseAt,
+		CreatedAt: now,
+	}, nil
+}
+
+func (h *HeldNotification) Notification() (Notification, error) {
+	var notif Notification
+	if err := json.Unmarshal([]byte(h.Payload), &notif); err != nil {
+		return Notification{}, ErrUnmarshal
+	}
+	return notif, nil
+}
+
+// ErrUndeliverable means the notification will never be delivered, e.g. the user blocked the bot
+var ErrUndeliverable = errors.New("notification is undeliverable")
+
+// ErrRetryAfter asks to postpone the delivery, e.g. when Telegram rate limits the bot
+type ErrRetryAfter struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ErrRetryAfter) Error() string {
+	return fmt.Sprintf("retry after %s: %v", e.RetryAfter, e.Err)
+}
+
+func (e *ErrRetryAfter) Unwrap() error {
+	return e.Err
+}
+
+type OutboxKind string
+
+const (
+	OutboxKindNotification OutboxKind = "notification"
+	OutboxKindDigest       OutboxKind = "digest"
+	OutboxKindBroadcast    OutboxKind = "broadcast"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusDead    OutboxStatus = "dead"
+)
+
+// OutboxMessage is a notification persisted until it is delivered or given up on
+type OutboxMessage struct {
+	ID            int64        `db:"id"`
+	UserID        int          `db:"user_id"`
+	Kind          OutboxKind   `db:"kind"`
+	Payload       string       `db:"payload"`
+	Status        OutboxStatus `db:"status"`
+	Attempts      int          `db:"attempts"`
+	NextAttemptAt time.Time    `db:"next_attempt_at"`
+	LastError     *string      `db:"last_error"`
+	CreatedAt     time.Time    `db:"created_at"`
+}
+
+func newOutboxMessage(notif Notification, now time.Time) (OutboxMessage, error) {
+	return newOutboxMessageOfKind(OutboxKindNotification, notif.UserID, notif, now)
+}
+
+func newDigestOutboxMessage(digest Digest, now time.Time) (OutboxMessage, error) {
+	return newOutboxMessageOfKind(OutboxKindDigest, digest.UserID, digest, now)
+}
+
+func newBroadcastOutboxMessage(broadcast Broadcast, now time.Time) (OutboxMessage, error) {
+	return newOutboxMessageOfKind(OutboxKindBroadcast, broadcast.UserID, broadcast, now)
+}
+
+func newOutboxMessageOfKind(kind OutboxKind, userID int, content any, now time.Time) (OutboxMessage, error) {
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return OutboxMessage{}, ErrMarshal
+	}
+	return OutboxMessage{
+		UserID:        userID,
+		Kind:          kind,
+		Payload:       string(payload),
+		Status:        OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+func (m *OutboxMessage) Notification() (Notification, error) {
+	var notif Notification
+	if err := json.Unmarshal([]byte(m.Payload), &notif); err != nil {
+		return Notification{}, ErrUnmarshal
+	}
+	return notif, nil
+}
+
+func (m *OutboxMessage) Digest() (Digest, error) {
+	var digest Digest
+	if err := json.Unmarshal([]byte(m.Payload), &digest); err != nil {
+		return Digest{}, ErrUnmarshal
+	}
+	return digest, nil
+}
+
+func (m *OutboxMessage) Broadcast() (Broadcast, error) {
+	var broadcast Broadcast
+	if err := json.Unmarshal([]byte(m.Payload), &broadcast); err != nil {
+		return Broadcast{}, ErrUnmarshal
+	}
+	return broadcast, nil
 }
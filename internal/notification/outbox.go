@@ -0,0 +1,196 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+)
+
+const outboxBatchSize = 100
+
+// enqueue persists the notification, so it survives restarts and failed deliveries
+func (s *notificationService) enqueue(ctx context.Context, notif Notification) error {
+	msg, err := newOutboxMessage(notif, outboxNow())
+	if err != nil {
+		return err
+	}
+	return s.outbox.Enqueue(ctx, msg)
+}
+
+// Broadcast queues the text for every user, it is delivered and retried through the outbox like notifications.
+// Admin messages aren't about slots, so quiet hours and digests don't hold them back
+func (s *notificationService) Broadcast(ctx context.Context, userIDs []int, text string) (int, error) {
+	queued := 0
+	for _, userID := range userIDs {
+		msg, err := newBroadcastOutboxMessage(Broadcast{UserID: userID, Text: text}, outboxNow())
+		if err != nil {
+			return queued, err
+		}
+		if err = s.outbox.Enqueue(ctx, msg); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+func (s *notificationService) startOutboxLoop(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.options.OutboxPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.dispatchOutbox(ctx)
+			}
+		}
+	}()
+}
+
+// dispatchOutbox delivers due notifications until there is nothing left or Telegram asks to slow down
+func (s *notificationService) dispatchOutbox(ctx context.Context) {
+	for {
+		msgs, err := s.outbox.FindDue(ctx, outboxNow(), outboxBatchSize)
+		if err != nil {
+			slog.Error("Outbox error", "error", err, "service", logger.ServiceNotification)
+			return
+		}
+		for _, msg := range msgs {
+			if !s.deliver(ctx, msg) {
+				return
+			}
+		}
+		if len(msgs) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// deliver sends a single outbox message and reports whether the dispatching may go on
+func (s *notificationService) deliver(ctx context.Context, msg OutboxMessage) bool {
+	if err := s.limiter.Wait(ctx); err != nil {
+		slog.Error("Limiter error", "err", err, "service", logger.ServiceNotification)
+		return false
+	}
+
+	err := s.send(ctx, msg)
+	if errors.Is(err, ErrUnmarshal) {
+		s.markDead(ctx, msg, err)
+		return true
+	}
+	if err == nil {
+		recordNotification()
+		if err = s.outbox.Delete(ctx, msg.ID); err != nil {
+			slog.Error("Outbox error", "error", err, "service", logger.ServiceNotification)
+		}
+		return true
+	}
+
+	if errors.Is(err, ErrUndeliverable) {
+		s.markDead(ctx, msg, err)
+		return true
+	}
+
+	// Rate limiting is not the message's fault, so it doesn't count as an attempt
+	var retryErr *ErrRetryAfter
+	if errors.As(err, &retryErr) {
+		s.reschedule(ctx, msg, retryErr.RetryAfter, err)
+		return false
+	}
+
+	msg.Attempts++
+	if msg.Attempts >= s.options.MaxAttempts {
+		s.markDead(ctx, msg, err)
+		return true
+	}
+	s.reschedule(ctx, msg, retryDelay(msg.Attempts, s.options.RetryBaseDelay, s.options.RetryMaxDelay), err)
+	return true
+}
+
+func (s *notificationService) send(ctx context.Context, msg OutboxMessage) error {
+	switch msg.Kind {
+	case OutboxKindDigest:
+		digest, err := msg.Digest()
+		if err != nil {
+			return err
+		}
+		return s.notifier.SendDigest(ctx, digest)
+	case OutboxKindBroadcast:
+		broadcast, err := msg.Broadcast()
+		if err != nil {
+			return err
+		}
+		return s.notifier.SendBroadcast(ctx, broadcast)
+	default:
+		notif, err := msg.Notification()
+		if err != nil {
+			return err
+		}
+		return s.notifier.SendNotification(ctx, notif)
+	}
+}
+
+func (s *notificationService) reschedule(ctx context.Context, msg OutboxMessage, delay time.Duration, cause error) {
+	recordRetry()
+	lastErr := cause.Error()
+	msg.LastError = &lastErr
+	msg.NextAttemptAt = outboxNow().Add(delay)
+	slog.Warn("Notification delivery postponed",
+		"id", msg.ID,
+		"user_id", msg.UserID,
+		"attempts", msg.Attempts,
+		"delay", delay,
+		"error", cause,
+		"service", logger.ServiceNotification)
+	if err := s.outbox.Reschedule(ctx, msg); err != nil {
+		slog.Error("Outbox error", "error", err, "service", logger.ServiceNotification)
+	}
+}
+
+func (s *notificationService) markDead(ctx context.Context, msg OutboxMessage, cause error) {
+	recordDeadLetter()
+	lastErr := cause.Error()
+	msg.LastError = &lastErr
+	msg.Status = OutboxStatusDead
+	slog.Error("Notification moved to dead letters",
+		"id", msg.ID,
+		"user_id", msg.UserID,
+		"attempts", msg.Attempts,
+		"error", cause,
+		"service", logger.ServiceNotification)
+	if err := s.outbox.Reschedule(ctx, msg); err != nil {
+		slog.Error("Outbox error", "error", err, "service", logger.ServiceNotification)
+	}
+}
+
+func (s *notificationService) ListDeadNotifications(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	return s.outbox.FindDead(ctx, limit)
+}
+
+func (s *notificationService) ReplayDeadNotifications(ctx context.Context, ids ...int64) (int64, error) {
+	return s.outbox.Requeue(ctx, outboxNow(), ids...)
+}
+
+// retryDelay grows exponentially with every failed attempt, up to the max delay
+func retryDelay(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return min(delay, max)
+}
+
+// outboxNow is stored in UTC with second precision, so timestamps in SQLite compare correctly as text
+func outboxNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
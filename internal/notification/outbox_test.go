@@ -0,0 +1,93 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDelay(t *testing.T) {
+	type testCase struct {
+		attempts int
+		expected time.Duration
+	}
+
+	tests := []testCase{
+		{attempts: 1, expected: 5 * time.Second},
+		{attempts: 2, expected: 10 * time.Second},
+		{attempts: 4, expected: 40 * time.Second},
+		{attempts: 8, expected: time.Minute},
+		{attempts: 100, expected: time.Minute},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_retry_delay_%d", i), func(t *testing.T) {
+			assert.Equal(t, tCase.expected, retryDelay(tCase.attempts, 5*time.Second, time.Minute))
+		})
+	}
+}
+
+func TestOutboxRepo(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+
+	repo := NewOutboxRepo(db)
+	now := outboxNow()
+	notif := Notification{
+		Kind:   KindNewTimes,
+		UserID: 42,
+		Slot: polling.Slot{
+			Number:        7,
+			TimesTeachers: map[time.Time][]string{now.Add(time.Hour): {"Иванов И.И."}},
+		},
+	}
+	msg, err := newOutboxMessage(notif, now)
+	require.NoError(t, err)
+	require.NoError(t, repo.Enqueue(ctx, msg))
+
+	due, err := repo.FindDue(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	decoded, err := due[0].Notification()
+	require.NoError(t, err)
+	assert.Equal(t, notif.Kind, decoded.Kind)
+	assert.Equal(t, notif.UserID, decoded.UserID)
+	assert.Len(t, decoded.Slot.TimesTeachers, 1)
+
+	// Postponed messages are not due yet
+	msg = due[0]
+	msg.Attempts = 1
+	msg.NextAttemptAt = now.Add(time.Minute)
+	require.NoError(t, repo.Reschedule(ctx, msg))
+	due, err = repo.FindDue(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	msg.Status = OutboxStatusDead
+	require.NoError(t, repo.Reschedule(ctx, msg))
+	due, err = repo.FindDue(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	dead, err := repo.FindDead(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+
+	requeued, err := repo.Requeue(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), requeued)
+	due, err = repo.FindDue(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, 0, due[0].Attempts)
+
+	require.NoError(t, repo.Delete(ctx, due[0].ID))
+	due, err = repo.FindDue(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
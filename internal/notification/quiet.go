@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+)
+
+func (s *notificationService) GetPreferences(ctx context.Context, userID int) (*Preferences, error) {
+	prefs, err := s.prefs.Find(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return &Preferences{UserID: userID, Timezone: s.options.DefaultTimezone, DeliveryMode: DeliveryInstant}, nil
+	}
+	return prefs, err
+}
+
+// SetQuietHours stores the quiet window, nil disables it
+func (s *notificationService) SetQuietHours(ctx context.Context, userID int, quiet *QuietHours) error {
+	if quiet == nil {
+		prefs, err := s.GetPreferences(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return s.prefs.SetQuietHours(ctx, userID, nil, nil, prefs.Timezone)
+	}
+	timezone := quiet.Timezone
+	if timezone == "" {
+		timezone = s.options.DefaultTimezone
+	}
+	return s.prefs.SetQuietHours(ctx, userID, &quiet.Start, &quiet.End, timezone)
+}
+
+// schedule enqueues the notification right away, or holds it until the next digest or the end of the user's quiet hours
+func (s *notificationService) schedule(ctx context.Context, notif Notification) error {
+	now := outboxNow()
+	prefs, err := s.GetPreferences(ctx, notif.UserID)
+	if err != nil {
+		// Better to disturb the user than to lose the notification
+		slog.Error("Failed to get preferences", "error", err, "user_id", notif.UserID, "service", logger.ServiceNotification)
+		return s.enqueue(ctx, notif)
+	}
+
+	releaseAt := now
+	if digestAt, ok := s.nextDigest(prefs, now); ok {
+		releaseAt = digestAt
+	}
+	quietEnd, quiet := quietUntil(prefs.QuietHours(), releaseAt)
+	if quiet && !s.isUrgent(notif, quietEnd) {
+		releaseAt = quietEnd
+	}
+	if !releaseAt.After(now) {
+		return s.enqueue(ctx, notif)
+	}
+
+	held, err := newHeldNotification(notif, releaseAt.UTC(), now)
+	if err != nil {
+		return err
+	}
+	recordHeld()
+	return s.held.Hold(ctx, held)
+}
+
+// isUrgent reports whether some of the slot times start too soon after the quiet hours to wait for them
+func (s *notificationService) isUrgent(notif Notification, quietEnd time.Time) bool {
+	if notif.Kind == KindTimesTaken || s.options.QuietUrgentWithin <= 0 {
+		return false
+	}
+	deadline := quietEnd.Add(s.options.QuietUrgentWithin)
+	for t := range notif.Slot.TimesTeachers {
+		if t.Before(deadline) {
+			return true
+		}
+	}
+	return false
+}
+
+// quietUntil returns the end of the quiet window when the moment falls into it
+func quietUntil(quiet *QuietHours, now time.Time) (time.Time, bool) {
+	if quiet == nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(quiet.Timezone)
+	if err != nil {
+		slog.Warn("Unknown time zone", "timezone", quiet.Timezone, "service", logger.ServiceNotification)
+		loc = time.UTC
+	}
+	_, errStart := time.Parse("15:04", quiet.Start)
+	end, errEnd := time.Parse("15:04", quiet.End)
+	if errStart != nil || errEnd != nil || quiet.Start == quiet.End {
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	clock := local.Format("15:04")
+	var inWindow bool
+	if quiet.Start < quiet.End {
+		inWindow = clock >= quiet.Start && clock < quiet.End
+	} else {
+		inWindow = clock >= quiet.Start || clock < quiet.End
+	}
+	if !inWindow {
+		return time.Time{}, false
+	}
+
+	windowEnd := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if !windowEnd.After(local) {
+		windowEnd = time.Date(local.Year(), local.Month(), local.Day()+1, end.Hour(), end.Minute(), 0, 0, loc)
+	}
+	return windowEnd, true
+}
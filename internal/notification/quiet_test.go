@@ -0,0 +1,103 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuietUntil(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+
+	type testCase struct {
+		quiet       *QuietHours
+		now         time.Time
+		expectedEnd time.Time
+		expectedOk  bool
+	}
+
+	tests := []testCase{
+		{quiet: nil, now: time.Date(2025, 11, 20, 3, 0, 0, 0, moscow)},
+		{
+			quiet:       &QuietHours{Start: "23:00", End: "08:00", Timezone: "Europe/Moscow"},
+			now:         time.Date(2025, 11, 20, 3, 0, 0, 0, moscow),
+			expectedEnd: time.Date(2025, 11, 20, 8, 0, 0, 0, moscow),
+			expectedOk:  true,
+		},
+		{
+			quiet:       &QuietHours{Start: "23:00", End: "08:00", Timezone: "Europe/Moscow"},
+			now:         time.Date(2025, 11, 20, 23, 30, 0, 0, moscow),
+			expectedEnd: time.Date(2025, 11, 21, 8, 0, 0, 0, moscow),
+			expectedOk:  true,
+		},
+		{
+			quiet: &QuietHours{Start: "23:00", End: "08:00", Timezone: "Europe/Moscow"},
+			now:   time.Date(2025, 11, 20, 12, 0, 0, 0, moscow),
+		},
+		{
+			quiet:       &QuietHours{Start: "13:00", End: "15:00", Timezone: "Europe/Moscow"},
+			now:         time.Date(2025, 11, 20, 13, 0, 0, 0, moscow),
+			expectedEnd: time.Date(2025, 11, 20, 15, 0, 0, 0, moscow),
+			expectedOk:  true,
+		},
+		// The host clock is in UTC, while the window is in the user's time zone
+		{
+			quiet:       &QuietHours{Start: "23:00", End: "08:00", Timezone: "Europe/Moscow"},
+			now:         time.Date(2025, 11, 20, 21, 0, 0, 0, time.UTC),
+			expectedEnd: time.Date(2025, 11, 21, 8, 0, 0, 0, moscow),
+			expectedOk:  true,
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_quiet_until_%d", i), func(t *testing.T) {
+			end, ok := quietUntil(tCase.quiet, tCase.now)
+			assert.Equal(t, tCase.expectedOk, ok)
+			if tCase.expectedOk {
+				assert.True(t, tCase.expectedEnd.Equal(end), "expected %v, got %v", tCase.expectedEnd, end)
+			}
+		})
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+	service := &notificationService{
+		options: config.NotificationConfig{DefaultTimezone: "UTC", HourlyDigestSpec: "0 * * * *"},
+		outbox:  NewOutboxRepo(db),
+		prefs:   NewPreferenceRepo(db),
+		held:    NewHeldRepo(db),
+	}
+
+	now := time.Now().UTC()
+	require.NoError(t, service.SetQuietHours(ctx, 2, &QuietHours{
+		Start:    now.Add(-time.Hour).Format("15:04"),
+		End:      now.Add(time.Hour).Format("15:04"),
+		Timezone: "UTC",
+	}))
+	// Digest users get new subscription notifications with the next digest too
+	require.NoError(t, service.SetDeliveryMode(ctx, 3, DeliveryHourly))
+
+	for _, userID := range []int{1, 2, 3} {
+		require.NoError(t, service.schedule(ctx, Notification{UserID: userID}))
+	}
+
+	due, err := service.outbox.FindDue(ctx, outboxNow(), outboxBatchSize)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, 1, due[0].UserID)
+
+	var held []int
+	require.NoError(t, db.SelectContext(ctx, &held, `select user_id from held_notifications order by user_id`))
+	assert.Equal(t, []int{2, 3}, held)
+}
@@ -2,10 +2,12 @@ package notification
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/Ademun/mining-lab-bot/internal/calendar"
 	"github.com/Ademun/mining-lab-bot/internal/polling"
 	"github.com/Ademun/mining-lab-bot/internal/subscription"
 	"github.com/Ademun/mining-lab-bot/pkg/config"
@@ -20,32 +22,58 @@ type Service interface {
 	Stop(ctx context.Context)
 	SendNotification(ctx context.Context, slot polling.Slot)
 	NotifyNewSubscription(ctx context.Context, sub subscription.RequestSubscription)
+	NotifyCatalogChange(ctx context.Context, diff polling.CatalogDiff)
+	ObserveSlots(ctx context.Context, slots []polling.Slot)
+	Broadcast(ctx context.Context, userIDs []int, text string) (int, error)
+	ListDeadNotifications(ctx context.Context, limit int) ([]OutboxMessage, error)
+	ReplayDeadNotifications(ctx context.Context, ids ...int64) (int64, error)
+	GetPreferences(ctx context.Context, userID int) (*Preferences, error)
+	SetQuietHours(ctx context.Context, userID int, quiet *QuietHours) error
+	SetDeliveryMode(ctx context.Context, userID int, mode DeliveryMode) error
+	CountSlots(ctx context.Context) (int, error)
 }
 
 type notificationService struct {
 	subService    subscription.Service
 	notifier      SlotNotifier
+	calendar      calendar.Calendar
+	location      *time.Location
 	options       config.NotificationConfig
 	limiter       *rate.Limiter
 	cache         SlotCache
+	ledger        NotificationLedger
+	outbox        OutboxRepo
+	prefs         PreferenceRepo
+	held          HeldRepo
+	history       SlotHistory
 	cronScheduler *cron.Cron
+	wg            sync.WaitGroup
 	mu            sync.Mutex
 }
 
-func New(subService subscription.Service, notifier SlotNotifier, client *redis.Client, opts *config.NotificationConfig) Service {
+// New creates the notification service, the slot history is optional
+func New(subService subscription.Service, notifier SlotNotifier, cal calendar.Calendar, loc *time.Location, client *redis.Client, outbox OutboxRepo, prefs PreferenceRepo, held HeldRepo, history SlotHistory, opts *config.NotificationConfig) Service {
 	return &notificationService{
 		subService: subService,
 		notifier:   notifier,
+		calendar:   cal,
+		location:   loc,
 		options:    *opts,
 		limiter:    rate.NewLimiter(opts.NotificationRate, 1),
 		cache:      *NewSlotCache(client),
+		ledger:     *NewNotificationLedger(client),
+		outbox:     outbox,
+		prefs:      prefs,
+		held:       held,
+		history:    history,
+		wg:         sync.WaitGroup{},
 		mu:         sync.Mutex{},
 	}
 }
 
 func (s *notificationService) Start(ctx context.Context) error {
 	slog.Info("Starting", "service", logger.ServiceNotification)
-	c := cron.New(cron.WithLocation(time.Local))
+	c := cron.New(cron.WithLocation(s.location))
 	_, err := c.AddFunc("0 0 * * *", func() {
 		slog.Info("Resetting unique cache", "service", logger.ServiceNotification)
 		s.resetUniqueSlots(ctx)
@@ -53,16 +81,30 @@ func (s *notificationService) Start(ctx context.Context) error {
 	if err != nil {
 		slog.Info("Cron error", "error", err, "service", logger.ServiceNotification)
 	}
+	_, err = c.AddFunc("* * * * *", func() {
+		s.releaseHeld(ctx)
+	})
+	if err != nil {
+		slog.Info("Cron error", "error", err, "service", logger.ServiceNotification)
+	}
 	c.Start()
 	s.cronScheduler = c
+	s.startOutboxLoop(ctx)
 	slog.Info("Started", "service", logger.ServiceNotification)
 	return nil
 }
 
 func (s *notificationService) Stop(ctx context.Context) {
 	cronCtx := s.cronScheduler.Stop()
+	done := make(chan struct{})
+	go func() {
+		<-cronCtx.Done()
+		s.wg.Wait()
+		close(done)
+	}()
+
 	select {
-	case <-cronCtx.Done():
+	case <-done:
 		slog.Info("Stopped", "service", logger.ServiceNotification)
 		return
 	case <-ctx.Done():
@@ -72,52 +114,83 @@ func (s *notificationService) Stop(ctx context.Context) {
 
 func (s *notificationService) SendNotification(ctx context.Context, slot polling.Slot) {
 	defer s.trackSlot(ctx, slot)
-	exists, err := s.cache.Exists(ctx, s.options.RedisPrefix+slot.Key())
-	if err != nil {
+	if s.history != nil {
+		s.history.Record(ctx, slot)
+	}
+	slot.TimesTeachers = s.filterDaysOff(slot.TimesTeachers)
+	if len(slot.TimesTeachers) == 0 {
+		return
+	}
+	key := s.options.RedisPrefix + slot.Key()
+	cached, err := s.cache.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		slog.Error("Redis error", "error", err, "service", logger.ServiceNotification)
 		return
 	}
 
-	if exists {
-		// Since the slot can have different available times, we should update the old one
-		if err := s.cache.Set(ctx, slot, s.options.RedisPrefix+slot.Key(), s.options.CacheTTL); err != nil {
-			slog.Error("Redis error", "error", err, "service", logger.ServiceNotification)
-		}
+	// Since the slot can have different available times, the cached one is always replaced
+	if err := s.cache.Set(ctx, slot, key, s.options.CacheTTL); err != nil {
+		slog.Error("Redis error", "error", err, "service", logger.ServiceNotification)
 		return
 	}
 
-	recordSlot(slot.Type)
-
-	if err := s.cache.Set(ctx, slot, s.options.RedisPrefix+slot.Key(), s.options.CacheTTL); err != nil {
-		slog.Error("Redis error", "error", err, "service", logger.ServiceNotification)
+	if cached == nil {
+		recordSlot(slot.Type)
+		s.notifyUsers(ctx, slot, KindNewSlot)
 		return
 	}
 
+	added, removed := diffTimes(cached.TimesTeachers, slot.TimesTeachers)
+	if len(added) > 0 {
+		addedSlot := slot
+		addedSlot.TimesTeachers = added
+		s.notifyUsers(ctx, addedSlot, KindNewTimes)
+	}
+	if len(removed) > 0 && s.options.NotifyTimesTaken {
+		removedSlot := slot
+		removedSlot.TimesTeachers = removed
+		s.notifyUsers(ctx, removedSlot, KindTimesTaken)
+	}
+}
+
+func (s *notificationService) notifyUsers(ctx context.Context, slot polling.Slot, kind NotificationKind) {
 	users, err := s.subService.FindUsersBySlotInfo(ctx, slot)
 	if err != nil {
 		slog.Error("Failed to find users", "slot", slot, "err", err, "service", logger.ServiceNotification)
 		return
 	}
 
+	total := 0
 	for _, user := range users {
+		// Changes of a known slot are only interesting if they touch the user's preferred times
+		if kind != KindNewSlot && !hasPreferredTime(slot.TimesTeachers, user.PreferredTimes, s.location) {
+			continue
+		}
 		notif := Notification{
+			Kind:           kind,
 			UserID:         user.UserID,
 			PreferredTimes: user.PreferredTimes,
 			Slot:           slot,
 		}
-		if err = s.limiter.Wait(ctx); err != nil {
-			slog.Error("Limiter error", "err", err, "service", logger.ServiceNotification)
-			return
+		if kind != KindTimesTaken && !s.filterSeenTimes(ctx, &notif) {
+			recordDeduplicated()
+			continue
+		}
+		if err = s.schedule(ctx, notif); err != nil {
+			slog.Error("Failed to enqueue notification", "error", err, "user_id", user.UserID, "service", logger.ServiceNotification)
+			continue
 		}
-		recordNotification()
-		s.notifier.SendNotification(ctx, notif)
+		s.recordSeenTimes(ctx, notif)
+		total++
 	}
 
-	if len(users) > 0 {
-		slog.Info("Finished sending notifications", "total", len(users), "slot", slot, "service", logger.ServiceNotification)
+	if total > 0 {
+		slog.Info("Finished enqueuing notifications", "total", total, "kind", kind, "slot", slot, "service", logger.ServiceNotification)
 	}
 }
 
+// NotifyNewSubscription tells the user about the open slots matching the new subscription.
+// The notifications follow quiet hours and digests like any other notification
 func (s *notificationService) NotifyNewSubscription(ctx context.Context, sub subscription.RequestSubscription) {
 	slots, err := s.findSlotsBySubscriptionInfo(ctx, sub)
 	if err != nil {
@@ -125,29 +198,130 @@ func (s *notificationService) NotifyNewSubscription(ctx context.Context, sub sub
 		return
 	}
 
-	prefTimes := subscription.GetSubscriptionPreferredTimes(sub)
 	for _, slot := range slots {
+		prefTimes, _ := s.subService.SlotPreferredTimes(sub, slot)
 		notif := Notification{
 			UserID:         sub.UserID,
 			PreferredTimes: prefTimes,
 			Slot:           slot,
 		}
-		if err = s.limiter.Wait(ctx); err != nil {
-			slog.Error("Limiter error", "err", err, "service", logger.ServiceNotification)
-			return
+		if err = s.schedule(ctx, notif); err != nil {
+			slog.Error("Failed to enqueue notification", "error", err, "user_id", sub.UserID, "service", logger.ServiceNotification)
+			continue
+		}
+		s.recordSeenTimes(ctx, notif)
+	}
+
+	slog.Info("Finished enqueuing notifications", "total", len(slots), "sub", sub, "service", logger.ServiceNotification)
+}
+
+// ObserveSlots passes the slots on to the history, so that times the poller still sees aren't closed
+func (s *notificationService) ObserveSlots(ctx context.Context, slots []polling.Slot) {
+	if s.history != nil {
+		s.history.Observe(ctx, slots)
+	}
+}
+
+// NotifyCatalogChange sends the admin a summary of the changes and tells subscribers about the labs they want
+// that got published. The announcement follows quiet hours and digests like any other notification
+func (s *notificationService) NotifyCatalogChange(ctx context.Context, diff polling.CatalogDiff) {
+	if err := s.notifier.SendCatalogDiff(ctx, diff); err != nil {
+		slog.Error("Failed to send catalog changes", "error", err, "company", diff.CompanyID, "service", logger.ServiceNotification)
+	}
+
+	total := 0
+	for _, lab := range diff.Published {
+		users, err := s.subService.FindUsersBySlotInfo(ctx, lab)
+		if err != nil {
+			slog.Error("Failed to find users", "slot", lab, "err", err, "service", logger.ServiceNotification)
+			continue
+		}
+		for _, user := range users {
+			notif := Notification{
+				Kind:           KindLabPublished,
+				UserID:         user.UserID,
+				PreferredTimes: user.PreferredTimes,
+				Slot:           lab,
+			}
+			if err = s.schedule(ctx, notif); err != nil {
+				slog.Error("Failed to enqueue notification", "error", err, "user_id", user.UserID, "service", logger.ServiceNotification)
+				continue
+			}
+			total++
+		}
+	}
+	if total > 0 {
+		slog.Info("Finished enqueuing notifications", "total", total, "kind", KindLabPublished, "company", diff.CompanyID, "service", logger.ServiceNotification)
+	}
+}
+
+// filterDaysOff drops times on holidays and Sundays. Exams and days out of the semester are kept,
+// labs are still booked then and outdated calendar dates must not silence notifications
+func (s *notificationService) filterDaysOff(times map[time.Time][]string) map[time.Time][]string {
+	filtered := make(map[time.Time][]string, len(times))
+	for t, teachers := range times {
+		if s.calendar.IsDayOff(t) {
+			slog.Debug("Skipping slot time on a day off", "time", t, "service", logger.ServiceNotification)
+			continue
 		}
-		recordNotification()
-		s.notifier.SendNotification(ctx, notif)
+		filtered[t] = teachers
 	}
+	return filtered
+}
+
+// filterSeenTimes checks the ledger and reports whether the notification still has something new for the user.
+// Notifications about new times are narrowed down to the unseen ones
+func (s *notificationService) filterSeenTimes(ctx context.Context, notif *Notification) bool {
+	unseen, err := s.ledger.FilterUnseen(ctx, notif.UserID, notif.Slot)
+	if err != nil {
+		// Better to notify twice than to miss a slot
+		slog.Error("Redis error", "error", err, "service", logger.ServiceNotification)
+		return true
+	}
+	if len(unseen) == 0 {
+		return false
+	}
+	if notif.Kind == KindNewTimes {
+		notif.Slot.TimesTeachers = unseen
+	}
+	return true
+}
 
-	slog.Info("Finished sending notifications", "total", len(slots), "sub", sub, "service", logger.ServiceNotification)
+func (s *notificationService) recordSeenTimes(ctx context.Context, notif Notification) {
+	if err := s.ledger.Record(ctx, notif.UserID, notif.Slot, s.options.ResendCooldown); err != nil {
+		slog.Error("Redis error", "error", err, "service", logger.ServiceNotification)
+	}
+}
+
+// CountSlots returns the number of currently open slots
+func (s *notificationService) CountSlots(ctx context.Context) (int, error) {
+	total := 0
+	cacheSlots, errChan := s.cache.ListSlots(ctx, s.options.RedisPrefix)
+	for cacheSlots != nil || errChan != nil {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case _, ok := <-cacheSlots:
+			if !ok {
+				cacheSlots = nil
+				continue
+			}
+			total++
+		case err, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			return 0, err
+		}
+	}
+	return total, nil
 }
 
 func (s *notificationService) findSlotsBySubscriptionInfo(ctx context.Context, sub subscription.RequestSubscription) ([]polling.Slot, error) {
 	slog.Info("sub", "sub", sub)
 	items := make([]polling.Slot, 0)
 	cacheSlots, errChan := s.cache.ListSlots(ctx, s.options.RedisPrefix)
-	prefTimes := subscription.LessonsToTimeRanges(sub.Lessons...)
 	for cacheSlots != nil || errChan != nil {
 		select {
 		case <-ctx.Done():
@@ -157,6 +331,9 @@ func (s *notificationService) findSlotsBySubscriptionInfo(ctx context.Context, s
 				cacheSlots = nil
 				continue
 			}
+			if sub.CompanyID != nil && slot.CompanyID != *sub.CompanyID {
+				continue
+			}
 			if slot.Type != sub.Type {
 				continue
 			}
@@ -173,7 +350,7 @@ func (s *notificationService) findSlotsBySubscriptionInfo(ctx context.Context, s
 					continue
 				}
 			}
-			if matchesPreferredTimes(slot.TimesTeachers, sub.Weekday, prefTimes) {
+			if _, ok := s.subService.SlotPreferredTimes(sub, slot); ok {
 				items = append(items, slot)
 			}
 		case err, ok := <-errChan:
@@ -187,18 +364,50 @@ func (s *notificationService) findSlotsBySubscriptionInfo(ctx context.Context, s
 	return items, nil
 }
 
-func matchesPreferredTimes(slotTimes map[time.Time][]string, subWeekday *int, prefTimes []subscription.TimeRange) bool {
-	if subWeekday == nil {
+// diffTimes compares slot times by instant, since cached times lose their location after a round trip through Redis
+func diffTimes(oldTimes, newTimes map[time.Time][]string) (added, removed map[time.Time][]string) {
+	oldSet := make(map[int64]struct{}, len(oldTimes))
+	for t := range oldTimes {
+		oldSet[t.Unix()] = struct{}{}
+	}
+	newSet := make(map[int64]struct{}, len(newTimes))
+	for t := range newTimes {
+		newSet[t.Unix()] = struct{}{}
+	}
+
+	added = make(map[time.Time][]string)
+	for t, teachers := range newTimes {
+		if _, ok := oldSet[t.Unix()]; !ok {
+			added[t] = teachers
+		}
+	}
+	removed = make(map[time.Time][]string)
+	for t, teachers := range oldTimes {
+		if _, ok := newSet[t.Unix()]; !ok {
+			removed[t] = teachers
+		}
+	}
+	return added, removed
+}
+
+// hasPreferredTime reports whether any of the times falls into the preferred times.
+// Users without weekday preferences are interested in every time. Preferred times are campus wall clock times
+func hasPreferredTime(slotTimes map[time.Time][]string, prefTimes PreferredTimes, loc *time.Location) bool {
+	if len(prefTimes) == 0 {
 		return true
 	}
-	for slotTime := range slotTimes {
-		slotWeekday := int(slotTime.Weekday())
-		if slotWeekday != *subWeekday {
+	for t := range slotTimes {
+		slotTime := t.In(loc)
+		timeRanges, ok := prefTimes[slotTime.Weekday()]
+		if !ok {
 			continue
 		}
+		if len(timeRanges) == 0 {
+			return true
+		}
 		slotTimeStr := slotTime.Format("15:04")
-		for _, prefTime := range prefTimes {
-			if slotTimeStr >= prefTime.TimeStart && slotTimeStr < prefTime.TimeEnd {
+		for _, timeRange := range timeRanges {
+			if slotTimeStr >= timeRange.TimeStart && slotTimeStr < timeRange.TimeEnd {
 				return true
 			}
 		}
@@ -0,0 +1,192 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/subscription"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestDiffTimes(t *testing.T) {
+	first := time.Date(2025, 11, 20, 8, 50, 0, 0, time.UTC)
+	second := time.Date(2025, 11, 20, 10, 35, 0, 0, time.UTC)
+	third := time.Date(2025, 11, 21, 12, 35, 0, 0, time.UTC)
+	// The same instant as first, but decoded from JSON with a fixed zone
+	firstFixed := first.In(time.FixedZone("", 3*60*60))
+
+	type testCase struct {
+		oldTimes        map[time.Time][]string
+		newTimes        map[time.Time][]string
+		expectedAdded   []time.Time
+		expectedRemoved []time.Time
+	}
+
+	tests := []testCase{
+		{
+			oldTimes:      map[time.Time][]string{first: nil},
+			newTimes:      map[time.Time][]string{first: nil, second: nil},
+			expectedAdded: []time.Time{second},
+		},
+		{
+			oldTimes:        map[time.Time][]string{first: nil, second: nil},
+			newTimes:        map[time.Time][]string{second: nil, third: nil},
+			expectedAdded:   []time.Time{third},
+			expectedRemoved: []time.Time{first},
+		},
+		{
+			oldTimes: map[time.Time][]string{firstFixed: nil},
+			newTimes: map[time.Time][]string{first: nil},
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_diff_times_%d", i), func(t *testing.T) {
+			added, removed := diffTimes(tCase.oldTimes, tCase.newTimes)
+			assert.ElementsMatch(t, tCase.expectedAdded, keys(added))
+			assert.ElementsMatch(t, tCase.expectedRemoved, keys(removed))
+		})
+	}
+}
+
+func TestHasPreferredTime(t *testing.T) {
+	// 2025-11-20 is Thursday
+	slotTimes := map[time.Time][]string{
+		time.Date(2025, 11, 20, 12, 35, 0, 0, time.UTC): nil,
+	}
+
+	type testCase struct {
+		prefTimes PreferredTimes
+		expected  bool
+	}
+
+	tests := []testCase{
+		{prefTimes: nil, expected: true},
+		{prefTimes: PreferredTimes{time.Thursday: nil}, expected: true},
+		{prefTimes: PreferredTimes{time.Friday: nil}, expected: false},
+		{prefTimes: PreferredTimes{time.Thursday: {{TimeStart: "12:35", TimeEnd: "14:05"}}}, expected: true},
+		{prefTimes: PreferredTimes{time.Thursday: {{TimeStart: "08:50", TimeEnd: "10:20"}}}, expected: false},
+		{prefTimes: PreferredTimes{time.Thursday: []subscription.TimeRange{}}, expected: true},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_has_preferred_time_%d", i), func(t *testing.T) {
+			assert.Equal(t, tCase.expected, hasPreferredTime(slotTimes, tCase.prefTimes, time.UTC))
+		})
+	}
+}
+
+func TestPreferredTimesIgnoreHostTimezone(t *testing.T) {
+	campus := testutil.Campus(t)
+	// 12:35 on Thursday at the campus is 09:35 UTC and still Wednesday in Los Angeles
+	slotTimes := map[time.Time][]string{
+		time.Date(2025, 11, 20, 9, 35, 0, 0, time.UTC): nil,
+	}
+	thursday := []subscription.TimeRange{{TimeStart: "12:35", TimeEnd: "14:05"}}
+
+	for i, hostTimezone := range []string{"UTC", "America/Los_Angeles", "Asia/Tokyo"} {
+		t.Run(fmt.Sprintf("test_host_timezone_%d", i), func(t *testing.T) {
+			testutil.SetHostTimezone(t, hostTimezone)
+			localTimes := make(map[time.Time][]string, len(slotTimes))
+			for slotTime, teachers := range slotTimes {
+				localTimes[slotTime.Local()] = teachers
+			}
+			assert.True(t, hasPreferredTime(localTimes, PreferredTimes{time.Thursday: thursday}, campus))
+		})
+	}
+}
+
+// recordingSlotNotifier keeps everything it was asked to send
+type recordingSlotNotifier struct {
+	notifs     []Notification
+	diffs      []polling.CatalogDiff
+	broadcasts []Broadcast
+}
+
+func (n *recordingSlotNotifier) SendNotification(_ context.Context, notif Notification) error {
+	n.notifs = append(n.notifs, notif)
+	return nil
+}
+
+func (n *recordingSlotNotifier) SendDigest(_ context.Context, _ Digest) error {
+	return nil
+}
+
+func (n *recordingSlotNotifier) SendBroadcast(_ context.Context, broadcast Broadcast) error {
+	n.broadcasts = append(n.broadcasts, broadcast)
+	return nil
+}
+
+func (n *recordingSlotNotifier) SendCatalogDiff(_ context.Context, diff polling.CatalogDiff) error {
+	n.diffs = append(n.diffs, diff)
+	return nil
+}
+
+func TestNotifyCatalogChange(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+	campus := testutil.Campus(t)
+
+	// Subscriptions without lessons don't need the bell schedule
+	subService := subscription.New(subscription.NewRepo(db), nil, campus)
+	auditorium, otherAuditorium, weekday := 233, 512, int(time.Monday)
+	require.NoError(t, subService.Subscribe(ctx, subscription.RequestSubscription{UserID: 1, Type: polling.LabTypePerformance, LabNumber: 7, LabAuditorium: &auditorium, Weekday: &weekday}))
+	require.NoError(t, subService.Subscribe(ctx, subscription.RequestSubscription{UserID: 2, Type: polling.LabTypePerformance, LabNumber: 7, LabAuditorium: &otherAuditorium}))
+
+	notifier := &recordingSlotNotifier{}
+	service := &notificationService{
+		subService: subService,
+		notifier:   notifier,
+		location:   campus,
+		options:    config.NotificationConfig{MaxAttempts: 1},
+		limiter:    rate.NewLimiter(rate.Inf, 1),
+		outbox:     NewOutboxRepo(db),
+		prefs:      NewPreferenceRepo(db),
+		held:       NewHeldRepo(db),
+	}
+
+	lab := polling.Slot{CompanyID: 550001, CompanyName: "Тестовая кафедра", Type: polling.LabTypePerformance, Number: 7, Auditorium: auditorium}
+	service.NotifyCatalogChange(ctx, polling.CatalogDiff{CompanyID: 550001, Published: []polling.Slot{lab}})
+	service.dispatchOutbox(ctx)
+
+	require.Len(t, notifier.diffs, 1)
+	require.Len(t, notifier.notifs, 1)
+	assert.Equal(t, 1, notifier.notifs[0].UserID)
+	assert.Equal(t, KindLabPublished, notifier.notifs[0].Kind)
+	assert.Equal(t, lab.Key(), notifier.notifs[0].Slot.Key())
+}
+
+func TestBroadcast(t *testing.T) {
+	ctx := context.Background()
+	notifier := &recordingSlotNotifier{}
+	service := &notificationService{
+		notifier: notifier,
+		options:  config.NotificationConfig{MaxAttempts: 1},
+		limiter:  rate.NewLimiter(rate.Inf, 1),
+		outbox:   NewOutboxRepo(testutil.DB(t)),
+	}
+
+	// Admin text isn't HTML, it must reach users as typed
+	text := "Лабы <b>не</b> будет & расходимся"
+	queued, err := service.Broadcast(ctx, []int{1, 2}, text)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+
+	service.dispatchOutbox(ctx)
+	assert.ElementsMatch(t, []Broadcast{{UserID: 1, Text: text}, {UserID: 2, Text: text}}, notifier.broadcasts)
+}
+
+func keys(times map[time.Time][]string) []time.Time {
+	result := make([]time.Time, 0, len(times))
+	for t := range times {
+		result = append(result, t)
+	}
+	return result
+}
@@ -0,0 +1,221 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/errs"
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+type OutboxRepo interface {
+	Enqueue(ctx context.Context, msg OutboxMessage) error
+	FindDue(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error)
+	FindDead(ctx context.Context, limit int) ([]OutboxMessage, error)
+	Delete(ctx context.Context, id int64) error
+	Reschedule(ctx context.Context, msg OutboxMessage) error
+	// Requeue moves dead messages back to pending. Without ids every dead message is requeued
+	Requeue(ctx context.Context, now time.Time, ids ...int64) (int64, error)
+}
+
+type outboxRepo struct {
+	db *sqlx.DB
+}
+
+func NewOutboxRepo(db *sqlx.DB) OutboxRepo {
+	return &outboxRepo{db: db}
+}
+
+const outboxInsert = `
+insert into notification_outbox
+(user_id, kind, payload, status, attempts, next_attempt_at, created_at)
+values
+(:user_id, :kind, :payload, :status, :attempts, :next_attempt_at, :created_at)`
+
+func (r *outboxRepo) Enqueue(ctx context.Context, msg OutboxMessage) error {
+	if _, err := r.db.NamedExecContext(ctx, outboxInsert, msg); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Enqueue", Query: outboxInsert, Err: err}
+	}
+	return nil
+}
+
+func (r *outboxRepo) FindDue(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error) {
+	query := `
+select * from notification_outbox
+where status = ? and next_attempt_at <= ?
+order by next_attempt_at, id
+limit ?`
+	var msgs []OutboxMessage
+	if err := r.db.SelectContext(ctx, &msgs, query, OutboxStatusPending, now, limit); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindDue", Query: query, Err: err}
+	}
+	return msgs, nil
+}
+
+func (r *outboxRepo) FindDead(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	query := `select * from notification_outbox where status = ? order by id desc limit ?`
+	var msgs []OutboxMessage
+	if err := r.db.SelectContext(ctx, &msgs, query, OutboxStatusDead, limit); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindDead", Query: query, Err: err}
+	}
+	return msgs, nil
+}
+
+func (r *outboxRepo) Delete(ctx context.Context, id int64) error {
+	query := `delete from notification_outbox where id = ?`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Delete", Query: query, Err: err}
+	}
+	return nil
+}
+
+func (r *outboxRepo) Reschedule(ctx context.Context, msg OutboxMessage) error {
+	query := `
+update notification_outbox
+set status = :status, attempts = :attempts, next_attempt_at = :next_attempt_at, last_error = :last_error
+where id = :id`
+	if _, err := r.db.NamedExecContext(ctx, query, msg); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Reschedule", Query: query, Err: err}
+	}
+	return nil
+}
+
+func (r *outboxRepo) Requeue(ctx context.Context, now time.Time, ids ...int64) (int64, error) {
+	q := squirrel.Update("notification_outbox").
+		Set("status", OutboxStatusPending).
+		Set("attempts", 0).
+		Set("next_attempt_at", now).
+		Where(squirrel.Eq{"status": OutboxStatusDead})
+	if len(ids) > 0 {
+		q = q.Where(squirrel.Eq{"id": ids})
+	}
+	query, args, err := q.ToSql()
+	if err != nil {
+		return 0, &errs.ErrQueryCreation{Operation: "Requeue", Query: query, Err: err}
+	}
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, &errs.ErrQueryExecution{Operation: "Requeue", Query: query, Err: err}
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, &errs.ErrQueryExecution{Operation: "Requeue", Query: query, Err: err}
+	}
+	return affected, nil
+}
+
+type PreferenceRepo interface {
+	// Find returns ErrNotFound when the user has never changed the preferences
+	Find(ctx context.Context, userID int) (*Preferences, error)
+	SetQuietHours(ctx context.Context, userID int, start, end *string, timezone string) error
+	SetDeliveryMode(ctx context.Context, userID int, mode DeliveryMode, timezone string) error
+}
+
+type preferenceRepo struct {
+	db *sqlx.DB
+}
+
+func NewPreferenceRepo(db *sqlx.DB) PreferenceRepo {
+	return &preferenceRepo{db: db}
+}
+
+func (r *preferenceRepo) Find(ctx context.Context, userID int) (*Preferences, error) {
+	query := `select * from user_preferences where user_id = ?`
+	var prefs Preferences
+	if err := r.db.GetContext(ctx, &prefs, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, &errs.ErrQueryExecution{Operation: "Find", Query: query, Err: err}
+	}
+	return &prefs, nil
+}
+
+func (r *preferenceRepo) SetQuietHours(ctx context.Context, userID int, start, end *string, timezone string) error {
+	query := `
+insert into user_preferences (user_id, quiet_start, quiet_end, timezone)
+values (?, ?, ?, ?)
+on conflict (user_id) do update
+set quiet_start = excluded.quiet_start, quiet_end = excluded.quiet_end, timezone = excluded.timezone`
+	if _, err := r.db.ExecContext(ctx, query, userID, start, end, timezone); err != nil {
+		return &errs.ErrQueryExecution{Operation: "SetQuietHours", Query: query, Err: err}
+	}
+	return nil
+}
+
+func (r *preferenceRepo) SetDeliveryMode(ctx context.Context, userID int, mode DeliveryMode, timezone string) error {
+	query := `
+insert into user_preferences (user_id, timezone, delivery_mode)
+values (?, ?, ?)
+on conflict (user_id) do update
+set delivery_mode = excluded.delivery_mode`
+	if _, err := r.db.ExecContext(ctx, query, userID, timezone, mode); err != nil {
+		return &errs.ErrQueryExecution{Operation: "SetDeliveryMode", Query: query, Err: err}
+	}
+	return nil
+}
+
+type HeldRepo interface {
+	Hold(ctx context.Context, held HeldNotification) error
+	FindReleased(ctx context.Context, now time.Time) ([]HeldNotification, error)
+	// Release atomically replaces held notifications with the outbox message delivering them.
+	// Without a message the held notifications are just dropped
+	Release(ctx context.Context, ids []int64, msg *OutboxMessage) error
+}
+
+type heldRepo struct {
+	db *sqlx.DB
+}
+
+func NewHeldRepo(db *sqlx.DB) HeldRepo {
+	return &heldRepo{db: db}
+}
+
+func (r *heldRepo) Hold(ctx context.Context, held HeldNotification) error {
+	query := `
+insert into held_notifications
+(user_id, payload, release_at, created_at)
+values
+(:user_id, :payload, :release_at, :created_at)`
+	if _, err := r.db.NamedExecContext(ctx, query, held); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Hold", Query: query, Err: err}
+	}
+	return nil
+}
+
+func (r *heldRepo) FindReleased(ctx context.Context, now time.Time) ([]HeldNotification, error) {
+	query := `select * from held_notifications where release_at <= ? order by user_id, id`
+	var held []HeldNotification
+	if err := r.db.SelectContext(ctx, &held, query, now); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindReleased", Query: query, Err: err}
+	}
+	return held, nil
+}
+
+func (r *heldRepo) Release(ctx context.Context, ids []int64, msg *OutboxMessage) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errs.ErrBeginTransaction
+	}
+	defer tx.Rollback()
+
+	if msg != nil {
+		if _, err = tx.NamedExecContext(ctx, outboxInsert, msg); err != nil {
+			return &errs.ErrQueryExecution{Operation: "Release", Query: outboxInsert, Err: err}
+		}
+	}
+
+	query, args, err := squirrel.Delete("held_notifications").Where(squirrel.Eq{"id": ids}).ToSql()
+	if err != nil {
+		return &errs.ErrQueryCreation{Operation: "Release", Query: query, Err: err}
+	}
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Release", Query: query, Err: err}
+	}
+
+	return tx.Commit()
+}
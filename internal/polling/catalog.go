@@ -0,0 +1,131 @@
+package polling
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+)
+
+// CatalogService is a service listed on the booking page of a company
+type CatalogService struct {
+	CompanyID int
+	ServiceID int
+	Name      string
+}
+
+// CatalogDiff is what changed on the booking page of a company since the previous refresh
+type CatalogDiff struct {
+	CompanyID   int
+	CompanyName string
+	// Added services are listed for the first time ever, Returned ones are listed again after being removed
+	Added    []CatalogService
+	Returned []CatalogService
+	Removed  []CatalogService
+	// Published are the labs of the added services and Withdrawn the labs only the removed services had.
+	// Both come from the polled slots and have no times. Added services are polled after the refresh,
+	// so their labs come in a later diff of their own
+	Published []Slot
+	Withdrawn []Slot
+}
+
+func (d *CatalogDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Returned) == 0 && len(d.Removed) == 0 && len(d.Published) == 0 && len(d.Withdrawn) == 0
+}
+
+// Catalog keeps every service ever listed on the booking pages, so that refreshes can be compared with it,
+// and every lab ever parsed from them
+type Catalog interface {
+	// Sync stores the listed services of the company and returns what changed since the previous sync.
+	// The first sync of a company has nothing to compare with and reports no changes
+	Sync(ctx context.Context, companyID int, services []CatalogService) (added, returned, removed []CatalogService, err error)
+	// AddLabs stores the labs the catalog hasn't seen yet, times are ignored
+	AddLabs(ctx context.Context, labs []Slot) error
+}
+
+// syncCatalog compares the listed services with the catalog, nil when there is no catalog or nothing changed
+func (s *dikidiSource) syncCatalog(ctx context.Context, services []CatalogService) *CatalogDiff {
+	// An empty page is more likely broken than every lab gone
+	if s.catalog == nil || len(services) == 0 {
+		return nil
+	}
+	added, returned, removed, err := s.catalog.Sync(ctx, s.company.ID, services)
+	if err != nil {
+		slog.Error("Failed to sync service catalog", "error", err, "company", s.company.ID, "service", logger.ServicePolling)
+		return nil
+	}
+	diff := &CatalogDiff{
+		CompanyID:   s.company.ID,
+		CompanyName: s.company.Name,
+		Added:       added,
+		Returned:    returned,
+		Removed:     removed,
+	}
+	if diff.Empty() {
+		return nil
+	}
+	return diff
+}
+
+// withdrawnLabs returns the labs of the removed services that no other service has, the caller must hold the lock
+func (s *dikidiSource) withdrawnLabs(removed []CatalogService) []Slot {
+	remaining := make(map[string]bool)
+	for id, state := range s.services {
+		if slices.ContainsFunc(removed, func(service CatalogService) bool { return service.ServiceID == id }) {
+			continue
+		}
+		for _, lab := range state.labs {
+			remaining[lab.Key()] = true
+		}
+	}
+	var withdrawn []Slot
+	for _, service := range removed {
+		state, ok := s.services[service.ServiceID]
+		if !ok {
+			continue
+		}
+		for _, lab := range state.labs {
+			if !remaining[lab.Key()] {
+				remaining[lab.Key()] = true
+				withdrawn = append(withdrawn, lab)
+			}
+		}
+	}
+	return withdrawn
+}
+
+// publishLabs queues the labs of an added service for announcement once its poll found some.
+// Until then the service stays unpublished, the caller must hold the lock
+func (s *dikidiSource) publishLabs(serviceID int, labs []Slot) {
+	if !s.unpublished[serviceID] || len(labs) == 0 {
+		return
+	}
+	delete(s.unpublished, serviceID)
+	s.published = append(s.published, labs...)
+}
+
+func (s *dikidiSource) PublishedLabs() *CatalogDiff {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.published) == 0 {
+		return nil
+	}
+	diff := &CatalogDiff{
+		CompanyID:   s.company.ID,
+		CompanyName: s.company.Name,
+		Published:   s.published,
+	}
+	s.published = nil
+	return diff
+}
+
+// catalogLabs stores the parsed labs, so that the subscription wizard can offer them
+func (s *dikidiSource) catalogLabs(ctx context.Context, labs []Slot) {
+	if s.catalog == nil || len(labs) == 0 {
+		return
+	}
+	if err := s.catalog.AddLabs(ctx, labs); err != nil {
+		slog.Error("Failed to store parsed labs", "error", err, "company", s.company.ID, "service", logger.ServicePolling)
+	}
+}
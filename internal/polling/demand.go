@@ -0,0 +1,174 @@
+package polling
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+)
+
+// LabDemand is a lab somebody is subscribed to. Nil fields match any value, like they do in subscriptions
+type LabDemand struct {
+	CompanyID  *int
+	Type       LabType
+	Number     int
+	Auditorium *int
+	Domain     *LabDomain
+}
+
+// Matches mirrors the way subscriptions are matched against slots
+func (d LabDemand) Matches(slot Slot) bool {
+	if d.CompanyID != nil && *d.CompanyID != slot.CompanyID {
+		return false
+	}
+	if d.Type != slot.Type || d.Number != slot.Number {
+		return false
+	}
+	if slot.Type == LabTypePerformance && d.Auditorium != nil && *d.Auditorium != slot.Auditorium {
+		return false
+	}
+	if slot.Type == LabTypeDefence && d.Domain != nil && *d.Domain != slot.Domain {
+		return false
+	}
+	return true
+}
+
+// Demand is the set of labs with subscribers
+type Demand struct {
+	Labs []LabDemand
+}
+
+func (d *Demand) wants(labs []Slot) bool {
+	for _, lab := range labs {
+		for _, labDemand := range d.Labs {
+			if labDemand.Matches(lab) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unmatched tells whether some demanded lab is yielded by none of the labs
+func (d *Demand) unmatched(labs []Slot) bool {
+	for _, labDemand := range d.Labs {
+		matched := false
+		for _, lab := range labs {
+			if labDemand.Matches(lab) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return true
+		}
+	}
+	return false
+}
+
+// DemandProvider tells the poller which labs are worth polling often
+type DemandProvider interface {
+	FindDemand(ctx context.Context) ([]LabDemand, error)
+}
+
+// serviceState is what a source remembers about a service between polls
+type serviceState struct {
+	// labs are the slots the service yielded last time it had any, without times
+	labs []Slot
+	// slots are the merged slots of the latest poll by Key
+	slots      map[string]Slot
+	lastPolled time.Time
+}
+
+// selectServices splits the services into the ones to poll right away and the ones to poll after them.
+// Services with demand, and the ones never polled yet, are polled on every tick. So are the services that
+// never yielded a lab while some demand is matched by no known lab, any of them may turn out to hold it.
+// The rest are polled once per BackgroundPollRate. Without demand, or with the rate unset, every service is polled
+func (s *dikidiSource) selectServices(demand *Demand, now time.Time) (hot []int, cold []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unmatched := false
+	if demand != nil {
+		known := make([]Slot, 0)
+		for _, id := range s.serviceIDs {
+			if state, ok := s.services[id]; ok {
+				known = append(known, state.labs...)
+			}
+		}
+		unmatched = demand.unmatched(known)
+	}
+
+	for _, id := range s.serviceIDs {
+		state, ok := s.services[id]
+		if !ok {
+			state = &serviceState{}
+			s.services[id] = state
+		}
+		switch {
+		case demand == nil || s.options.BackgroundPollRate <= 0 || state.lastPolled.IsZero() || demand.wants(state.labs):
+			hot = append(hot, id)
+		case len(state.labs) == 0 && unmatched:
+			hot = append(hot, id)
+		case now.Sub(state.lastPolled) >= s.options.BackgroundPollRate:
+			cold = append(cold, id)
+		default:
+			continue
+		}
+		state.lastPolled = now
+	}
+	return hot, cold
+}
+
+// rememberService keeps what the latest poll of the service yielded
+func (s *dikidiSource) rememberService(serviceID int, slots map[string]Slot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.services[serviceID]
+	if !ok {
+		return
+	}
+	state.slots = slots
+	if len(slots) == 0 {
+		return
+	}
+	state.labs = make([]Slot, 0, len(slots))
+	for _, slot := range slots {
+		slot.TimesTeachers = nil
+		state.labs = append(state.labs, slot)
+	}
+	s.publishLabs(serviceID, state.labs)
+}
+
+func (s *dikidiSource) previousSlots(serviceID int) map[string]Slot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if state, ok := s.services[serviceID]; ok {
+		return state.slots
+	}
+	return nil
+}
+
+func (s *dikidiSource) CurrentSlots() []Slot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	slots := make([]Slot, 0)
+	for _, id := range s.serviceIDs {
+		if state, ok := s.services[id]; ok {
+			slots = append(slots, slices.Collect(maps.Values(state.slots))...)
+		}
+	}
+	return slots
+}
+
+// knownSlots sums the latest slot counts of every service, including the ones skipped this time
+func (s *dikidiSource) knownSlots() int {
+	total := 0
+	for _, id := range s.serviceIDs {
+		if state, ok := s.services[id]; ok {
+			total += len(state.slots)
+		}
+	}
+	return total
+}
@@ -0,0 +1,99 @@
+package polling
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabDemandMatches(t *testing.T) {
+	company, auditorium, otherAuditorium := 550001, 233, 512
+	mechanics := LabDomain("mechanics")
+
+	type testCase struct {
+		demand   LabDemand
+		slot     Slot
+		expected bool
+	}
+
+	performance := Slot{CompanyID: company, Type: LabTypePerformance, Number: 7, Auditorium: auditorium}
+	defence := Slot{CompanyID: company, Type: LabTypeDefence, Number: 12, Auditorium: 512, Domain: LabDomain("mechanics")}
+
+	tests := []testCase{
+		{demand: LabDemand{Type: LabTypePerformance, Number: 7, Auditorium: &auditorium}, slot: performance, expected: true},
+		{demand: LabDemand{CompanyID: &company, Type: LabTypePerformance, Number: 7}, slot: performance, expected: true},
+		{demand: LabDemand{Type: LabTypePerformance, Number: 7, Auditorium: &otherAuditorium}, slot: performance, expected: false},
+		{demand: LabDemand{Type: LabTypePerformance, Number: 8}, slot: performance, expected: false},
+		{demand: LabDemand{Type: LabTypeDefence, Number: 7}, slot: performance, expected: false},
+		// Defence subscriptions don't care about the auditorium
+		{demand: LabDemand{Type: LabTypeDefence, Number: 12, Auditorium: &auditorium, Domain: &mechanics}, slot: defence, expected: true},
+		{demand: LabDemand{Type: LabTypeDefence, Number: 12, Domain: new(LabDomain)}, slot: defence, expected: false},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_lab_demand_matches_%d", i), func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.demand.Matches(tCase.slot))
+		})
+	}
+}
+
+func TestSelectServices(t *testing.T) {
+	now := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+	demand := &Demand{Labs: []LabDemand{{Type: LabTypePerformance, Number: 7}}}
+	unmatched := &Demand{Labs: []LabDemand{{Type: LabTypePerformance, Number: 9}}}
+
+	newSource := func(backgroundRate time.Duration) *dikidiSource {
+		s := &dikidiSource{
+			options:    config.PollingConfig{BackgroundPollRate: backgroundRate},
+			serviceIDs: []int{101, 102, 103, 104, 105},
+			services: map[int]*serviceState{
+				// Wanted lab
+				101: {labs: []Slot{{Type: LabTypePerformance, Number: 7}}, lastPolled: now.Add(-time.Minute)},
+				// Nobody wants it and it was polled recently
+				102: {labs: []Slot{{Type: LabTypePerformance, Number: 8}}, lastPolled: now.Add(-time.Minute)},
+				// Nobody wants it and it's due
+				103: {labs: []Slot{{Type: LabTypeDefence, Number: 12}}, lastPolled: now.Add(-time.Hour)},
+				// Polled recently and yielded nothing so far
+				105: {lastPolled: now.Add(-time.Minute)},
+			},
+		}
+		// 104 was never polled
+		return s
+	}
+
+	type testCase struct {
+		source       *dikidiSource
+		demand       *Demand
+		expectedHot  []int
+		expectedCold []int
+	}
+
+	tests := []testCase{
+		{source: newSource(10 * time.Minute), demand: demand, expectedHot: []int{101, 104}, expectedCold: []int{103}},
+		{source: newSource(10 * time.Minute), demand: &Demand{}, expectedHot: []int{104}, expectedCold: []int{103}},
+		// No known lab yields the demand, so it may be on the service that yielded nothing yet
+		{source: newSource(10 * time.Minute), demand: unmatched, expectedHot: []int{104, 105}, expectedCold: []int{103}},
+		// Unknown demand or no background rate polls everything
+		{source: newSource(10 * time.Minute), demand: nil, expectedHot: []int{101, 102, 103, 104, 105}},
+		{source: newSource(0), demand: demand, expectedHot: []int{101, 102, 103, 104, 105}},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_select_services_%d", i), func(t *testing.T) {
+			hot, cold := tCase.source.selectServices(tCase.demand, now)
+			assert.Equal(t, tCase.expectedHot, hot)
+			assert.Equal(t, tCase.expectedCold, cold)
+
+			if tCase.source.options.BackgroundPollRate == 0 {
+				return
+			}
+			// Selected services aren't due again until the background rate passes
+			hot, cold = tCase.source.selectServices(&Demand{}, now.Add(time.Minute))
+			assert.Empty(t, hot)
+			assert.Empty(t, cold)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/teacher"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+	"golang.org/x/time/rate"
+)
+
+// dikidiSource scrapes lab slots of a single company from the dikidi.net booking system
+type dikidiSource struct {
+	teacherService   teacher.Service
+	quarantine       Quarantine
+	catalog          Catalog
+	rules            Rules
+	company          config.CompanyConfig
+	location         *time.Location
+	options          config.PollingConfig
+	serviceIDs       []int
+	services         map[int]*serviceState
+	unpublished      map[int]bool
+	published        []Slot
+	hashes           map[hashKey]responseHash
+	httpClient       http.Client
+	fetchRateLimiter *rate.Limiter
+	lastPollStats    PollStats
+	mu               sync.RWMutex
+}
+
+// NewDikidiSource reads dikidi times as wall clock times in the campus location.
+// The quarantine and the catalog are optional, without them parsing failures are only logged
+// and service lists aren't compared between refreshes
+func NewDikidiSource(teacherService teacher.Service, quarantine Quarantine, catalog Catalog, rules Rules, company config.CompanyConfig, loc *time.Location, opts *config.PollingConfig) SlotSource {
+	httpClient := http.Client{
+		Timeout: time.Second * 30,
+	}
+	if opts.Dikidi.RecordDir != "" {
+		httpClient.Transport = newRecordingTransport(opts.Dikidi.RecordDir)
+	}
+
+	return &dikidiSource{
+		teacherService:   teacherService,
+		quarantine:       quarantine,
+		catalog:          catalog,
+		rules:            rules,
+		company:          company,
+		location:         loc,
+		options:          *opts,
+		serviceIDs:       make([]int, 0),
+		services:         make(map[int]*serviceState),
+		unpublished:      make(map[int]bool),
+		hashes:           make(map[hashKey]responseHash),
+		httpClient:       httpClient,
+		fetchRateLimiter: rate.NewLimiter(rate.Every(opts.GetFetchRate()), 1),
+		mu:               sync.RWMutex{},
+	}
+}
+
+func (s *dikidiSource) UpdateServices(ctx context.Context) (*CatalogDiff, error) {
+	services, err := s.fetchServiceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(services))
+	for _, service := range services {
+		ids = append(ids, service.ServiceID)
+	}
+	diff := s.syncCatalog(ctx, services)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if diff != nil {
+		diff.Withdrawn = s.withdrawnLabs(diff.Removed)
+		for _, service := range diff.Added {
+			s.unpublished[service.ServiceID] = true
+		}
+	}
+	s.serviceIDs = ids
+	for id := range s.services {
+		if !slices.Contains(ids, id) {
+			delete(s.services, id)
+		}
+	}
+	for id := range s.unpublished {
+		if !slices.Contains(ids, id) {
+			delete(s.unpublished, id)
+		}
+	}
+	for key := range s.hashes {
+		if !slices.Contains(ids, key.serviceID) {
+			delete(s.hashes, key)
+		}
+	}
+	return diff, nil
+}
+
+func (s *dikidiSource) SetMode(mode config.PollingMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options.Mode = mode
+	s.fetchRateLimiter.SetLimit(rate.Every(s.options.GetFetchRate()))
+}
+
+func (s *dikidiSource) FetchRate() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.options.GetFetchRate()
+}
+
+func (s *dikidiSource) LastPollStats() PollStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastPollStats
+}
+
+// quarantineFailures logs the entries the parser missed and keeps them for the admin to review
+func (s *dikidiSource) quarantineFailures(ctx context.Context, failures []ParseFailure) {
+	for _, failure := range failures {
+		slog.Warn("Parsing error",
+			"error", failure.Err,
+			"company", s.company.ID,
+			"service_id", failure.ServiceID,
+			"service", logger.ServicePolling)
+	}
+	if s.quarantine == nil {
+		return
+	}
+	if err := s.quarantine.Add(ctx, s.company.ID, failures); err != nil {
+		slog.Error("Failed to quarantine parsing failures", "error", err, "company", s.company.ID, "service", logger.ServicePolling)
+	}
+}
+
+func (s *dikidiSource) PollSlots(ctx context.Context, demand *Demand) (chan []Slot, chan error) {
+	results := make(chan []Slot)
+	errChan := make(chan error)
+
+	hot, cold := s.selectServices(demand, time.Now())
+	s.mu.RLock()
+	stats := PollStats{CompanyID: s.company.ID, Source: s.company.Name, ServiceIDs: len(s.serviceIDs)}
+	s.mu.RUnlock()
+
+	dataChan, fetchErrChan := s.pollServerData(ctx, hot, cold)
+	mergers := make(map[int]*slotMerger)
+	go func() {
+		defer close(errChan)
+		defer close(results)
+		// Runs before the channels close, so the stats are ready once the caller is done reading
+		defer func() {
+			s.mu.Lock()
+			stats.Slots = s.knownSlots()
+			s.lastPollStats = stats
+			s.mu.Unlock()
+		}()
+
+		for dataChan != nil || fetchErrChan != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-dataChan:
+				if !ok {
+					dataChan = nil
+					continue
+				}
+				merger, ok := mergers[item.serviceID]
+				if !ok {
+					merger = newSlotMerger(s.previousSlots(item.serviceID), s.location)
+					mergers[item.serviceID] = merger
+				}
+
+				if item.dates != nil {
+					merger.list(item.dates)
+				}
+				var slots []Slot
+				if item.unchanged {
+					// The previous times of the date are still right, the merger keeps them as they are
+					stats.Responses++
+					continue
+				}
+				if item.done {
+					var current map[string]Slot
+					slots, current = merger.finish()
+					delete(mergers, item.serviceID)
+					s.rememberService(item.serviceID, current)
+				} else {
+					parseStart := time.Now()
+					parsed, failures := s.ParseServerData(ctx, item.data, item.serviceID)
+					recordParsing(time.Since(parseStart), len(failures) > 0)
+					s.catalogLabs(ctx, parsed)
+
+					stats.Responses++
+					if len(failures) > 0 {
+						stats.ParseErrors++
+						s.quarantineFailures(ctx, failures)
+						// Responses with failures are parsed every time, so parser drift doesn't look like a recovery
+						s.forgetHash(item.hash)
+					}
+					slots = merger.add(item.date, parsed)
+				}
+				if len(slots) == 0 {
+					continue
+				}
+
+				select {
+				case results <- slots:
+				case <-ctx.Done():
+					return
+				}
+			case err, ok := <-fetchErrChan:
+				if !ok {
+					fetchErrChan = nil
+					continue
+				}
+				// A response that isn't JSON anymore means the source changed, not that it's down
+				var parseErr *ErrParseData
+				if errors.As(err, &parseErr) {
+					stats.Responses++
+					stats.ParseErrors++
+				} else {
+					stats.FetchErrors++
+				}
+				select {
+				case errChan <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return results, errChan
+}
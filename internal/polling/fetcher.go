@@ -10,7 +10,7 @@ import (
 	"golang.org/x/time/rate"
 )
 
-func (s *pollingService) fetchData(ctx context.Context, url string) (*http.Response, error) {
+func (s *dikidiSource) fetchData(ctx context.Context, url string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, &ErrFetch{url: url, msg: "failed to create request", err: err}
@@ -36,7 +36,7 @@ func (s *pollingService) fetchData(ctx context.Context, url string) (*http.Respo
 	return res, nil
 }
 
-func (s *pollingService) processBadHTTPResponse(res *http.Response) error {
+func (s *dikidiSource) processBadHTTPResponse(res *http.Response) error {
 	switch res.StatusCode {
 	case http.StatusTooManyRequests:
 		s.decreaseFetchRate()
@@ -47,22 +47,22 @@ func (s *pollingService) processBadHTTPResponse(res *http.Response) error {
 	return fmt.Errorf("unexpected status code: %d", res.StatusCode)
 }
 
-func (s *pollingService) increaseFetchRate() {
+func (s *dikidiSource) increaseFetchRate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	newRateFloat := float64(s.options.GetFetchRate().Milliseconds()) * s.options.RecoveryFactor
 	newRateFloat = math.Min(float64(s.options.MaxFetchRate.Milliseconds()), newRateFloat)
 	newRate := time.Millisecond * time.Duration(math.Round(newRateFloat))
-	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.options.SetFetchRate(newRate)
 	s.fetchRateLimiter.SetLimit(rate.Every(newRate))
 }
 
-func (s *pollingService) decreaseFetchRate() {
+func (s *dikidiSource) decreaseFetchRate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	newRateFloat := float64(s.options.GetFetchRate().Milliseconds()) / s.options.BackoffFactor
 	newRateFloat = math.Max(float64(s.options.MinFetchRate.Milliseconds()), newRateFloat)
 	newRate := time.Millisecond * time.Duration(math.Round(newRateFloat))
-	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.options.SetFetchRate(newRate)
 	s.fetchRateLimiter.SetLimit(rate.Every(newRate))
 }
@@ -0,0 +1,50 @@
+package polling
+
+import (
+	"crypto/sha256"
+	"time"
+)
+
+type hashKey struct {
+	serviceID int
+	// date is empty for the initial request of a service
+	date string
+}
+
+// responseHash is the content hash of the latest processed response of a service date
+type responseHash struct {
+	sum [sha256.Size]byte
+	// dates of the response, the initial one is needed to go on with the rest of the dates
+	dates       []string
+	processedAt time.Time
+}
+
+// checkHash reports whether the response is the same as the processed one and may be skipped.
+// Responses are processed again once ContentHashTTL passes, so open slots are re-sent before the notification cache forgets them
+func (s *dikidiSource) checkHash(key hashKey, sum [sha256.Size]byte, now time.Time) (responseHash, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.options.ContentHashTTL <= 0 {
+		return responseHash{}, false
+	}
+	hash, ok := s.hashes[key]
+	if !ok || hash.sum != sum || now.Sub(hash.processedAt) >= s.options.ContentHashTTL {
+		recordContentHash(false)
+		return responseHash{}, false
+	}
+	recordContentHash(true)
+	return hash, true
+}
+
+func (s *dikidiSource) forgetHash(key hashKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.hashes, key)
+}
+
+func (s *dikidiSource) storeHash(key hashKey, hash responseHash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes[key] = hash
+}
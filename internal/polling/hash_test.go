@@ -0,0 +1,45 @@
+package polling
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHash(t *testing.T) {
+	processedAt := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+	key := hashKey{serviceID: 101, date: "2025-11-21"}
+	sum := sha256.Sum256([]byte(`{"data":{}}`))
+
+	type testCase struct {
+		ttl      time.Duration
+		key      hashKey
+		sum      [sha256.Size]byte
+		now      time.Time
+		expected bool
+	}
+
+	tests := []testCase{
+		{ttl: 4 * time.Minute, key: key, sum: sum, now: processedAt.Add(time.Minute), expected: true},
+		{ttl: 4 * time.Minute, key: key, sum: sha256.Sum256([]byte(`{}`)), now: processedAt.Add(time.Minute), expected: false},
+		{ttl: 4 * time.Minute, key: hashKey{serviceID: 101}, sum: sum, now: processedAt.Add(time.Minute), expected: false},
+		// Unchanged responses are still processed once in a while, so the notification cache doesn't forget open slots
+		{ttl: 4 * time.Minute, key: key, sum: sum, now: processedAt.Add(4 * time.Minute), expected: false},
+		{ttl: 0, key: key, sum: sum, now: processedAt.Add(time.Minute), expected: false},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_check_hash_%d", i), func(t *testing.T) {
+			s := &dikidiSource{
+				options: config.PollingConfig{ContentHashTTL: tCase.ttl},
+				hashes:  map[hashKey]responseHash{key: {sum: sum, processedAt: processedAt}},
+			}
+			_, ok := s.checkHash(tCase.key, tCase.sum, tCase.now)
+			assert.Equal(t, tCase.expected, ok)
+		})
+	}
+}
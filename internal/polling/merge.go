@@ -0,0 +1,146 @@
+package polling
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// slotMerger puts the slots of a service together by Key as its dates arrive.
+// Times of dates not fetched yet are taken from the previous poll, so a half-fetched service
+// doesn't look like its later times were taken
+type slotMerger struct {
+	location *time.Location
+	previous map[string]Slot
+	fresh    map[string]Slot
+	emitted  map[string]Slot
+	fetched  map[string]bool
+	// listed are the dates the service offers, previous times of other dates are gone
+	listed map[string]bool
+	// all is set once a response covered every date of the service
+	all bool
+}
+
+func newSlotMerger(previous map[string]Slot, loc *time.Location) *slotMerger {
+	return &slotMerger{
+		location: loc,
+		previous: previous,
+		fresh:    make(map[string]Slot),
+		emitted:  make(map[string]Slot),
+		fetched:  make(map[string]bool),
+	}
+}
+
+// list remembers the dates the service offers now
+func (m *slotMerger) list(dates []string) {
+	m.listed = make(map[string]bool, len(dates))
+	for _, date := range dates {
+		m.listed[date] = true
+	}
+}
+
+// add merges the slots of a date and returns the merged slots they touched. An empty date covers every date
+func (m *slotMerger) add(date string, slots []Slot) []Slot {
+	if date == "" {
+		m.all = true
+	} else {
+		m.fetched[date] = true
+	}
+
+	keys := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		key := slot.Key()
+		merged, ok := m.fresh[key]
+		if !ok {
+			merged = slot
+			merged.TimesTeachers = make(map[time.Time][]string, len(slot.TimesTeachers))
+			keys = append(keys, key)
+		} else if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+		maps.Copy(merged.TimesTeachers, slot.TimesTeachers)
+		m.fresh[key] = merged
+	}
+
+	result := make([]Slot, 0, len(keys))
+	for _, key := range keys {
+		view := m.view(key)
+		m.emitted[key] = view
+		result = append(result, view)
+	}
+	return result
+}
+
+// finish returns the merged slots that changed since they were last emitted, along with every slot the service has now
+func (m *slotMerger) finish() (changed []Slot, current map[string]Slot) {
+	current = make(map[string]Slot, len(m.fresh))
+	for _, key := range slices.Sorted(maps.Keys(m.keys())) {
+		view := m.view(key)
+		if len(view.TimesTeachers) == 0 {
+			continue
+		}
+		current[key] = view
+		if _, ok := m.fresh[key]; !ok {
+			// Only times of dates that failed to fetch are left, nothing new to tell
+			continue
+		}
+		if last, ok := m.emitted[key]; !ok || !sameTimes(last.TimesTeachers, view.TimesTeachers) {
+			changed = append(changed, view)
+		}
+	}
+	return changed, current
+}
+
+func (m *slotMerger) keys() map[string]struct{} {
+	keys := make(map[string]struct{}, len(m.fresh)+len(m.previous))
+	for key := range m.fresh {
+		keys[key] = struct{}{}
+	}
+	for key := range m.previous {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// view is the fresh slot with the previous times of dates that weren't fetched yet
+func (m *slotMerger) view(key string) Slot {
+	fresh, hasFresh := m.fresh[key]
+	previous, hasPrevious := m.previous[key]
+
+	view := fresh
+	if !hasFresh {
+		view = previous
+	}
+	view.TimesTeachers = make(map[time.Time][]string)
+	if hasFresh {
+		maps.Copy(view.TimesTeachers, fresh.TimesTeachers)
+	}
+	if hasPrevious {
+		for t, teachers := range previous.TimesTeachers {
+			if !m.covered(t) {
+				view.TimesTeachers[t] = teachers
+			}
+		}
+	}
+	return view
+}
+
+func (m *slotMerger) covered(t time.Time) bool {
+	date := t.In(m.location).Format(dateLayout)
+	return m.all || m.fetched[date] || (m.listed != nil && !m.listed[date])
+}
+
+func sameTimes(a, b map[time.Time][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for t, teachers := range a {
+		other, ok := b[t]
+		if !ok || !slices.Equal(teachers, other) {
+			return false
+		}
+	}
+	return true
+}
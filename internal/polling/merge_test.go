@@ -0,0 +1,146 @@
+package polling
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlotMerger(t *testing.T) {
+	campus := testutil.Campus(t)
+	at := func(date, clock string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, campus)
+		require.NoError(t, err)
+		return parsed
+	}
+	slotWith := func(times ...time.Time) Slot {
+		timesTeachers := make(map[time.Time][]string, len(times))
+		for _, t := range times {
+			timesTeachers[t] = nil
+		}
+		return Slot{CompanyID: 550001, Number: 7, Auditorium: 233, TimesTeachers: timesTeachers}
+	}
+	keySlot := slotWith()
+	key := keySlot.Key()
+
+	type batch struct {
+		date  string
+		slots []Slot
+	}
+
+	type testCase struct {
+		previous []time.Time
+		// listed are the dates the service offers, nil when its list wasn't fetched
+		listed  []string
+		batches []batch
+		// emitted holds the times of every emitted slot, the ones from finish go last
+		expectedEmitted [][]time.Time
+		expectedCurrent []time.Time
+	}
+
+	tests := []testCase{
+		// Dates add up
+		{
+			batches: []batch{
+				{date: "2025-11-20", slots: []Slot{slotWith(at("2025-11-20", "08:50"))}},
+				{date: "2025-11-21", slots: []Slot{slotWith(at("2025-11-21", "10:35"))}},
+			},
+			expectedEmitted: [][]time.Time{
+				{at("2025-11-20", "08:50")},
+				{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+			},
+			expectedCurrent: []time.Time{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+		},
+		// Later dates keep their previous times until they're fetched
+		{
+			previous: []time.Time{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+			batches: []batch{
+				{date: "2025-11-20", slots: []Slot{slotWith(at("2025-11-20", "12:35"))}},
+				{date: "2025-11-21", slots: []Slot{slotWith(at("2025-11-21", "10:35"))}},
+			},
+			expectedEmitted: [][]time.Time{
+				{at("2025-11-20", "12:35"), at("2025-11-21", "10:35")},
+				{at("2025-11-20", "12:35"), at("2025-11-21", "10:35")},
+			},
+			expectedCurrent: []time.Time{at("2025-11-20", "12:35"), at("2025-11-21", "10:35")},
+		},
+		// A date without the slot anymore is corrected once the service is done
+		{
+			previous: []time.Time{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+			batches: []batch{
+				{date: "2025-11-20", slots: []Slot{slotWith(at("2025-11-20", "08:50"))}},
+				{date: "2025-11-21"},
+			},
+			expectedEmitted: [][]time.Time{
+				{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+				{at("2025-11-20", "08:50")},
+			},
+			expectedCurrent: []time.Time{at("2025-11-20", "08:50")},
+		},
+		// A date that failed to fetch keeps its previous times
+		{
+			previous: []time.Time{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+			batches: []batch{
+				{date: "2025-11-20", slots: []Slot{slotWith(at("2025-11-20", "08:50"))}},
+			},
+			expectedEmitted: [][]time.Time{
+				{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+			},
+			expectedCurrent: []time.Time{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+		},
+		// A date the service stopped offering loses its previous times, even though it's never fetched
+		{
+			previous: []time.Time{at("2025-11-20", "08:50"), at("2025-11-21", "10:35")},
+			listed:   []string{"2025-11-20"},
+			batches: []batch{
+				{date: "2025-11-20", slots: []Slot{slotWith(at("2025-11-20", "08:50"))}},
+			},
+			expectedEmitted: [][]time.Time{
+				{at("2025-11-20", "08:50")},
+			},
+			expectedCurrent: []time.Time{at("2025-11-20", "08:50")},
+		},
+		// A response without dates covers them all
+		{
+			previous: []time.Time{at("2025-11-21", "10:35")},
+			batches:  []batch{{date: ""}},
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_slot_merger_%d", i), func(t *testing.T) {
+			var previous map[string]Slot
+			if tCase.previous != nil {
+				previous = map[string]Slot{key: slotWith(tCase.previous...)}
+			}
+			merger := newSlotMerger(previous, campus)
+			if tCase.listed != nil {
+				merger.list(tCase.listed)
+			}
+
+			var emitted []Slot
+			for _, b := range tCase.batches {
+				emitted = append(emitted, merger.add(b.date, b.slots)...)
+			}
+			changed, current := merger.finish()
+			emitted = append(emitted, changed...)
+
+			require.Len(t, emitted, len(tCase.expectedEmitted))
+			for idx, slot := range emitted {
+				assert.ElementsMatch(t, tCase.expectedEmitted[idx], slices.Collect(maps.Keys(slot.TimesTeachers)))
+			}
+			if tCase.expectedCurrent == nil {
+				assert.Empty(t, current)
+				return
+			}
+			require.Contains(t, current, key)
+			assert.ElementsMatch(t, tCase.expectedCurrent, slices.Collect(maps.Keys(current[key].TimesTeachers)))
+		})
+	}
+}
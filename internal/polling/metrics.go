@@ -36,6 +36,11 @@ var (
 		},
 	})
 
+	contentHashMetrics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "polling_content_hash_count",
+		Help: "Number of responses checked against the previous content hash by result, a hit skips parsing",
+	}, []string{"result"})
+
 	pollingDurationMetrics = promauto.NewSummary(prometheus.SummaryOpts{
 		Name: "polling_duration",
 		Help: "Slot polling duration in seconds",
@@ -59,6 +64,14 @@ func recordParsing(d time.Duration, hasError bool) {
 	parsingDurationMetrics.Observe(d.Seconds())
 }
 
+func recordContentHash(hit bool) {
+	if hit {
+		contentHashMetrics.WithLabelValues("hit").Inc()
+		return
+	}
+	contentHashMetrics.WithLabelValues("miss").Inc()
+}
+
 func recordPolling(d time.Duration) {
 	pollingDurationMetrics.Observe(d.Seconds())
 }
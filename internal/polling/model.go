@@ -12,25 +12,76 @@ import (
 // Модель записи на лабораторную работу
 // ======================================================
 
-type LabDomain int
+// LabDomain is the key of a lab domain from the parsing config, e.g. "mechanics"
+type LabDomain string
+
+// LabDomainUnknown is given to slots no domain rule matched, it needs no config
+const LabDomainUnknown LabDomain = "unknown"
+
+// legacyLabDomains are the keys of the domains that used to be stored as numbers, by number
+var legacyLabDomains = []LabDomain{"electricity", "mechanics", "virtual", LabDomainUnknown}
+
+// UnmarshalJSON also reads the numbers domains were encoded as before they moved to the config,
+// so that notifications queued by older versions are still delivered
+func (ld *LabDomain) UnmarshalJSON(data []byte) error {
+	var legacy int
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*ld = LabDomainUnknown
+		if legacy >= 0 && legacy < len(legacyLabDomains) {
+			*ld = legacyLabDomains[legacy]
+		}
+		return nil
+	}
+	var key string
+	if err := json.Unmarshal(data, &key); err != nil {
+		return err
+	}
+	*ld = LabDomain(key)
+	return nil
+}
 
-const (
-	LabDomainElectricity LabDomain = iota
-	LabDomainMechanics
-	LabDomainVirtual
-)
+// legacyLabDomainLabels are the labels slot keys were hashed with before domains moved to the config
+var legacyLabDomainLabels = map[LabDomain]string{
+	"electricity":    "Электричество",
+	"mechanics":      "Механика",
+	"virtual":        "Виртуалка",
+	LabDomainUnknown: "Неизвестно",
+}
 
-func (ld LabDomain) String() string {
-	switch ld {
-	case LabDomainElectricity:
-		return "Электричество"
-	case LabDomainMechanics:
-		return "Механика"
-	case LabDomainVirtual:
-		return "Виртуалка"
-	default:
-		return "Неизвестно"
+// keyString is the domain as slot keys hash it, legacy domains keep their old labels
+// so that slot history, cache and ledger entries stay attached to the same slots
+func (ld LabDomain) keyString() string {
+	if label, ok := legacyLabDomainLabels[ld]; ok {
+		return label
+	}
+	return string(ld)
+}
+
+// Domain is a lab domain of the parsing config
+type Domain struct {
+	Key   LabDomain
+	Label string
+}
+
+// Domains are the configured lab domains in config order
+type Domains []Domain
+
+// Find returns the configured domain of the key
+func (d Domains) Find(key LabDomain) (Domain, bool) {
+	for _, domain := range d {
+		if domain.Key == key {
+			return domain, true
+		}
+	}
+	return Domain{}, false
+}
+
+// Label returns the display label of the domain, the unknown domain and keys missing from the config get a generic one
+func (d Domains) Label(key LabDomain) string {
+	if domain, ok := d.Find(key); ok {
+		return domain.Label
 	}
+	return "Неизвестно"
 }
 
 type LabType int
@@ -52,6 +103,8 @@ func (lt LabType) String() string {
 }
 
 type Slot struct {
+	CompanyID     int
+	CompanyName   string
 	Type          LabType
 	Name          string
 	Number        int
@@ -67,10 +120,11 @@ func (s *Slot) Key() string {
 	if s.Order != nil {
 		orderString = strconv.Itoa(*s.Order)
 	}
-	keyString := fmt.Sprintf("%v|%d|%v|%s",
+	keyString := fmt.Sprintf("%d|%v|%d|%s|%s",
+		s.CompanyID,
 		s.Type,
 		s.Number,
-		s.Domain,
+		s.Domain.keyString(),
 		orderString,
 	)
 
@@ -83,6 +137,38 @@ func (s *Slot) Key() string {
 // Структура HTML документа для извлечения айди сервисов
 // ======================================================
 
+// Status tells how the poller is doing, so a stalled poller can be told apart from an idle one
+type Status struct {
+	StartedAt time.Time
+	// LastPoll is when a poll last got through to the sources, zero until the first one does
+	LastPoll          time.Time
+	LastServiceUpdate time.Time
+	// FetchRate is the slowest fetch rate among the sources
+	FetchRate time.Duration
+}
+
+// PollStats sums up a single poll of a source
+type PollStats struct {
+	// CompanyID identifies the source, Source is its display name and may be empty
+	CompanyID  int
+	Source     string
+	ServiceIDs int
+	// Responses counts responses that came back from the source, one per date of a service, whether they parsed or not
+	Responses   int
+	FetchErrors int
+	ParseErrors int
+	// Slots counts the latest known slots of every service, including the ones this poll skipped
+	Slots int
+}
+
+// ParseFailure is a master entry the parser couldn't make sense of
+type ParseFailure struct {
+	ServiceID   int
+	Username    string
+	ServiceName string
+	Err         error
+}
+
 type PageOptions struct {
 	StepData StepData `json:"step_data"`
 }
@@ -96,7 +182,8 @@ type Category struct {
 }
 
 type LabService struct {
-	ID int `json:"id"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
 }
 
 // ======================================================
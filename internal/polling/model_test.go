@@ -0,0 +1,69 @@
+package polling
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabDomainUnmarshalJSON(t *testing.T) {
+	type testCase struct {
+		data     string
+		expected LabDomain
+	}
+
+	tests := []testCase{
+		{data: `"optics"`, expected: LabDomain("optics")},
+		// Notifications queued before domains moved to the config hold numbers
+		{data: `0`, expected: LabDomain("electricity")},
+		{data: `1`, expected: LabDomain("mechanics")},
+		{data: `2`, expected: LabDomain("virtual")},
+		{data: `3`, expected: LabDomainUnknown},
+		{data: `7`, expected: LabDomainUnknown},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_lab_domain_unmarshal_json_%d", i), func(t *testing.T) {
+			var slot Slot
+			require.NoError(t, json.Unmarshal([]byte(fmt.Sprintf(`{"Domain": %s}`, tCase.data)), &slot))
+			assert.Equal(t, tCase.expected, slot.Domain)
+		})
+	}
+}
+
+func TestSlotKey(t *testing.T) {
+	type testCase struct {
+		slot     Slot
+		expected string
+	}
+
+	order := 2
+	tests := []testCase{
+		// Keys of legacy domains must not change, slot history, cache and ledger entries are stored under them
+		{
+			slot:     Slot{CompanyID: 550001, Type: LabTypeDefence, Number: 7, Domain: LabDomain("mechanics")},
+			expected: "8c71accc5ac0e110415539f258413ae82c23b1021fbfdd72de4c2fc74da44aee",
+		},
+		{
+			slot:     Slot{CompanyID: 550001, Type: LabTypeDefence, Number: 12, Domain: LabDomainUnknown, Order: &order},
+			expected: "33f240f66948eac40026c7e614d60d769b65b13c07a33b643cd4d31fdfe49f6a",
+		},
+		{
+			slot:     Slot{CompanyID: 550001, Type: LabTypePerformance, Number: 3, Domain: LabDomain("electricity")},
+			expected: "a8aa94e90400b4e99296d82c2c2610f529fa5940bcc6a3d00b957993cb600f3c",
+		},
+		{
+			slot:     Slot{CompanyID: 550001, Type: LabTypeDefence, Number: 4, Domain: LabDomain("optics")},
+			expected: "2b514e09b6da104f992c604598c71f7286b403487ca51e9938a26dbafe0fcd7e",
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_slot_key_%d", i), func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.slot.Key())
+		})
+	}
+}
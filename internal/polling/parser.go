@@ -2,45 +2,44 @@ package polling
 
 import (
 	"context"
-	"errors"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var (
-	numRe      = regexp.MustCompile(`№\s*(\d+)`)
-	audRe      = regexp.MustCompile(`\((\d+)\s*\p{L}+\.\)`)
-	orderRe    = regexp.MustCompile(`\((\d+)-?\p{L}*\s*место\)`)
-	domainRe   = regexp.MustCompile(`(Электричество|Механика|Виртуальная\s*лаб\.?)`)
-	typePrefix = "Аудиторное"
-)
-
-func (s *pollingService) ParseServerData(ctx context.Context, data *ServerData, serviceID int) ([]Slot, error) {
+// ParseServerData returns the slots of every master it could parse along with the entries it couldn't.
+// A bad entry costs only its own slot, or its own time, rather than the whole service
+func (s *dikidiSource) ParseServerData(ctx context.Context, data *ServerData, serviceID int) ([]Slot, []ParseFailure) {
 	dataMasters := data.Data.Masters
 	if len(dataMasters) == 0 {
 		return nil, nil
 	}
 
 	slots := make([]Slot, 0, len(dataMasters))
-	errs := make([]error, 0)
+	failures := make([]ParseFailure, 0)
 
 	for id, master := range dataMasters {
-		slot, err := parseSlotInfo(master.Username, master.ServiceName)
+		slot, err := s.rules.Parse(master.Username, master.ServiceName)
 		if err != nil {
-			errs = append(errs, err)
+			failures = append(failures, ParseFailure{
+				ServiceID:   serviceID,
+				Username:    master.Username,
+				ServiceName: master.ServiceName,
+				Err:         err,
+			})
 			continue
 		}
 
 		dataTimes := data.Data.Times
 		timesTeachers := make(map[time.Time][]string, len(dataTimes))
 		for _, timeString := range dataTimes[id] {
-			timestamp, err := parseTimeString(timeString)
+			timestamp, err := parseTimeString(timeString, s.location)
 			if err != nil {
-				errs = append(errs, &ErrParseData{
-					data: timeString,
-					err:  err,
+				failures = append(failures, ParseFailure{
+					ServiceID:   serviceID,
+					Username:    master.Username,
+					ServiceName: master.ServiceName,
+					Err:         &ErrParseData{data: timeString, msg: "invalid slot time", err: err},
 				})
 				continue
 			}
@@ -52,112 +51,25 @@ func (s *pollingService) ParseServerData(ctx context.Context, data *ServerData,
 			timesTeachers[timestamp.Round(0)] = teacherNames
 		}
 
+		slot.CompanyID = s.company.ID
+		slot.CompanyName = s.company.Name
 		slot.TimesTeachers = timesTeachers
-		slot.URL = buildURL(serviceID)
+		slot.URL = s.buildURL(serviceID)
 
 		slots = append(slots, *slot)
 	}
 
-	if len(errs) > 0 {
-		return nil, errors.Join(errs...)
-	}
-
-	return slots, nil
-}
-
-func parseSlotInfo(username, serviceName string) (*Slot, error) {
-	number, err := parseNumber(username, serviceName)
-	if err != nil {
-		return nil, err
-	}
-	auditorium, err := parseAuditorium(username, serviceName)
-	if err != nil {
-		return nil, err
-	}
-	order := parseOrder(username, serviceName)
-	domain := parseDomain(serviceName)
-	labType := parseType(username)
-
-	name := parseName(username)
-
-	return &Slot{
-			Name:       name,
-			Number:     number,
-			Auditorium: auditorium,
-			Order:      order,
-			Domain:     domain,
-			Type:       labType,
-		},
-		nil
-}
-
-func parseName(username string) string {
-	name := numRe.ReplaceAllString(username, "")
-	name = audRe.ReplaceAllString(name, "")
-	name = orderRe.ReplaceAllString(name, "")
-	name = strings.TrimPrefix(name, "Лабораторная работа")
-	name = strings.TrimSpace(name)
-	return strings.Join(strings.Fields(name), " ")
-}
-
-func parseNumber(username string, serviceName string) (int, error) {
-	if match := numRe.FindStringSubmatch(username); match != nil {
-		labNum, _ := strconv.Atoi(match[1])
-		return labNum, nil
-	} else if match := numRe.FindStringSubmatch(serviceName); match != nil {
-		labNum, _ := strconv.Atoi(match[1])
-		return labNum, nil
-	}
-	return 0, &ErrParseData{data: username + " " + serviceName, msg: "lab number not found", err: errors.New("invalid lab name format")}
-}
-
-func parseAuditorium(username string, serviceName string) (int, error) {
-	if match := audRe.FindStringSubmatch(username); match != nil {
-		labAud, _ := strconv.Atoi(match[1])
-		return labAud, nil
-	} else if match := audRe.FindStringSubmatch(serviceName); match != nil {
-		labAud, _ := strconv.Atoi(match[1])
-		return labAud, nil
-	}
-	return 0, &ErrParseData{data: username + " " + serviceName, msg: "lab auditorium not found", err: errors.New("invalid lab name format")}
-}
-
-func parseOrder(username string, serviceName string) *int {
-	if match := orderRe.FindStringSubmatch(username); match != nil {
-		labOrder, _ := strconv.Atoi(match[1])
-		return &labOrder
-	} else if match := orderRe.FindStringSubmatch(serviceName); match != nil {
-		labOrder, _ := strconv.Atoi(match[1])
-		return &labOrder
-	}
-	return nil
-}
-
-func parseDomain(serviceName string) LabDomain {
-	if match := domainRe.FindStringSubmatch(serviceName); match != nil {
-		switch match[1] {
-		case "Электричество":
-			return LabDomainElectricity
-		case "Механика":
-			return LabDomainMechanics
-		case "Виртуальная лаб.":
-			return LabDomainVirtual
-		}
-	}
-	return LabDomainVirtual
-}
-
-func parseType(username string) LabType {
-	if strings.Contains(username, typePrefix) {
-		return LabTypeDefence
-	}
-	return LabTypePerformance
+	return slots, failures
 }
 
-func parseTimeString(timeString string) (time.Time, error) {
-	return time.Parse("2006-01-02 15:04:05", timeString)
+// parseTimeString parses dikidi times, which come without an offset
+func parseTimeString(timeString string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04:05", timeString, loc)
 }
 
-func buildURL(serviceID int) string {
-	return "https://dikidi.net/550001?p=3.pi-po-ssm-sd&o=7&s=" + strconv.Itoa(serviceID) + "&rl=0_undefined"
+func (s *dikidiSource) buildURL(serviceID int) string {
+	return strings.NewReplacer(
+		"{company_id}", strconv.Itoa(s.company.ID),
+		"{service_id}", strconv.Itoa(serviceID),
+	).Replace(s.options.Dikidi.BookingURLTemplate)
 }
@@ -0,0 +1,217 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestUnmarshalServerData(t *testing.T) {
+	type testCase struct {
+		data            string
+		expectedMasters int
+		expectedTimes   int
+		expectErr       bool
+	}
+
+	tests := []testCase{
+		{data: `{"data":{"masters":[],"dates_true":[],"times":[]}}`},
+		{
+			data:            `{"data":{"masters":{"1":{"username":"a","service_name":"b"}},"times":{"1":["2025-11-20 08:50:00"]}}}`,
+			expectedMasters: 1,
+			expectedTimes:   1,
+		},
+		{data: `{"data":{"masters":"unknown"}}`, expectErr: true},
+		{data: `{"data":{"times":{"1":"08:50"}}}`, expectErr: true},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_unmarshal_server_data_%d", i), func(t *testing.T) {
+			var data ServerData
+			err := json.Unmarshal([]byte(tCase.data), &data)
+			if tCase.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, data.Data.Masters, tCase.expectedMasters)
+			assert.Len(t, data.Data.Times, tCase.expectedTimes)
+		})
+	}
+}
+
+// shippedRules compiles the parsing rules from the config the bot ships with
+func shippedRules(t *testing.T) Rules {
+	data, err := os.ReadFile("../../config.yaml")
+	require.NoError(t, err)
+	var cfg config.Config
+	require.NoError(t, yaml.Unmarshal(data, &cfg))
+	rules, err := NewRules(&cfg.PollingConfig.Parsing)
+	require.NoError(t, err)
+	return rules
+}
+
+func TestParseSlotInfo(t *testing.T) {
+	order := 2
+
+	type testCase struct {
+		username    string
+		serviceName string
+		expected    *Slot
+	}
+
+	tests := []testCase{
+		{
+			username:    "Лабораторная работа №7 (233 ауд.)",
+			serviceName: "Выполнение лабораторных работ. Электричество",
+			expected:    &Slot{Type: LabTypePerformance, Name: "", Number: 7, Auditorium: 233, Domain: LabDomain("electricity")},
+		},
+		{
+			username:    "Аудиторное занятие №12 (2-ое место) (512 ауд.)",
+			serviceName: "Защита лабораторных работ. Механика",
+			expected:    &Slot{Type: LabTypeDefence, Name: "Аудиторное занятие", Number: 12, Auditorium: 512, Order: &order, Domain: LabDomain("mechanics")},
+		},
+		{
+			username:    "Маятник Обербека (118 ауд.)",
+			serviceName: "Лабораторная работа № 3. Механика",
+			expected:    &Slot{Type: LabTypePerformance, Name: "Маятник Обербека", Number: 3, Auditorium: 118, Domain: LabDomain("mechanics")},
+		},
+		{
+			username:    "Лабораторная работа №3 (118 ауд.)",
+			serviceName: "Виртуальная лаб.",
+			expected:    &Slot{Type: LabTypePerformance, Name: "", Number: 3, Auditorium: 118, Domain: LabDomain("virtual")},
+		},
+		// Domains the rules don't know are no longer taken for virtual labs
+		{
+			username:    "Лабораторная работа №2 (310 ауд.)",
+			serviceName: "Выполнение лабораторных работ. Оптика",
+			expected:    &Slot{Type: LabTypePerformance, Name: "", Number: 2, Auditorium: 310, Domain: LabDomainUnknown},
+		},
+		{username: "Консультация", serviceName: "Консультации"},
+		{username: "Лабораторная работа №7", serviceName: "Электричество"},
+	}
+
+	rules := shippedRules(t)
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_parse_slot_info_%d", i), func(t *testing.T) {
+			actual, err := rules.Parse(tCase.username, tCase.serviceName)
+			if tCase.expected == nil {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, actual)
+		})
+	}
+}
+
+func TestNewRulesRejectsInvalidConfig(t *testing.T) {
+	valid := func() config.ParsingConfig {
+		return config.ParsingConfig{
+			NumberPatterns:     []string{`№\s*(\d+)`},
+			AuditoriumPatterns: []string{`\((\d+)\s*ауд\.\)`},
+			LabDomains:         []config.LabDomainConfig{{Key: "optics", Label: "Оптика"}},
+			Domains:            []config.DomainRuleConfig{{Pattern: "Оптика", Domain: "optics"}, {Pattern: "Разное", Domain: "unknown"}},
+		}
+	}
+
+	noGroup := valid()
+	noGroup.NumberPatterns = []string{`№\s*\d+`}
+	twoGroups := valid()
+	twoGroups.OrderPatterns = []string{`\((\d+)-(\p{L}+) место\)`}
+	invalidPattern := valid()
+	invalidPattern.AuditoriumPatterns = []string{`(\d+`}
+	unknownDomain := valid()
+	unknownDomain.Domains = []config.DomainRuleConfig{{Pattern: "Акустика", Domain: "acoustics"}}
+	noAuditorium := valid()
+	noAuditorium.AuditoriumPatterns = nil
+	duplicateDomain := valid()
+	duplicateDomain.LabDomains = append(duplicateDomain.LabDomains, config.LabDomainConfig{Key: "optics", Label: "Оптика 2"})
+	reservedDomain := valid()
+	reservedDomain.LabDomains = []config.LabDomainConfig{{Key: "unknown", Label: "Прочее"}}
+	unlabeledDomain := valid()
+	unlabeledDomain.LabDomains = []config.LabDomainConfig{{Key: "optics"}}
+
+	tests := []config.ParsingConfig{noGroup, twoGroups, invalidPattern, unknownDomain, noAuditorium, duplicateDomain, reservedDomain, unlabeledDomain}
+
+	opts := valid()
+	rules, err := NewRules(&opts)
+	require.NoError(t, err)
+	// A domain added in the config is parsed without a release
+	slot, err := rules.Parse("Лабораторная работа №4 (301 ауд.)", "Выполнение лабораторных работ. Оптика")
+	require.NoError(t, err)
+	assert.Equal(t, LabDomain("optics"), slot.Domain)
+	assert.Equal(t, "Оптика", rules.Domains().Label(slot.Domain))
+	for i, opts := range tests {
+		t.Run(fmt.Sprintf("test_invalid_rules_%d", i), func(t *testing.T) {
+			_, err := NewRules(&opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseTimeString(t *testing.T) {
+	campus := testutil.Campus(t)
+
+	type testCase struct {
+		hostTimezone string
+		timeString   string
+		expected     time.Time
+	}
+
+	tests := []testCase{
+		{hostTimezone: "UTC", timeString: "2025-11-20 08:50:00", expected: time.Date(2025, 11, 20, 5, 50, 0, 0, time.UTC)},
+		{hostTimezone: "America/Los_Angeles", timeString: "2025-11-20 08:50:00", expected: time.Date(2025, 11, 20, 5, 50, 0, 0, time.UTC)},
+		{hostTimezone: "Asia/Tokyo", timeString: "2025-11-20 20:40:00", expected: time.Date(2025, 11, 20, 17, 40, 0, 0, time.UTC)},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_parse_time_string_%d", i), func(t *testing.T) {
+			testutil.SetHostTimezone(t, tCase.hostTimezone)
+			parsed, err := parseTimeString(tCase.timeString, campus)
+			require.NoError(t, err)
+			assert.True(t, tCase.expected.Equal(parsed))
+			assert.Equal(t, tCase.timeString, parsed.Format("2006-01-02 15:04:05"))
+		})
+	}
+}
+
+func TestParseServerDataKeepsGoodSlots(t *testing.T) {
+	data := &ServerData{Data: ServiceData{
+		Masters: Masters{
+			1: {Username: "Лабораторная работа №3 (118 ауд.)", ServiceName: "Виртуальная лаб."},
+			2: {Username: "Консультация", ServiceName: "Консультации"},
+			3: {Username: "Лабораторная работа №5 (118 ауд.)", ServiceName: "Виртуальная лаб."},
+		},
+		Times: Times{
+			1: {"2025-11-25 17:30:00"},
+			2: {"2025-11-25 17:30:00"},
+			3: {"2025-11-25 17:30:00", "25.11.2025 19:00"},
+		},
+	}}
+	source := NewDikidiSource(fakeTeacherService{}, nil, nil, shippedRules(t), config.CompanyConfig{ID: 550001}, testutil.Campus(t), &config.PollingConfig{}).(*dikidiSource)
+
+	slots, failures := source.ParseServerData(context.Background(), data, 104)
+	require.Len(t, slots, 2)
+	for _, slot := range slots {
+		assert.Len(t, slot.TimesTeachers, 1)
+	}
+
+	require.Len(t, failures, 2)
+	usernames := []string{failures[0].Username, failures[1].Username}
+	assert.ElementsMatch(t, []string{"Консультация", "Лабораторная работа №5 (118 ауд.)"}, usernames)
+	for _, failure := range failures {
+		assert.Equal(t, 104, failure.ServiceID)
+		assert.Error(t, failure.Err)
+	}
+}
@@ -2,99 +2,133 @@ package polling
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
+	"strconv"
 	"sync"
+	"time"
 )
 
-func (s *pollingService) pollServerData(ctx context.Context) (chan ServerData, chan error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// serviceData is a single response of a service. Every service ends with a done marker without data
+type serviceData struct {
+	serviceID int
+	// date is the date the response covers, empty when it covers every date
+	date string
+	// dates lists every date of the service, only the first response of the service has them
+	dates []string
+	data  *ServerData
+	// hash identifies the content hash of the response
+	hash hashKey
+	// unchanged responses are the same as the processed ones, their data holds nothing but the dates
+	unchanged bool
+	done      bool
+}
 
-	results := make(chan ServerData)
+// pollServerData fetches the hot services first, so they don't wait for the rate limiter behind the cold ones
+func (s *dikidiSource) pollServerData(ctx context.Context, hot, cold []int) (chan serviceData, chan error) {
+	results := make(chan serviceData)
 	errChan := make(chan error)
 
-	s.wg.Add(1)
 	go func() {
-		defer s.wg.Done()
 		defer close(errChan)
 		defer close(results)
 
-		wg := sync.WaitGroup{}
-
-		for _, serviceID := range s.serviceIDs {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				result, err := s.processSingleService(ctx, serviceID)
-				if err != nil {
-					select {
-					case errChan <- err:
-					case <-ctx.Done():
-					}
-					return
-				}
-				select {
-				case results <- *result:
-				case <-ctx.Done():
-				}
-			}()
-		}
-		wg.Wait()
+		s.fetchServices(ctx, hot, results, errChan)
+		s.fetchServices(ctx, cold, results, errChan)
 	}()
 
 	return results, errChan
 }
 
-// The initial request retrieves a list of dates, which is used to request all available slots for the serviceID
-func (s *pollingService) processSingleService(ctx context.Context, serviceID int) (*ServerData, error) {
-	initialData, err := s.fetchServerData(ctx, serviceID, nil)
+func (s *dikidiSource) fetchServices(ctx context.Context, serviceIDs []int, results chan<- serviceData, errChan chan<- error) {
+	wg := sync.WaitGroup{}
+
+	for _, serviceID := range serviceIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.processSingleService(ctx, serviceID, results, errChan)
+		}()
+	}
+	wg.Wait()
+}
+
+// The initial request retrieves a list of dates, which is used to request all available slots for the serviceID.
+// Every date is sent on as soon as it arrives, the nearest dates are requested first
+func (s *dikidiSource) processSingleService(ctx context.Context, serviceID int, results chan<- serviceData, errChan chan<- error) {
+	defer send(ctx, results, serviceData{serviceID: serviceID, done: true})
+
+	initialData, unchanged, err := s.fetchServerData(ctx, serviceID, nil)
 	if err != nil {
-		return nil, err
+		send(ctx, errChan, err)
+		return
 	}
 	initialData.Data.ServiceID = serviceID
 
 	dates := initialData.Data.DatesTrue
-	if len(dates) == 0 {
-		return initialData, nil
+	first := ""
+	if len(dates) > 0 {
+		first = dates[0]
+	}
+	if !send(ctx, results, serviceData{serviceID: serviceID, date: first, dates: dates, data: initialData, hash: hashKey{serviceID: serviceID}, unchanged: unchanged}) {
+		return
+	}
+	if len(dates) < 2 {
+		return
 	}
 
 	// API includes data of the first date, so we can skip it
-	for _, date := range dates[1:] {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		newData, err := s.fetchServerData(ctx, serviceID, &date)
-		if err != nil {
-			return nil, err
+	queue := make(chan string)
+	go func() {
+		defer close(queue)
+		for _, date := range dates[1:] {
+			if !send(ctx, queue, date) {
+				return
+			}
 		}
+	}()
 
-		for k, v := range newData.Data.Masters {
-			initialData.Data.Masters[k] = v
-		}
-		for k, v := range newData.Data.Times {
-			if existing, exists := initialData.Data.Times[k]; exists {
-				initialData.Data.Times[k] = append(existing, v...)
-			} else {
-				initialData.Data.Times[k] = v
+	wg := sync.WaitGroup{}
+	for range min(max(s.options.DateConcurrency, 1), len(dates)-1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for date := range queue {
+				data, unchanged, err := s.fetchServerData(ctx, serviceID, &date)
+				if err != nil {
+					send(ctx, errChan, err)
+					continue
+				}
+				data.Data.ServiceID = serviceID
+				send(ctx, results, serviceData{serviceID: serviceID, date: date, data: data, hash: hashKey{serviceID: serviceID, date: date}, unchanged: unchanged})
 			}
-		}
+		}()
 	}
+	wg.Wait()
+}
 
-	return initialData, nil
+// send reports whether the value got through before the context was done
+func send[T any](ctx context.Context, ch chan<- T, value T) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
-func (s *pollingService) fetchServerData(ctx context.Context, serviceID int, date *string) (*ServerData, error) {
-	u, err := url.Parse("https://dikidi.net/ru/mobile/ajax/newrecord/get_datetimes/?company_id=550001")
+// fetchServerData reports whether the response is the same as the last processed one,
+// in which case it isn't decoded and the data holds only the dates
+func (s *dikidiSource) fetchServerData(ctx context.Context, serviceID int, date *string) (*ServerData, bool, error) {
+	u, err := url.Parse(s.options.Dikidi.BaseURL + "/ru/mobile/ajax/newrecord/get_datetimes/")
 	if err != nil {
-		return nil, &ErrFetch{err: err, msg: "Failed to build url"}
+		return nil, false, &ErrFetch{err: err, msg: "Failed to build url"}
 	}
 	q := u.Query()
+	q.Set("company_id", strconv.Itoa(s.company.ID))
 	if date != nil {
 		q.Set("date", *date)
 	}
@@ -103,18 +137,37 @@ func (s *pollingService) fetchServerData(ctx context.Context, serviceID int, dat
 
 	res, err := s.fetchData(ctx, u.String())
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if res.Body != nil {
 		defer res.Body.Close()
 	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, false, &ErrFetch{url: u.String(), msg: "failed to read response", err: err}
+	}
+
+	key := hashKey{serviceID: serviceID}
+	if date != nil {
+		key.date = *date
+	}
+	sum := sha256.Sum256(body)
+	now := time.Now()
+	if hash, ok := s.checkHash(key, sum, now); ok {
+		return &ServerData{Data: ServiceData{DatesTrue: hash.dates}}, true, nil
+	}
 
-	return unmarshalServerData(res.Body, serviceID)
+	data, err := unmarshalServerData(body, serviceID)
+	if err != nil {
+		return nil, false, err
+	}
+	s.storeHash(key, responseHash{sum: sum, dates: data.Data.DatesTrue, processedAt: now})
+	return data, false, nil
 }
 
-func unmarshalServerData(serverData io.ReadCloser, serviceID int) (*ServerData, error) {
+func unmarshalServerData(serverData []byte, serviceID int) (*ServerData, error) {
 	var data ServerData
-	if err := json.NewDecoder(serverData).Decode(&data); err != nil {
+	if err := json.Unmarshal(serverData, &data); err != nil {
 		return nil, &ErrParseData{msg: fmt.Sprintf("serviceID: %d", serviceID), err: err}
 	}
 	return &data, nil
@@ -0,0 +1,65 @@
+package polling
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Ademun/mining-lab-bot/pkg/logger"
+)
+
+// recordingTransport saves every successful dikidi response to dir, so it can be replayed later by a fake server
+type recordingTransport struct {
+	next http.RoundTripper
+	dir  string
+}
+
+func newRecordingTransport(dir string) *recordingTransport {
+	return &recordingTransport{
+		next: http.DefaultTransport,
+		dir:  dir,
+	}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		slog.Warn("Failed to create record dir", "error", err, "service", logger.ServicePolling)
+		return res, nil
+	}
+	name := filepath.Join(t.dir, FixtureName(req))
+	if err := os.WriteFile(name, body, 0o644); err != nil {
+		slog.Warn("Failed to record response", "file", name, "error", err, "service", logger.ServicePolling)
+	}
+	return res, nil
+}
+
+// FixtureName maps a dikidi request to the file its response is recorded to.
+// Datetimes requests are keyed by company, service and date, everything else is treated as a landing page
+func FixtureName(req *http.Request) string {
+	q := req.URL.Query()
+	if strings.HasSuffix(req.URL.Path, "/get_datetimes/") {
+		name := fmt.Sprintf("datetimes_%s_%s", q.Get("company_id"), q.Get("service_id[]"))
+		if date := q.Get("date"); date != "" {
+			name += "_" + date
+		}
+		return name + ".json"
+	}
+	return "page" + strings.ReplaceAll(strings.TrimSuffix(req.URL.Path, "/"), "/", "_") + ".html"
+}
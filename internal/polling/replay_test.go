@@ -0,0 +1,229 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/teacher"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const replayDir = "testdata/replay"
+
+type fakeTeacherService struct{}
+
+func (fakeTeacherService) FindTeachersForTime(_ context.Context, _ time.Time, auditorium int) []teacher.Teacher {
+	if auditorium == 233 {
+		return []teacher.Teacher{{Name: "Иванов И.И."}}
+	}
+	return nil
+}
+
+// recordingNotifier keeps the latest slot by Key, the way the notification cache does, and every catalog diff
+type recordingNotifier struct {
+	mu    sync.Mutex
+	slots map[string]Slot
+	diffs []CatalogDiff
+}
+
+func (n *recordingNotifier) SendNotification(_ context.Context, slot Slot) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.slots == nil {
+		n.slots = make(map[string]Slot)
+	}
+	n.slots[slot.Key()] = slot
+}
+
+func (n *recordingNotifier) NotifyCatalogChange(_ context.Context, diff CatalogDiff) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.diffs = append(n.diffs, diff)
+}
+
+func (n *recordingNotifier) ObserveSlots(_ context.Context, _ []Slot) {}
+
+func (n *recordingNotifier) sorted() []Slot {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	slots := slices.Collect(maps.Values(n.slots))
+	slices.SortFunc(slots, func(a, b Slot) int {
+		return strings.Compare(a.Key(), b.Key())
+	})
+	return slots
+}
+
+// fakeCatalog reports every listed service as added while fresh is set, and the removed ones on every sync
+type fakeCatalog struct {
+	fresh   bool
+	removed []CatalogService
+}
+
+func (c *fakeCatalog) Sync(_ context.Context, _ int, services []CatalogService) ([]CatalogService, []CatalogService, []CatalogService, error) {
+	if c.fresh {
+		return services, nil, nil, nil
+	}
+	return nil, nil, c.removed, nil
+}
+
+func (c *fakeCatalog) AddLabs(_ context.Context, _ []Slot) error {
+	return nil
+}
+
+// newFakeDikidiServer serves recorded responses back, using the same naming as the recorder
+func newFakeDikidiServer(t *testing.T, dir string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(filepath.Join(dir, FixtureName(r)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newReplayService(server *httptest.Server, notifier Notifier, catalog Catalog, rules Rules, recordDir string, campus *time.Location) *pollingService {
+	opts := &config.PollingConfig{
+		Dikidi: config.DikidiConfig{
+			BaseURL:            server.URL,
+			BookingURLTemplate: "https://dikidi.test/{company_id}?s={service_id}",
+			RecordDir:          recordDir,
+		},
+		Mode:            config.ModeNormal,
+		NormalFetchRate: time.Millisecond,
+		MinFetchRate:    time.Millisecond,
+		MaxFetchRate:    time.Millisecond,
+		BackoffFactor:   1,
+		RecoveryFactor:  1,
+		DateConcurrency: 2,
+		ContentHashTTL:  time.Minute,
+	}
+	company := config.CompanyConfig{
+		ID:         550001,
+		Name:       "Тестовая кафедра",
+		ServiceURL: server.URL + "/550001?p=1.pi-ssm",
+	}
+	source := NewDikidiSource(fakeTeacherService{}, nil, catalog, rules, company, campus, opts)
+	return New(notifier, nil, nil, []SlotSource{source}, nil, opts).(*pollingService)
+}
+
+func replay(t *testing.T, dir, recordDir string, campus *time.Location) []Slot {
+	server := newFakeDikidiServer(t, dir)
+	notifier := &recordingNotifier{}
+	s := newReplayService(server, notifier, nil, shippedRules(t), recordDir, campus)
+
+	ctx := context.Background()
+	s.updateIDs(ctx)
+	s.poll(ctx)
+	return notifier.sorted()
+}
+
+func assertGolden(t *testing.T, name string, actual []byte) {
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestReplayGolden(t *testing.T) {
+	// The golden file holds campus times, so it must not depend on the host
+	testutil.SetHostTimezone(t, "America/Los_Angeles")
+	slots := replay(t, replayDir, "", testutil.Campus(t))
+
+	actual, err := json.MarshalIndent(slots, "", "  ")
+	require.NoError(t, err)
+	assertGolden(t, "replay.json", actual)
+}
+
+func TestReplayUnchanged(t *testing.T) {
+	server := newFakeDikidiServer(t, replayDir)
+	notifier := &recordingNotifier{}
+	s := newReplayService(server, notifier, nil, shippedRules(t), "", testutil.Campus(t))
+
+	ctx := context.Background()
+	s.updateIDs(ctx)
+	s.poll(ctx)
+	first := s.sources[0].LastPollStats()
+	require.NotEmpty(t, notifier.sorted())
+
+	// Nothing changed upstream, so the second poll skips every response but the one that failed to parse
+	// and still knows every slot
+	notifier.slots = nil
+	s.poll(ctx)
+	for _, slot := range notifier.sorted() {
+		assert.Equal(t, "https://dikidi.test/550001?s=104", slot.URL)
+	}
+	assert.Equal(t, first, s.sources[0].LastPollStats())
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	campus := testutil.Campus(t)
+	recordDir := t.TempDir()
+	recorded := replay(t, replayDir, recordDir, campus)
+
+	replayed := replay(t, recordDir, "", campus)
+	assert.Equal(t, recorded, replayed)
+}
+
+func TestReplayCatalogLabs(t *testing.T) {
+	server := newFakeDikidiServer(t, replayDir)
+	notifier := &recordingNotifier{}
+	catalog := &fakeCatalog{fresh: true}
+	s := newReplayService(server, notifier, catalog, shippedRules(t), "", testutil.Campus(t))
+	labKeys := func(labs []Slot) []string {
+		keys := make([]string, 0, len(labs))
+		for _, lab := range labs {
+			assert.Empty(t, lab.TimesTeachers)
+			keys = append(keys, lab.Key())
+		}
+		return keys
+	}
+
+	ctx := context.Background()
+	s.updateIDs(ctx)
+	require.Len(t, notifier.diffs, 1)
+	added := notifier.diffs[0].Added
+	require.NotEmpty(t, added)
+	// The added services aren't polled yet, their labs are unknown
+	assert.Empty(t, notifier.diffs[0].Published)
+
+	s.poll(ctx)
+	require.Len(t, notifier.diffs, 2)
+	slotKeys := make([]string, 0)
+	for _, slot := range notifier.sorted() {
+		slotKeys = append(slotKeys, slot.Key())
+	}
+	require.NotEmpty(t, slotKeys)
+	assert.ElementsMatch(t, slotKeys, labKeys(notifier.diffs[1].Published))
+
+	// Labs are published once
+	s.poll(ctx)
+	assert.Len(t, notifier.diffs, 2)
+
+	catalog.fresh = false
+	catalog.removed = added
+	s.updateIDs(ctx)
+	require.Len(t, notifier.diffs, 3)
+	assert.ElementsMatch(t, slotKeys, labKeys(notifier.diffs[2].Withdrawn))
+}
@@ -0,0 +1,192 @@
+package polling
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+)
+
+// Rules read lab slots out of master and service names
+type Rules interface {
+	// Parse returns the slot described by the names, without times and company details
+	Parse(username, serviceName string) (*Slot, error)
+	// Domains lists the configured lab domains, the unknown domain isn't one of them
+	Domains() Domains
+}
+
+type domainRule struct {
+	re     *regexp.Regexp
+	domain LabDomain
+}
+
+type parsingRules struct {
+	number          []*regexp.Regexp
+	auditorium      []*regexp.Regexp
+	order           []*regexp.Regexp
+	labDomains      Domains
+	domains         []domainRule
+	defenceKeywords []string
+}
+
+// maxDomainKeyLen keeps the domain keys short enough for Telegram callback data
+const maxDomainKeyLen = 32
+
+// NewRules compiles the parsing rules, rejecting patterns that don't capture exactly one value
+// and domain rules pointing at domains that aren't configured
+func NewRules(opts *config.ParsingConfig) (Rules, error) {
+	number, err := compilePatterns("number", opts.NumberPatterns)
+	if err != nil {
+		return nil, err
+	}
+	if len(number) == 0 {
+		return nil, fmt.Errorf("no number patterns")
+	}
+	auditorium, err := compilePatterns("auditorium", opts.AuditoriumPatterns)
+	if err != nil {
+		return nil, err
+	}
+	if len(auditorium) == 0 {
+		return nil, fmt.Errorf("no auditorium patterns")
+	}
+	order, err := compilePatterns("order", opts.OrderPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	labDomains, err := compileLabDomains(opts.LabDomains)
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]domainRule, 0, len(opts.Domains))
+	for _, rule := range opts.Domains {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain pattern %q: %w", rule.Pattern, err)
+		}
+		domain := LabDomain(rule.Domain)
+		if _, ok := labDomains.Find(domain); !ok && domain != LabDomainUnknown {
+			return nil, fmt.Errorf("unknown domain %q for pattern %q", rule.Domain, rule.Pattern)
+		}
+		domains = append(domains, domainRule{re: re, domain: domain})
+	}
+
+	return &parsingRules{
+		number:          number,
+		auditorium:      auditorium,
+		order:           order,
+		labDomains:      labDomains,
+		domains:         domains,
+		defenceKeywords: opts.DefenceKeywords,
+	}, nil
+}
+
+func compileLabDomains(configs []config.LabDomainConfig) (Domains, error) {
+	domains := make(Domains, 0, len(configs))
+	for _, domain := range configs {
+		key := LabDomain(domain.Key)
+		switch {
+		case key == "" || len(key) > maxDomainKeyLen:
+			return nil, fmt.Errorf("domain key %q must have 1 to %d bytes", domain.Key, maxDomainKeyLen)
+		case key == LabDomainUnknown:
+			return nil, fmt.Errorf("domain key %q is reserved", domain.Key)
+		case domain.Label == "":
+			return nil, fmt.Errorf("domain %q has no label", domain.Key)
+		}
+		if _, ok := domains.Find(key); ok {
+			return nil, fmt.Errorf("duplicate domain %q", domain.Key)
+		}
+		domains = append(domains, Domain{Key: key, Label: domain.Label})
+	}
+	return domains, nil
+}
+
+func compilePatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", field, pattern, err)
+		}
+		if re.NumSubexp() != 1 {
+			return nil, fmt.Errorf("%s pattern %q must have exactly one group", field, pattern)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func (r *parsingRules) Domains() Domains {
+	return r.labDomains
+}
+
+func (r *parsingRules) Parse(username, serviceName string) (*Slot, error) {
+	number, ok := findInt(r.number, username, serviceName)
+	if !ok {
+		return nil, &ErrParseData{data: username + " " + serviceName, msg: "lab number not found", err: errors.New("invalid lab name format")}
+	}
+	auditorium, ok := findInt(r.auditorium, username, serviceName)
+	if !ok {
+		return nil, &ErrParseData{data: username + " " + serviceName, msg: "lab auditorium not found", err: errors.New("invalid lab name format")}
+	}
+	var order *int
+	if labOrder, ok := findInt(r.order, username, serviceName); ok {
+		order = &labOrder
+	}
+
+	return &Slot{
+		Name:       r.parseName(username),
+		Number:     number,
+		Auditorium: auditorium,
+		Order:      order,
+		Domain:     r.parseDomain(serviceName),
+		Type:       r.parseType(username),
+	}, nil
+}
+
+// findInt returns the value captured by the first matching pattern, the master name goes before the service name
+func findInt(patterns []*regexp.Regexp, username, serviceName string) (int, bool) {
+	for _, re := range patterns {
+		for _, s := range []string{username, serviceName} {
+			if match := re.FindStringSubmatch(s); match != nil {
+				if value, err := strconv.Atoi(match[1]); err == nil {
+					return value, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func (r *parsingRules) parseName(username string) string {
+	name := username
+	for _, patterns := range [][]*regexp.Regexp{r.number, r.auditorium, r.order} {
+		for _, re := range patterns {
+			name = re.ReplaceAllString(name, "")
+		}
+	}
+	name = strings.TrimPrefix(name, "Лабораторная работа")
+	name = strings.TrimSpace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func (r *parsingRules) parseDomain(serviceName string) LabDomain {
+	for _, rule := range r.domains {
+		if rule.re.MatchString(serviceName) {
+			return rule.domain
+		}
+	}
+	return LabDomainUnknown
+}
+
+func (r *parsingRules) parseType(username string) LabType {
+	for _, keyword := range r.defenceKeywords {
+		if strings.Contains(username, keyword) {
+			return LabTypeDefence
+		}
+	}
+	return LabTypePerformance
+}
@@ -8,16 +8,16 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
-func (s *pollingService) fetchServiceIDs(ctx context.Context) ([]int, error) {
+func (s *dikidiSource) fetchServiceList(ctx context.Context) ([]CatalogService, error) {
 	doc, err := s.fetchDocument(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	serviceIDs := make([]int, 0)
+	services := make([]CatalogService, 0)
 	var parsingErr error
-	doc.Find(".newrecord2").Each(func(_ int, s *goquery.Selection) {
-		dataOptions, exists := s.Attr("data-options")
+	doc.Find(".newrecord2").Each(func(_ int, sel *goquery.Selection) {
+		dataOptions, exists := sel.Attr("data-options")
 		if !exists {
 			return
 		}
@@ -30,7 +30,7 @@ func (s *pollingService) fetchServiceIDs(ctx context.Context) ([]int, error) {
 		categories := pageOptions.StepData.List
 		for _, category := range categories {
 			for _, service := range category.Services {
-				serviceIDs = append(serviceIDs, service.ID)
+				services = append(services, CatalogService{CompanyID: s.company.ID, ServiceID: service.ID, Name: service.Name})
 			}
 		}
 	})
@@ -39,11 +39,11 @@ func (s *pollingService) fetchServiceIDs(ctx context.Context) ([]int, error) {
 		return nil, parsingErr
 	}
 
-	return serviceIDs, nil
+	return services, nil
 }
 
-func (s *pollingService) fetchDocument(ctx context.Context) (*goquery.Document, error) {
-	res, err := s.fetchData(ctx, s.options.ServiceURL)
+func (s *dikidiSource) fetchDocument(ctx context.Context) (*goquery.Document, error) {
+	res, err := s.fetchData(ctx, s.company.ServiceURL)
 	if err != nil {
 		return nil, err
 	}
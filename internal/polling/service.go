@@ -3,53 +3,71 @@ package polling
 import (
 	"context"
 	"log/slog"
-	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/Ademun/mining-lab-bot/internal/teacher"
+	"github.com/Ademun/mining-lab-bot/internal/calendar"
 	"github.com/Ademun/mining-lab-bot/pkg/config"
 	"github.com/Ademun/mining-lab-bot/pkg/logger"
-	"golang.org/x/time/rate"
 )
 
+// bookingHorizonDays is how far ahead dikidi lets students book slots
+const bookingHorizonDays = 14
+
 type Notifier interface {
 	SendNotification(ctx context.Context, slot Slot)
+	// NotifyCatalogChange tells about services published on or removed from a booking page
+	NotifyCatalogChange(ctx context.Context, diff CatalogDiff)
+	// ObserveSlots tells about every slot the sources have after a successful poll, unchanged ones included
+	ObserveSlots(ctx context.Context, slots []Slot)
+}
+
+// PollObserver watches how polls go, e.g. to tell the admin about outages
+type PollObserver interface {
+	ObservePoll(ctx context.Context, stats []PollStats)
 }
 
 type Service interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context)
+	Mode() config.PollingMode
+	// SetMode switches polling and fetch rates at runtime, the new poll rate applies after the running poll
+	SetMode(mode config.PollingMode)
+	Status() Status
 }
 
 type pollingService struct {
-	notifier         Notifier
-	teacherService   teacher.Service
-	options          config.PollingConfig
-	serviceIDs       []int
-	httpClient       http.Client
-	fetchRateLimiter *rate.Limiter
-	wg               sync.WaitGroup
-	mu               sync.RWMutex
+	notifier Notifier
+	observer PollObserver
+	demand   DemandProvider
+	sources  []SlotSource
+	calendar calendar.Calendar
+	options  config.PollingConfig
+	status   Status
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
 }
 
-func New(notifier Notifier, teacherService teacher.Service, opts *config.PollingConfig) Service {
+// New creates the poller. The observer and the demand provider are optional, without demand every service is polled on every tick
+func New(notifier Notifier, observer PollObserver, demand DemandProvider, sources []SlotSource, cal calendar.Calendar, opts *config.PollingConfig) Service {
 	return &pollingService{
-		notifier:       notifier,
-		teacherService: teacherService,
-		options:        *opts,
-		serviceIDs:     make([]int, 0),
-		httpClient: http.Client{
-			Timeout: time.Second * 30,
-		},
-		fetchRateLimiter: rate.NewLimiter(rate.Every(opts.GetFetchRate()), 1),
-		wg:               sync.WaitGroup{},
-		mu:               sync.RWMutex{},
+		notifier: notifier,
+		observer: observer,
+		demand:   demand,
+		sources:  sources,
+		calendar: cal,
+		options:  *opts,
+		wg:       sync.WaitGroup{},
+		mu:       sync.RWMutex{},
 	}
 }
 
 func (s *pollingService) Start(ctx context.Context) error {
 	slog.Info("Starting", "options", s.options, "service", logger.ServicePolling)
+	s.mu.Lock()
+	s.status.StartedAt = time.Now()
+	s.mu.Unlock()
 
 	s.startIDUpdateLoop(ctx)
 	s.startPollingLoop(ctx)
@@ -73,6 +91,34 @@ func (s *pollingService) Stop(ctx context.Context) {
 	}
 }
 
+func (s *pollingService) Mode() config.PollingMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.options.Mode
+}
+
+func (s *pollingService) SetMode(mode config.PollingMode) {
+	s.mu.Lock()
+	s.options.Mode = mode
+	s.mu.Unlock()
+
+	for _, source := range s.sources {
+		source.SetMode(mode)
+	}
+	slog.Info("Polling mode changed", "mode", mode, "service", logger.ServicePolling)
+}
+
+func (s *pollingService) Status() Status {
+	s.mu.RLock()
+	status := s.status
+	s.mu.RUnlock()
+
+	for _, source := range s.sources {
+		status.FetchRate = max(status.FetchRate, source.FetchRate())
+	}
+	return status
+}
+
 func (s *pollingService) startPollingLoop(ctx context.Context) {
 	s.poll(ctx)
 
@@ -98,6 +144,9 @@ func (s *pollingService) startPollingLoop(ctx context.Context) {
 func (s *pollingService) getPolRate() time.Duration {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	if s.options.OffDayPollRate > 0 && !s.hasTeachingDaysAhead(time.Now()) {
+		return s.options.OffDayPollRate
+	}
 	switch s.options.Mode {
 	case config.ModeNormal:
 		return s.options.NormalPollRate
@@ -108,6 +157,19 @@ func (s *pollingService) getPolRate() time.Duration {
 	return s.options.NormalPollRate
 }
 
+// hasTeachingDaysAhead reports whether slots may be booked for any day of the booking horizon
+func (s *pollingService) hasTeachingDaysAhead(now time.Time) bool {
+	if s.calendar == nil {
+		return true
+	}
+	for day := range bookingHorizonDays {
+		if s.calendar.IsTeachingDay(now.AddDate(0, 0, day)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *pollingService) poll(ctx context.Context) {
 	s.wg.Add(1)
 	defer s.wg.Done()
@@ -115,46 +177,98 @@ func (s *pollingService) poll(ctx context.Context) {
 	wg := sync.WaitGroup{}
 	sem := make(chan struct{}, 100)
 	pollStart := time.Now()
-	dataChan, errChan := s.pollServerData(ctx)
-	for dataChan != nil || errChan != nil {
-		select {
-		case <-ctx.Done():
-			return
-		case data, ok := <-dataChan:
-			if !ok {
-				dataChan = nil
-				continue
-			}
-
-			parseStart := time.Now()
-			slots, err := s.ParseServerData(ctx, &data, data.Data.ServiceID)
-			recordParsing(time.Since(parseStart), err != nil)
+	demand := s.findDemand(ctx)
 
-			if err != nil {
-				slog.Warn("Parsing error", "error", err, "service", logger.ServicePolling)
-			}
-
-			for _, slot := range slots {
+	var succeeded atomic.Bool
+	sourcesWg := sync.WaitGroup{}
+	for _, source := range s.sources {
+		sourcesWg.Add(1)
+		go func() {
+			defer sourcesWg.Done()
+			ok := s.pollSource(ctx, source, demand, func(slot Slot) {
 				sem <- struct{}{}
 				wg.Add(1)
-				go func(slot Slot) {
+				go func() {
 					defer wg.Done()
 					s.notifier.SendNotification(ctx, slot)
 					<-sem
-				}(slot)
+				}()
+			})
+			if ok {
+				succeeded.Store(true)
+			}
+			// Labs of the added services are known once they're polled
+			if diff := source.PublishedLabs(); diff != nil {
+				s.notifyCatalogChange(ctx, *diff)
+			}
+		}()
+	}
+	sourcesWg.Wait()
+
+	if succeeded.Load() {
+		s.mu.Lock()
+		s.status.LastPoll = time.Now()
+		s.mu.Unlock()
+
+		current := make([]Slot, 0)
+		for _, source := range s.sources {
+			current = append(current, source.CurrentSlots()...)
+		}
+		s.notifier.ObserveSlots(ctx, current)
+	}
+	if s.observer != nil && ctx.Err() == nil {
+		stats := make([]PollStats, 0, len(s.sources))
+		for _, source := range s.sources {
+			stats = append(stats, source.LastPollStats())
+		}
+		s.observer.ObservePoll(ctx, stats)
+	}
+
+	recordPolling(time.Since(pollStart))
+	wg.Wait()
+}
+
+// findDemand returns nil when the demand is unknown, so that nothing is left unpolled
+func (s *pollingService) findDemand(ctx context.Context) *Demand {
+	if s.demand == nil {
+		return nil
+	}
+	labs, err := s.demand.FindDemand(ctx)
+	if err != nil {
+		slog.Warn("Failed to find demand, polling every service", "error", err, "service", logger.ServicePolling)
+		return nil
+	}
+	return &Demand{Labs: labs}
+}
+
+// pollSource reports whether the source got through: it either yielded slots or polled without errors.
+// A source stuck in a 429 backoff does neither
+func (s *pollingService) pollSource(ctx context.Context, source SlotSource, demand *Demand, notify func(slot Slot)) bool {
+	gotSlots, gotErrors := false, false
+	slotsChan, errChan := source.PollSlots(ctx, demand)
+	for slotsChan != nil || errChan != nil {
+		select {
+		case <-ctx.Done():
+			return false
+		case slots, ok := <-slotsChan:
+			if !ok {
+				slotsChan = nil
+				continue
+			}
+			gotSlots = true
+			for _, slot := range slots {
+				notify(slot)
 			}
 		case err, ok := <-errChan:
 			if !ok {
 				errChan = nil
 				continue
 			}
+			gotErrors = true
 			slog.Warn("Polling error", "error", err, "service", logger.ServicePolling)
 		}
 	}
-	recordPolling(time.Since(pollStart))
-	wg.Wait()
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return gotSlots || !gotErrors
 }
 
 func (s *pollingService) startIDUpdateLoop(ctx context.Context) {
@@ -180,13 +294,33 @@ func (s *pollingService) updateIDs(ctx context.Context) {
 	s.wg.Add(1)
 	defer s.wg.Done()
 
-	ids, err := s.fetchServiceIDs(ctx)
-	if err != nil {
-		slog.Warn("Failed to fetch service IDs", "error", err, "service", logger.ServicePolling)
-		return
+	updated := false
+	for _, source := range s.sources {
+		diff, err := source.UpdateServices(ctx)
+		if err != nil {
+			slog.Warn("Failed to fetch service IDs", "error", err, "service", logger.ServicePolling)
+			continue
+		}
+		updated = true
+		if diff != nil {
+			s.notifyCatalogChange(ctx, *diff)
+		}
 	}
+	if updated {
+		s.mu.Lock()
+		s.status.LastServiceUpdate = time.Now()
+		s.mu.Unlock()
+	}
+}
 
-	s.mu.Lock()
-	s.serviceIDs = ids
-	s.mu.Unlock()
+func (s *pollingService) notifyCatalogChange(ctx context.Context, diff CatalogDiff) {
+	slog.Info("Service catalog changed",
+		"company", diff.CompanyID,
+		"added", len(diff.Added),
+		"returned", len(diff.Returned),
+		"removed", len(diff.Removed),
+		"published", len(diff.Published),
+		"withdrawn", len(diff.Withdrawn),
+		"service", logger.ServicePolling)
+	s.notifier.NotifyCatalogChange(ctx, diff)
 }
@@ -0,0 +1,37 @@
+package polling
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+)
+
+// Quarantine keeps the master entries the parser failed on, so new naming formats can be spotted
+type Quarantine interface {
+	Add(ctx context.Context, companyID int, failures []ParseFailure) error
+}
+
+// SlotSource is a booking system the poller watches for lab slots.
+// Each source owns its own fetching, rate limiting and parsing
+type SlotSource interface {
+	// UpdateServices refreshes the list of services that PollSlots should fetch.
+	// It returns what changed on the booking page since the previous refresh, nil when nothing did
+	UpdateServices(ctx context.Context) (*CatalogDiff, error)
+	// PublishedLabs returns the labs of the added services polled since the previous call, nil when there are none
+	PublishedLabs() *CatalogDiff
+	// PollSlots fetches the services due for a poll and yields merged slots as the dates of a service arrive.
+	// A slot may be yielded several times during a poll, each time with every time known for it so far.
+	// Services with demand come first, a nil demand means every service is due.
+	// Both channels are closed once polling is finished
+	PollSlots(ctx context.Context, demand *Demand) (chan []Slot, chan error)
+	// SetMode switches the source between normal and aggressive fetching
+	SetMode(mode config.PollingMode)
+	// FetchRate returns the current interval between requests, it grows while the source backs off
+	FetchRate() time.Duration
+	// LastPollStats sums up the latest finished PollSlots
+	LastPollStats() PollStats
+	// CurrentSlots returns every slot the latest polls of the services found, including the services skipped this time.
+	// Times of dates that failed to fetch are kept from the polls before
+	CurrentSlots() []Slot
+}
@@ -0,0 +1,16 @@
+package quarantine
+
+import "time"
+
+// Entry is a raw master entry the parser failed on. The same entry seen again only moves LastSeen
+type Entry struct {
+	ID          int64     `db:"id"`
+	CompanyID   int       `db:"company_id"`
+	ServiceID   int       `db:"service_id"`
+	Username    string    `db:"username"`
+	ServiceName string    `db:"service_name"`
+	Reason      string    `db:"reason"`
+	Occurrences int       `db:"occurrences"`
+	FirstSeen   time.Time `db:"first_seen"`
+	LastSeen    time.Time `db:"last_seen"`
+}
@@ -0,0 +1,53 @@
+package quarantine
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+)
+
+type Service interface {
+	polling.Quarantine
+	// List returns the entries seen most recently first
+	List(ctx context.Context, limit int) ([]Entry, error)
+}
+
+type quarantineService struct {
+	repo Repo
+}
+
+func New(repo Repo) Service {
+	return &quarantineService{repo: repo}
+}
+
+func (s *quarantineService) Add(ctx context.Context, companyID int, failures []polling.ParseFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	now := time.Now()
+	entries := make([]Entry, 0, len(failures))
+	seen := make(map[[2]string]bool, len(failures))
+	for _, failure := range failures {
+		// A master with several bad times is still a single entry
+		key := [2]string{failure.Username, failure.ServiceName}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entries = append(entries, Entry{
+			CompanyID:   companyID,
+			ServiceID:   failure.ServiceID,
+			Username:    failure.Username,
+			ServiceName: failure.ServiceName,
+			Reason:      failure.Err.Error(),
+			FirstSeen:   now,
+			LastSeen:    now,
+		})
+	}
+	return s.repo.Upsert(ctx, entries)
+}
+
+func (s *quarantineService) List(ctx context.Context, limit int) ([]Entry, error) {
+	return s.repo.FindRecent(ctx, limit)
+}
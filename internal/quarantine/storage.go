@@ -0,0 +1,56 @@
+package quarantine
+
+import (
+	"context"
+
+	"github.com/Ademun/mining-lab-bot/pkg/errs"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repo interface {
+	// Upsert adds new entries and bumps the ones already in quarantine
+	Upsert(ctx context.Context, entries []Entry) error
+	FindRecent(ctx context.Context, limit int) ([]Entry, error)
+}
+
+type quarantineRepo struct {
+	db *sqlx.DB
+}
+
+func NewRepo(db *sqlx.DB) Repo {
+	return &quarantineRepo{db: db}
+}
+
+const quarantineUpsert = `
+insert into parse_quarantine
+(company_id, service_id, username, service_name, reason, first_seen, last_seen)
+values
+(:company_id, :service_id, :username, :service_name, :reason, :first_seen, :last_seen)
+on conflict (company_id, username, service_name) do update
+set service_id = excluded.service_id, reason = excluded.reason, last_seen = excluded.last_seen,
+    occurrences = occurrences + 1`
+
+func (r *quarantineRepo) Upsert(ctx context.Context, entries []Entry) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errs.ErrBeginTransaction
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		if _, err := tx.NamedExecContext(ctx, quarantineUpsert, entry); err != nil {
+			return &errs.ErrQueryExecution{Operation: "Upsert", Query: quarantineUpsert, Err: err}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *quarantineRepo) FindRecent(ctx context.Context, limit int) ([]Entry, error) {
+	query := `select * from parse_quarantine order by last_seen desc, id desc limit ?`
+	var entries []Entry
+	if err := r.db.SelectContext(ctx, &entries, query, limit); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindRecent", Query: query, Err: err}
+	}
+	return entries, nil
+}
@@ -0,0 +1,38 @@
+package quarantine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantineRepo(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+
+	repo := NewRepo(db)
+	first := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+	entry := Entry{CompanyID: 550001, ServiceID: 104, Username: "Консультация", ServiceName: "Консультации", Reason: "lab number not found"}
+
+	entry.FirstSeen, entry.LastSeen = first, first
+	require.NoError(t, repo.Upsert(ctx, []Entry{entry}))
+	entry.FirstSeen, entry.LastSeen = later, later
+	require.NoError(t, repo.Upsert(ctx, []Entry{entry}))
+	other := Entry{CompanyID: 550001, ServiceID: 105, Username: "Экскурсия", ServiceName: "Прочее", Reason: "lab number not found", FirstSeen: first, LastSeen: first}
+	require.NoError(t, repo.Upsert(ctx, []Entry{other}))
+
+	entries, err := repo.FindRecent(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	// The same entry seen again keeps its first sighting
+	assert.Equal(t, "Консультация", entries[0].Username)
+	assert.Equal(t, 2, entries[0].Occurrences)
+	assert.True(t, first.Equal(entries[0].FirstSeen))
+	assert.True(t, later.Equal(entries[0].LastSeen))
+	assert.Equal(t, 1, entries[1].Occurrences)
+}
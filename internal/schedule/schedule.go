@@ -0,0 +1,158 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+)
+
+const (
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+)
+
+// Lesson is a single bell schedule entry, the times are "15:04" formatted
+type Lesson struct {
+	Number int
+	Start  string
+	End    string
+}
+
+// Schedule is the bell schedule of the university
+type Schedule interface {
+	// Lessons returns the lessons of the date, with schedule changes applied
+	Lessons(date time.Time) []Lesson
+	// WeekdayLessons returns the regular lessons of the weekday, ignoring schedule changes
+	WeekdayLessons(weekday time.Weekday) []Lesson
+	// LessonAt returns the lesson of the date that is going on at the moment
+	LessonAt(t time.Time) (Lesson, bool)
+}
+
+type change struct {
+	name    string
+	from    string
+	to      string
+	lessons []Lesson
+}
+
+func (c *change) contains(date string) bool {
+	return date >= c.from && (c.to == "" || date <= c.to)
+}
+
+type bellSchedule struct {
+	lessons  []Lesson
+	weekdays map[time.Weekday][]Lesson
+	changes  []change
+	location *time.Location
+}
+
+// New builds the schedule, lesson times are wall clock times in the campus location
+func New(opts *config.ScheduleConfig, loc *time.Location) (Schedule, error) {
+	s := &bellSchedule{
+		weekdays: make(map[time.Weekday][]Lesson),
+		location: loc,
+	}
+
+	lessons, err := parseLessons(opts.Lessons)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lessons: %w", err)
+	}
+	if len(lessons) == 0 {
+		return nil, fmt.Errorf("no lessons configured")
+	}
+	s.lessons = lessons
+
+	for name, weekdayLessons := range opts.Weekdays {
+		weekday, ok := parseWeekday(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday: %s", name)
+		}
+		if s.weekdays[weekday], err = parseLessons(weekdayLessons); err != nil {
+			return nil, fmt.Errorf("invalid %s lessons: %w", name, err)
+		}
+	}
+
+	for _, c := range opts.Changes {
+		if _, err := time.Parse(dateLayout, c.From); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		if c.To != "" {
+			if _, err := time.Parse(dateLayout, c.To); err != nil {
+				return nil, fmt.Errorf("%s: %w", c.Name, err)
+			}
+			if c.To < c.From {
+				return nil, fmt.Errorf("%s: change ends before it starts", c.Name)
+			}
+		}
+		changeLessons, err := parseLessons(c.Lessons)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		s.changes = append(s.changes, change{name: c.Name, from: c.From, to: c.To, lessons: changeLessons})
+	}
+	return s, nil
+}
+
+// parseLessons numbers the lessons by their position and checks they don't overlap
+func parseLessons(configs []config.LessonConfig) ([]Lesson, error) {
+	lessons := make([]Lesson, 0, len(configs))
+	for idx, c := range configs {
+		start, err := time.Parse(clockLayout, c.Start)
+		if err != nil {
+			return nil, fmt.Errorf("lesson %d: %w", idx+1, err)
+		}
+		end, err := time.Parse(clockLayout, c.End)
+		if err != nil {
+			return nil, fmt.Errorf("lesson %d: %w", idx+1, err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("lesson %d ends before it starts", idx+1)
+		}
+		lesson := Lesson{Number: idx + 1, Start: start.Format(clockLayout), End: end.Format(clockLayout)}
+		if idx > 0 && lesson.Start < lessons[idx-1].End {
+			return nil, fmt.Errorf("lesson %d starts before the previous one ends", idx+1)
+		}
+		lessons = append(lessons, lesson)
+	}
+	return lessons, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), name) {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+func (s *bellSchedule) Lessons(date time.Time) []Lesson {
+	local := date.In(s.location)
+	day := local.Format(dateLayout)
+	// The latest change wins, so a narrower change can be listed after a wider one
+	for i := len(s.changes) - 1; i >= 0; i-- {
+		if s.changes[i].contains(day) {
+			return s.changes[i].lessons
+		}
+	}
+	return s.WeekdayLessons(local.Weekday())
+}
+
+func (s *bellSchedule) WeekdayLessons(weekday time.Weekday) []Lesson {
+	if lessons, ok := s.weekdays[weekday]; ok {
+		return lessons
+	}
+	return s.lessons
+}
+
+func (s *bellSchedule) LessonAt(t time.Time) (Lesson, bool) {
+	clock := t.In(s.location).Format(clockLayout)
+	for _, lesson := range s.Lessons(t) {
+		if clock >= lesson.Start && clock < lesson.End {
+			return lesson, true
+		}
+	}
+	return Lesson{}, false
+}
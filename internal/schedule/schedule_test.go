@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() *config.ScheduleConfig {
+	return &config.ScheduleConfig{
+		Lessons: []config.LessonConfig{
+			{Start: "08:50", End: "10:20"},
+			{Start: "10:35", End: "12:05"},
+			{Start: "12:35", End: "14:05"},
+		},
+		Weekdays: map[string][]config.LessonConfig{
+			"saturday": {
+				{Start: "08:50", End: "10:00"},
+				{Start: "10:10", End: "11:20"},
+			},
+		},
+		Changes: []config.ScheduleChangeConfig{
+			{Name: "Short week", From: "2025-12-22", To: "2025-12-27", Lessons: []config.LessonConfig{
+				{Start: "09:00", End: "10:00"},
+			}},
+			{Name: "Open day", From: "2025-12-24", To: "2025-12-24", Lessons: []config.LessonConfig{
+				{Start: "11:00", End: "12:00"},
+			}},
+		},
+	}
+}
+
+func TestLessonAt(t *testing.T) {
+	type testCase struct {
+		time     string
+		expected *Lesson
+	}
+
+	tests := []testCase{
+		{time: "2025-12-15 08:50", expected: &Lesson{Number: 1, Start: "08:50", End: "10:20"}},
+		{time: "2025-12-15 10:19", expected: &Lesson{Number: 1, Start: "08:50", End: "10:20"}},
+		{time: "2025-12-15 10:20", expected: nil},
+		{time: "2025-12-15 12:35", expected: &Lesson{Number: 3, Start: "12:35", End: "14:05"}},
+		// Shortened Saturday
+		{time: "2025-12-20 10:10", expected: &Lesson{Number: 2, Start: "10:10", End: "11:20"}},
+		{time: "2025-12-20 12:35", expected: nil},
+		// Schedule changes win over weekdays, the latest change wins over earlier ones
+		{time: "2025-12-22 08:50", expected: nil},
+		{time: "2025-12-22 09:00", expected: &Lesson{Number: 1, Start: "09:00", End: "10:00"}},
+		{time: "2025-12-27 09:00", expected: &Lesson{Number: 1, Start: "09:00", End: "10:00"}},
+		{time: "2025-12-24 11:00", expected: &Lesson{Number: 1, Start: "11:00", End: "12:00"}},
+		{time: "2025-12-28 08:50", expected: &Lesson{Number: 1, Start: "08:50", End: "10:20"}},
+	}
+
+	campus := testutil.Campus(t)
+	// Lessons are campus wall clock times, whatever the host thinks
+	testutil.SetHostTimezone(t, "America/New_York")
+	sched, err := New(testConfig(), campus)
+	require.NoError(t, err)
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_lesson_at_%d", i), func(t *testing.T) {
+			at, err := time.ParseInLocation("2006-01-02 15:04", tCase.time, campus)
+			require.NoError(t, err)
+			// Times decoded from JSON or computed on the host are in other locations
+			at = at.Local()
+			lesson, ok := sched.LessonAt(at)
+			if tCase.expected == nil {
+				assert.False(t, ok)
+				return
+			}
+			assert.True(t, ok)
+			assert.Equal(t, *tCase.expected, lesson)
+		})
+	}
+}
+
+func TestWeekdayLessonsIgnoresChanges(t *testing.T) {
+	sched, err := New(testConfig(), time.UTC)
+	require.NoError(t, err)
+
+	assert.Len(t, sched.WeekdayLessons(time.Wednesday), 3)
+	assert.Len(t, sched.WeekdayLessons(time.Saturday), 2)
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	tests := []*config.ScheduleConfig{
+		{},
+		{Lessons: []config.LessonConfig{{Start: "8:50am", End: "10:20"}}},
+		{Lessons: []config.LessonConfig{{Start: "10:20", End: "08:50"}}},
+		{Lessons: []config.LessonConfig{{Start: "08:50", End: "10:20"}, {Start: "10:00", End: "11:30"}}},
+		{
+			Lessons:  []config.LessonConfig{{Start: "08:50", End: "10:20"}},
+			Weekdays: map[string][]config.LessonConfig{"caturday": {{Start: "08:50", End: "10:20"}}},
+		},
+		{
+			Lessons: []config.LessonConfig{{Start: "08:50", End: "10:20"}},
+			Changes: []config.ScheduleChangeConfig{{Name: "Backwards", From: "2025-12-24", To: "2025-12-22"}},
+		},
+	}
+
+	for i, opts := range tests {
+		t.Run(fmt.Sprintf("test_invalid_config_%d", i), func(t *testing.T) {
+			_, err := New(opts, time.UTC)
+			assert.Error(t, err)
+		})
+	}
+}
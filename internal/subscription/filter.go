@@ -3,11 +3,11 @@ package subscription
 import (
 	"github.com/Ademun/mining-lab-bot/internal/polling"
 	"github.com/Masterminds/squirrel"
-	"github.com/google/uuid"
 )
 
 type SubFilters struct {
 	UserID        int
+	CompanyID     int
 	Type          *polling.LabType
 	LabNumber     int
 	LabAuditorium int
@@ -22,6 +22,12 @@ func (f *SubFilters) buildQuery() (string, []interface{}, error) {
 	if f.UserID != 0 {
 		conditions = append(conditions, squirrel.Eq{"user_id": f.UserID})
 	}
+	if f.CompanyID != 0 {
+		conditions = append(conditions, squirrel.Or{
+			squirrel.Eq{"company_id": f.CompanyID},
+			squirrel.Eq{"company_id": nil},
+		})
+	}
 	if f.Type != nil {
 		conditions = append(conditions, squirrel.Eq{"lab_type": f.Type})
 	}
@@ -46,34 +52,3 @@ func (f *SubFilters) buildQuery() (string, []interface{}, error) {
 
 	return q.ToSql()
 }
-
-type TimeFilters struct {
-	SubUUIDs []uuid.UUID
-	Includes []string
-}
-
-func (f *TimeFilters) buildQuery() (string, []interface{}, error) {
-	q := squirrel.Select("*").From("subscription_times")
-	conditions := squirrel.And{}
-
-	if len(f.SubUUIDs) > 0 {
-		conditions = append(conditions, squirrel.Eq{"subscription_uuid": f.SubUUIDs})
-	}
-
-	if len(f.Includes) > 0 {
-		orConditions := squirrel.Or{}
-		for _, targetTime := range f.Includes {
-			orConditions = append(orConditions, squirrel.And{
-				squirrel.LtOrEq{"time_start": targetTime},
-				squirrel.Gt{"time_end": targetTime},
-			})
-		}
-		conditions = append(conditions, orConditions)
-	}
-
-	if len(conditions) > 0 {
-		q = q.Where(conditions)
-	}
-
-	return q.ToSql()
-}
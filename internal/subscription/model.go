@@ -20,20 +20,31 @@ type ResponseUser struct {
 	PreferredTimes map[time.Weekday][]TimeRange
 }
 
+// UserStats is a subscriber with the number of their subscriptions
+type UserStats struct {
+	UserID        int `db:"user_id"`
+	Subscriptions int `db:"subscriptions"`
+}
+
+// ResponseSubscription is a stored subscription. PreferredTimes are only set when searching by slot,
+// they are the times of the lessons that matched the slot
 type ResponseSubscription struct {
 	UUID           uuid.UUID
 	UserID         int
+	CompanyID      *int
 	LabType        polling.LabType
 	LabNumber      int
 	LabAuditorium  *int
 	LabDomain      *polling.LabDomain
 	Weekday        *int
+	Lessons        []int
 	PreferredTimes []TimeRange
 }
 
 type DBSubscription struct {
 	UUID          uuid.UUID          `db:"uuid"`
 	UserID        int                `db:"user_id"`
+	CompanyID     *int               `db:"company_id"`
 	LabType       polling.LabType    `db:"lab_type"`
 	LabNumber     int                `db:"lab_number"`
 	LabAuditorium *int               `db:"lab_auditorium"`
@@ -41,34 +52,41 @@ type DBSubscription struct {
 	Weekday       *int               `db:"weekday"`
 }
 
-type DBSubscriptionTimes struct {
+type DBDemand struct {
+	CompanyID     *int               `db:"company_id"`
+	LabType       polling.LabType    `db:"lab_type"`
+	LabNumber     int                `db:"lab_number"`
+	LabAuditorium *int               `db:"lab_auditorium"`
+	LabDomain     *polling.LabDomain `db:"lab_domain"`
+}
+
+// DBSubscriptionLesson is a lesson of the subscription weekday, its times depend on the date and come from the schedule
+type DBSubscriptionLesson struct {
 	SubscriptionUUID uuid.UUID `db:"subscription_uuid"`
-	TimeStart        string    `db:"time_start"`
-	TimeEnd          string    `db:"time_end"`
+	Lesson           int       `db:"lesson"`
 }
 
-func toResponse(sub DBSubscription, subTimes []DBSubscriptionTimes) ResponseSubscription {
-	prefTimes := make([]TimeRange, len(subTimes))
-	for idx, t := range subTimes {
-		prefTimes[idx] = TimeRange{
-			TimeStart: t.TimeStart,
-			TimeEnd:   t.TimeEnd,
-		}
+func toResponse(sub DBSubscription, subLessons []DBSubscriptionLesson) ResponseSubscription {
+	var lessons []int
+	for _, lesson := range subLessons {
+		lessons = append(lessons, lesson.Lesson)
 	}
 	return ResponseSubscription{
-		UUID:           sub.UUID,
-		UserID:         sub.UserID,
-		LabType:        sub.LabType,
-		LabNumber:      sub.LabNumber,
-		LabAuditorium:  sub.LabAuditorium,
-		LabDomain:      sub.LabDomain,
-		Weekday:        sub.Weekday,
-		PreferredTimes: prefTimes,
+		UUID:          sub.UUID,
+		UserID:        sub.UserID,
+		CompanyID:     sub.CompanyID,
+		LabType:       sub.LabType,
+		LabNumber:     sub.LabNumber,
+		LabAuditorium: sub.LabAuditorium,
+		LabDomain:     sub.LabDomain,
+		Weekday:       sub.Weekday,
+		Lessons:       lessons,
 	}
 }
 
 type RequestSubscription struct {
 	UserID        int
+	CompanyID     *int
 	Type          polling.LabType
 	LabNumber     int
 	LabAuditorium *int
@@ -77,23 +95,27 @@ type RequestSubscription struct {
 	Lessons       []int
 }
 
-func (rs RequestSubscription) toDBModels() (DBSubscription, []DBSubscriptionTimes) {
+// toDBModels keeps the lessons only with a weekday, lessons of any weekday mean nothing
+func (rs RequestSubscription) toDBModels(subUUID uuid.UUID) (DBSubscription, []DBSubscriptionLesson) {
 	dbSub := DBSubscription{
-		UUID:          uuid.New(),
+		UUID:          subUUID,
 		UserID:        rs.UserID,
+		CompanyID:     rs.CompanyID,
 		LabType:       rs.Type,
 		LabNumber:     rs.LabNumber,
 		LabAuditorium: rs.LabAuditorium,
 		LabDomain:     rs.LabDomain,
 		Weekday:       rs.Weekday,
 	}
-	dbTimes := make([]DBSubscriptionTimes, len(rs.Lessons))
-	for idx, timeRange := range LessonsToTimeRanges(rs.Lessons...) {
-		dbTimes[idx] = DBSubscriptionTimes{
+	if rs.Weekday == nil {
+		return dbSub, nil
+	}
+	dbLessons := make([]DBSubscriptionLesson, len(rs.Lessons))
+	for idx, lesson := range rs.Lessons {
+		dbLessons[idx] = DBSubscriptionLesson{
 			SubscriptionUUID: dbSub.UUID,
-			TimeStart:        timeRange.TimeStart,
-			TimeEnd:          timeRange.TimeEnd,
+			Lesson:           lesson,
 		}
 	}
-	return dbSub, dbTimes
+	return dbSub, dbLessons
 }
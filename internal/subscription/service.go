@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
 	"github.com/Ademun/mining-lab-bot/pkg/errs"
 	"github.com/google/uuid"
 	"github.com/mattn/go-sqlite3"
@@ -14,18 +17,30 @@ import (
 
 type Service interface {
 	Subscribe(ctx context.Context, sub RequestSubscription) error
+	// Update changes the subscription in place, it keeps its UUID
+	Update(ctx context.Context, subUUID uuid.UUID, sub RequestSubscription) error
 	Unsubscribe(ctx context.Context, subUUID uuid.UUID) error
 	FindSubscriptionsByUserID(ctx context.Context, userID int) ([]ResponseSubscription, error)
 	FindUsersBySlotInfo(ctx context.Context, slot polling.Slot) ([]ResponseUser, error)
+	FindActiveUsers(ctx context.Context) ([]UserStats, error)
+	// FindDemand lists the labs with subscribers, so the poller knows what to poll often
+	FindDemand(ctx context.Context) ([]polling.LabDemand, error)
+	// SlotPreferredTimes resolves the lessons of the subscription on the dates of the slot times
+	// and reports whether any of the times falls into them
+	SlotPreferredTimes(sub RequestSubscription, slot polling.Slot) (map[time.Weekday][]TimeRange, bool)
 }
 
 type subscriptionService struct {
-	subRepo Repo
+	subRepo  Repo
+	schedule schedule.Schedule
+	location *time.Location
 }
 
-func New(repo Repo) Service {
+func New(repo Repo, sched schedule.Schedule, loc *time.Location) Service {
 	return &subscriptionService{
-		subRepo: repo,
+		subRepo:  repo,
+		schedule: sched,
+		location: loc,
 	}
 }
 
@@ -41,6 +56,61 @@ func (s *subscriptionService) Subscribe(ctx context.Context, sub RequestSubscrip
 	return nil
 }
 
+func (s *subscriptionService) Update(ctx context.Context, subUUID uuid.UUID, sub RequestSubscription) error {
+	err := s.subRepo.Update(ctx, subUUID, sub)
+	if err != nil {
+		if isDuplicateError(err) {
+			return errs.ErrSubscriptionExists
+		}
+		slog.Error("Failed to update subscription", "subUUID", subUUID, "sub", sub, "err", err)
+		return err
+	}
+	return nil
+}
+
+func (s *subscriptionService) SlotPreferredTimes(sub RequestSubscription, slot polling.Slot) (map[time.Weekday][]TimeRange, bool) {
+	times, ok := s.preferredTimes(sub.Weekday, sub.Lessons, slot.TimesTeachers)
+	if !ok || sub.Weekday == nil {
+		return nil, ok
+	}
+	return map[time.Weekday][]TimeRange{
+		time.Weekday(*sub.Weekday): times,
+	}, true
+}
+
+// preferredTimes returns the times of the lessons that contain the slot times. Lessons are resolved against
+// the schedule of each date, so schedule changes apply. Without a weekday or lessons every time of the weekday matches
+func (s *subscriptionService) preferredTimes(weekday *int, lessons []int, slotTimes map[time.Time][]string) ([]TimeRange, bool) {
+	if weekday == nil || len(slotTimes) == 0 {
+		return nil, true
+	}
+	var times []TimeRange
+	matched := false
+	for t := range slotTimes {
+		// Subscription weekdays and lessons are in campus wall clock time
+		slotTime := t.In(s.location)
+		if int(slotTime.Weekday()) != *weekday {
+			continue
+		}
+		if len(lessons) == 0 {
+			return nil, true
+		}
+		slotTimeStr := slotTime.Format("15:04")
+		for _, timeRange := range lessonsToTimeRanges(s.schedule.Lessons(slotTime), lessons...) {
+			if slotTimeStr >= timeRange.TimeStart && slotTimeStr < timeRange.TimeEnd {
+				matched = true
+				if !slices.Contains(times, timeRange) {
+					times = append(times, timeRange)
+				}
+			}
+		}
+	}
+	slices.SortFunc(times, func(a, b TimeRange) int {
+		return strings.Compare(a.TimeStart, b.TimeStart)
+	})
+	return times, matched
+}
+
 func isDuplicateError(err error) bool {
 	var queryErr *errs.ErrQueryExecution
 	if errors.As(err, &queryErr) {
@@ -61,8 +131,7 @@ func (s *subscriptionService) Unsubscribe(ctx context.Context, subUUID uuid.UUID
 }
 
 func (s *subscriptionService) FindSubscriptionsByUserID(ctx context.Context, userID int) ([]ResponseSubscription, error) {
-	subFilters, timeFilters := SubFilters{UserID: userID}, TimeFilters{}
-	subs, err := s.subRepo.Find(ctx, subFilters, timeFilters)
+	subs, err := s.subRepo.Find(ctx, SubFilters{UserID: userID})
 	if err != nil {
 		slog.Error("Failed to find subscriptions", "userID", userID, "err", err)
 	}
@@ -70,12 +139,42 @@ func (s *subscriptionService) FindSubscriptionsByUserID(ctx context.Context, use
 	return subs, err
 }
 
+// FindActiveUsers returns users having at least one subscription, the most subscribed first
+func (s *subscriptionService) FindActiveUsers(ctx context.Context) ([]UserStats, error) {
+	stats, err := s.subRepo.FindUserStats(ctx)
+	if err != nil {
+		slog.Error("Failed to find user stats", "err", err)
+	}
+	return stats, err
+}
+
+func (s *subscriptionService) FindDemand(ctx context.Context) ([]polling.LabDemand, error) {
+	rows, err := s.subRepo.FindDemand(ctx)
+	if err != nil {
+		slog.Error("Failed to find demand", "err", err)
+		return nil, err
+	}
+	demand := make([]polling.LabDemand, len(rows))
+	for idx, row := range rows {
+		demand[idx] = polling.LabDemand{
+			CompanyID:  row.CompanyID,
+			Type:       row.LabType,
+			Number:     row.LabNumber,
+			Auditorium: row.LabAuditorium,
+			Domain:     row.LabDomain,
+		}
+	}
+	return demand, nil
+}
+
 func (s *subscriptionService) FindUsersBySlotInfo(ctx context.Context, slot polling.Slot) ([]ResponseUser, error) {
 	weekdays := make([]int, 0, len(slot.TimesTeachers))
+	// Subscription weekdays and times are in campus wall clock time
 	for t := range slot.TimesTeachers {
-		weekdays = append(weekdays, int(t.Weekday()))
+		weekdays = append(weekdays, int(t.In(s.location).Weekday()))
 	}
 	subFilters := SubFilters{
+		CompanyID: slot.CompanyID,
 		Type:      &slot.Type,
 		LabNumber: slot.Number,
 		Weekdays:  weekdays,
@@ -86,37 +185,51 @@ func (s *subscriptionService) FindUsersBySlotInfo(ctx context.Context, slot poll
 	if slot.Type == polling.LabTypeDefence {
 		subFilters.LabDomain = &slot.Domain
 	}
-	times := make([]string, 0, len(slot.TimesTeachers))
-	for t := range slot.TimesTeachers {
-		times = append(times, t.Format("15:04"))
-	}
-	timeFilters := TimeFilters{
-		Includes: times,
-	}
-	subs, err := s.subRepo.Find(ctx, subFilters, timeFilters)
+	subs, err := s.subRepo.Find(ctx, subFilters)
 	if err != nil {
 		slog.Error("Failed to find subscriptions", "slot", slot, "err", err)
 	}
 
 	userIDSubs := make(map[int][]ResponseSubscription)
 	for _, sub := range subs {
+		times, ok := s.preferredTimes(sub.Weekday, sub.Lessons, slot.TimesTeachers)
+		if !ok {
+			continue
+		}
+		sub.PreferredTimes = times
 		userIDSubs[sub.UserID] = append(userIDSubs[sub.UserID], sub)
 	}
 
 	users := make([]ResponseUser, 0, len(userIDSubs))
 	for userID, userSubs := range userIDSubs {
-		prefTimes := make(map[time.Weekday][]TimeRange)
-		for _, sub := range userSubs {
-			if sub.Weekday == nil {
-				continue
-			}
-			prefTimes[time.Weekday(*sub.Weekday)] = append(prefTimes[time.Weekday(*sub.Weekday)], sub.PreferredTimes...)
-		}
 		users = append(users, ResponseUser{
 			UserID:         userID,
-			PreferredTimes: prefTimes,
+			PreferredTimes: mergePreferredTimes(userSubs),
 		})
 	}
 
 	return users, err
 }
+
+// mergePreferredTimes joins the times of the subscriptions of one user. Subscriptions without a weekday or
+// without times match every time, so they widen the result instead of being left out
+func mergePreferredTimes(subs []ResponseSubscription) map[time.Weekday][]TimeRange {
+	prefTimes := make(map[time.Weekday][]TimeRange)
+	anyTime := make(map[time.Weekday]bool)
+	for _, sub := range subs {
+		if sub.Weekday == nil {
+			return nil
+		}
+		weekday := time.Weekday(*sub.Weekday)
+		if anyTime[weekday] {
+			continue
+		}
+		if len(sub.PreferredTimes) == 0 {
+			anyTime[weekday] = true
+			prefTimes[weekday] = nil
+			continue
+		}
+		prefTimes[weekday] = append(prefTimes[weekday], sub.PreferredTimes...)
+	}
+	return prefTimes
+}
@@ -0,0 +1,189 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePreferredTimes(t *testing.T) {
+	monday, tuesday := int(time.Monday), int(time.Tuesday)
+	first := TimeRange{TimeStart: "08:50", TimeEnd: "10:20"}
+	second := TimeRange{TimeStart: "10:35", TimeEnd: "12:05"}
+
+	type testCase struct {
+		subs     []ResponseSubscription
+		expected map[time.Weekday][]TimeRange
+	}
+
+	tests := []testCase{
+		{
+			subs: []ResponseSubscription{
+				{Weekday: &monday, PreferredTimes: []TimeRange{first}},
+				{Weekday: &monday, PreferredTimes: []TimeRange{second}},
+				{Weekday: &tuesday, PreferredTimes: []TimeRange{first}},
+			},
+			expected: map[time.Weekday][]TimeRange{time.Monday: {first, second}, time.Tuesday: {first}},
+		},
+		{
+			subs: []ResponseSubscription{
+				{Weekday: &monday, PreferredTimes: []TimeRange{first}},
+				{},
+			},
+			expected: nil,
+		},
+		{
+			subs: []ResponseSubscription{
+				{Weekday: &monday, PreferredTimes: []TimeRange{first}},
+				{Weekday: &monday},
+				{Weekday: &monday, PreferredTimes: []TimeRange{second}},
+			},
+			expected: map[time.Weekday][]TimeRange{time.Monday: nil},
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_merge_preferred_times_%d", i), func(t *testing.T) {
+			assert.Equal(t, tCase.expected, mergePreferredTimes(tCase.subs))
+		})
+	}
+}
+
+func testSchedule(t *testing.T, loc *time.Location) schedule.Schedule {
+	sched, err := schedule.New(&config.ScheduleConfig{
+		Lessons: []config.LessonConfig{
+			{Start: "08:50", End: "10:20"},
+			{Start: "10:35", End: "12:05"},
+			{Start: "12:35", End: "14:05"},
+		},
+		Changes: []config.ScheduleChangeConfig{
+			{Name: "Short day", From: "2025-12-22", To: "2025-12-22", Lessons: []config.LessonConfig{
+				{Start: "09:00", End: "09:45"},
+				{Start: "09:55", End: "10:40"},
+			}},
+		},
+	}, loc)
+	require.NoError(t, err)
+	return sched
+}
+
+func TestSlotPreferredTimes(t *testing.T) {
+	campus := testutil.Campus(t)
+	service := New(nil, testSchedule(t, campus), campus)
+	monday := int(time.Monday)
+	regular := TimeRange{TimeStart: "10:35", TimeEnd: "12:05"}
+	changed := TimeRange{TimeStart: "09:55", TimeEnd: "10:40"}
+
+	type testCase struct {
+		sub      RequestSubscription
+		time     time.Time
+		expected map[time.Weekday][]TimeRange
+		ok       bool
+	}
+
+	tests := []testCase{
+		{
+			sub:      RequestSubscription{Weekday: &monday, Lessons: []int{2}},
+			time:     time.Date(2025, 12, 15, 10, 35, 0, 0, campus),
+			expected: map[time.Weekday][]TimeRange{time.Monday: {regular}},
+			ok:       true,
+		},
+		// Times are compared in campus time, whatever the location of the slot time
+		{
+			sub:      RequestSubscription{Weekday: &monday, Lessons: []int{2}},
+			time:     time.Date(2025, 12, 15, 7, 35, 0, 0, time.UTC),
+			expected: map[time.Weekday][]TimeRange{time.Monday: {regular}},
+			ok:       true,
+		},
+		// Schedule changes move the lessons of the date
+		{
+			sub:      RequestSubscription{Weekday: &monday, Lessons: []int{2}},
+			time:     time.Date(2025, 12, 22, 11, 0, 0, 0, campus),
+			expected: nil,
+			ok:       false,
+		},
+		{
+			sub:      RequestSubscription{Weekday: &monday, Lessons: []int{2}},
+			time:     time.Date(2025, 12, 22, 10, 0, 0, 0, campus),
+			expected: map[time.Weekday][]TimeRange{time.Monday: {changed}},
+			ok:       true,
+		},
+		{
+			sub:      RequestSubscription{Weekday: &monday, Lessons: []int{2}},
+			time:     time.Date(2025, 12, 16, 10, 35, 0, 0, campus),
+			expected: nil,
+			ok:       false,
+		},
+		{
+			sub:      RequestSubscription{Weekday: &monday},
+			time:     time.Date(2025, 12, 15, 15, 0, 0, 0, campus),
+			expected: map[time.Weekday][]TimeRange{time.Monday: nil},
+			ok:       true,
+		},
+		{
+			sub:      RequestSubscription{},
+			time:     time.Date(2025, 12, 16, 15, 0, 0, 0, campus),
+			expected: nil,
+			ok:       true,
+		},
+	}
+
+	for i, tCase := range tests {
+		t.Run(fmt.Sprintf("test_slot_preferred_times_%d", i), func(t *testing.T) {
+			slot := polling.Slot{TimesTeachers: map[time.Time][]string{tCase.time: nil}}
+			prefTimes, ok := service.SlotPreferredTimes(tCase.sub, slot)
+			assert.Equal(t, tCase.ok, ok)
+			assert.Equal(t, tCase.expected, prefTimes)
+		})
+	}
+}
+
+func TestFindUsersBySlotInfo(t *testing.T) {
+	ctx := context.Background()
+	campus := testutil.Campus(t)
+	service := New(NewRepo(testutil.DB(t)), testSchedule(t, campus), campus)
+
+	auditorium, monday := 233, int(time.Monday)
+	for userID, lesson := range map[int]int{1: 1, 2: 2} {
+		require.NoError(t, service.Subscribe(ctx, RequestSubscription{
+			UserID:        userID,
+			Type:          polling.LabTypePerformance,
+			LabNumber:     7,
+			LabAuditorium: &auditorium,
+			Weekday:       &monday,
+			Lessons:       []int{lesson},
+		}))
+	}
+
+	// 10:00 is the first lesson on a regular Monday and the second one on the short day
+	slot := polling.Slot{
+		CompanyID:  550001,
+		Type:       polling.LabTypePerformance,
+		Number:     7,
+		Auditorium: auditorium,
+		TimesTeachers: map[time.Time][]string{
+			time.Date(2025, 12, 22, 10, 0, 0, 0, campus): nil,
+		},
+	}
+	users, err := service.FindUsersBySlotInfo(ctx, slot)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, 2, users[0].UserID)
+	assert.Equal(t, map[time.Weekday][]TimeRange{time.Monday: {{TimeStart: "09:55", TimeEnd: "10:40"}}}, users[0].PreferredTimes)
+
+	slot.TimesTeachers = map[time.Time][]string{
+		time.Date(2025, 12, 15, 10, 0, 0, 0, campus): nil,
+	}
+	users, err = service.FindUsersBySlotInfo(ctx, slot)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, 1, users[0].UserID)
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 
 	"github.com/Ademun/mining-lab-bot/pkg/errs"
+	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type Repo interface {
 	Create(ctx context.Context, subReq RequestSubscription) error
+	// Update replaces the subscription and its lessons, keeping the UUID
+	Update(ctx context.Context, subUUID uuid.UUID, subReq RequestSubscription) error
 	Delete(ctx context.Context, uuid uuid.UUID) (bool, error)
-	Find(ctx context.Context, subFilters SubFilters, timeFilters TimeFilters) ([]ResponseSubscription, error)
+	Find(ctx context.Context, subFilters SubFilters) ([]ResponseSubscription, error)
+	FindUserStats(ctx context.Context) ([]UserStats, error)
+	// FindDemand returns every distinct lab that has subscriptions
+	FindDemand(ctx context.Context) ([]DBDemand, error)
 }
 
 type subscriptionRepo struct {
@@ -28,30 +34,81 @@ func (s *subscriptionRepo) Create(ctx context.Context, subReq RequestSubscriptio
 		return errs.ErrBeginTransaction
 	}
 	defer tx.Rollback()
-	sub, subTimes := subReq.toDBModels()
+	sub, subLessons := subReq.toDBModels(uuid.New())
 
 	subInsert := `
 insert into subscriptions 
-(uuid, user_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday) 
+(uuid, user_id, company_id, lab_type, lab_number, lab_auditorium, lab_domain, weekday) 
 values 
-(:uuid, :user_id, :lab_type, :lab_number, :lab_auditorium, :lab_domain, :weekday)`
+(:uuid, :user_id, :company_id, :lab_type, :lab_number, :lab_auditorium, :lab_domain, :weekday)`
 	_, err = tx.NamedExecContext(ctx, subInsert, sub)
 	if err != nil {
 		return &errs.ErrQueryExecution{Operation: "Create", Query: subInsert, Err: err}
 	}
 
-	if len(subTimes) == 0 {
+	if len(subLessons) == 0 {
 		return tx.Commit()
 	}
 
-	timesInsert := `
-insert into subscription_times 
-(subscription_uuid, time_start, time_end) 
+	lessonsInsert := `
+insert into subscription_lessons 
+(subscription_uuid, lesson) 
 values 
-(:subscription_uuid, :time_start, :time_end)
+(:subscription_uuid, :lesson)
 `
-	if _, err = tx.NamedExecContext(ctx, timesInsert, subTimes); err != nil {
-		return &errs.ErrQueryExecution{Operation: "Create", Query: timesInsert, Err: err}
+	if _, err = tx.NamedExecContext(ctx, lessonsInsert, subLessons); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Create", Query: lessonsInsert, Err: err}
+	}
+
+	return tx.Commit()
+}
+
+func (s *subscriptionRepo) Update(ctx context.Context, subUUID uuid.UUID, subReq RequestSubscription) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errs.ErrBeginTransaction
+	}
+	defer tx.Rollback()
+	sub, subLessons := subReq.toDBModels(subUUID)
+
+	subUpdate := `
+update subscriptions
+set company_id     = :company_id,
+    lab_type       = :lab_type,
+    lab_number     = :lab_number,
+    lab_auditorium = :lab_auditorium,
+    lab_domain     = :lab_domain,
+    weekday        = :weekday
+where uuid = :uuid and user_id = :user_id`
+	res, err := tx.NamedExecContext(ctx, subUpdate, sub)
+	if err != nil {
+		return &errs.ErrQueryExecution{Operation: "Update", Query: subUpdate, Err: err}
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return &errs.ErrQueryExecution{Operation: "Update", Query: subUpdate, Err: err}
+	}
+	if affected == 0 {
+		return errs.ErrSubscriptionNotFound
+	}
+
+	lessonsDelete := `delete from subscription_lessons where subscription_uuid = ?`
+	if _, err = tx.ExecContext(ctx, lessonsDelete, subUUID.String()); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Update", Query: lessonsDelete, Err: err}
+	}
+
+	if len(subLessons) == 0 {
+		return tx.Commit()
+	}
+
+	lessonsInsert := `
+insert into subscription_lessons 
+(subscription_uuid, lesson) 
+values 
+(:subscription_uuid, :lesson)
+`
+	if _, err = tx.NamedExecContext(ctx, lessonsInsert, subLessons); err != nil {
+		return &errs.ErrQueryExecution{Operation: "Update", Query: lessonsInsert, Err: err}
 	}
 
 	return tx.Commit()
@@ -73,7 +130,7 @@ func (s *subscriptionRepo) Delete(ctx context.Context, uuid uuid.UUID) (bool, er
 	return true, nil
 }
 
-func (s *subscriptionRepo) Find(ctx context.Context, subFilters SubFilters, timeFilters TimeFilters) ([]ResponseSubscription, error) {
+func (s *subscriptionRepo) Find(ctx context.Context, subFilters SubFilters) ([]ResponseSubscription, error) {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, errs.ErrBeginTransaction
@@ -88,7 +145,7 @@ func (s *subscriptionRepo) Find(ctx context.Context, subFilters SubFilters, time
 		return nil, &errs.ErrQueryExecution{Operation: "Find", Query: query, Err: err}
 	}
 
-	response, err := s.convertDBSubsToResponse(ctx, tx, subs, timeFilters)
+	response, err := s.convertDBSubsToResponse(ctx, tx, subs)
 	if err != nil {
 		return nil, &errs.ErrQueryExecution{Operation: "Find", Query: query, Err: err}
 	}
@@ -96,38 +153,68 @@ func (s *subscriptionRepo) Find(ctx context.Context, subFilters SubFilters, time
 	return response, tx.Commit()
 }
 
-func (s *subscriptionRepo) convertDBSubsToResponse(ctx context.Context, tx *sqlx.Tx, subs []DBSubscription, timeFilters TimeFilters) ([]ResponseSubscription, error) {
+func (s *subscriptionRepo) FindUserStats(ctx context.Context) ([]UserStats, error) {
+	query := `
+select user_id, count(*) as subscriptions
+from subscriptions
+group by user_id
+order by subscriptions desc, user_id`
+	var stats []UserStats
+	if err := s.db.SelectContext(ctx, &stats, query); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindUserStats", Query: query, Err: err}
+	}
+	return stats, nil
+}
+
+func (s *subscriptionRepo) FindDemand(ctx context.Context) ([]DBDemand, error) {
+	query := `
+select distinct company_id, lab_type, lab_number, lab_auditorium, lab_domain
+from subscriptions`
+	var demand []DBDemand
+	if err := s.db.SelectContext(ctx, &demand, query); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "FindDemand", Query: query, Err: err}
+	}
+	return demand, nil
+}
+
+func (s *subscriptionRepo) convertDBSubsToResponse(ctx context.Context, tx *sqlx.Tx, subs []DBSubscription) ([]ResponseSubscription, error) {
+	if len(subs) == 0 {
+		return nil, nil
+	}
 	subUUIDs := make([]uuid.UUID, len(subs))
 	for idx, sub := range subs {
 		subUUIDs[idx] = sub.UUID
 	}
 
-	timeFilters.SubUUIDs = subUUIDs
-	prefTimes, err := s.findPreferredTimes(ctx, tx, timeFilters)
+	lessons, err := s.findLessons(ctx, tx, subUUIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	subTimes := make(map[uuid.UUID][]DBSubscriptionTimes, len(subs))
-	for _, time := range prefTimes {
-		subTimes[time.SubscriptionUUID] = append(subTimes[time.SubscriptionUUID], time)
+	subLessons := make(map[uuid.UUID][]DBSubscriptionLesson, len(subs))
+	for _, lesson := range lessons {
+		subLessons[lesson.SubscriptionUUID] = append(subLessons[lesson.SubscriptionUUID], lesson)
 	}
 
 	response := make([]ResponseSubscription, len(subs))
 	for idx, sub := range subs {
-		response[idx] = toResponse(sub, subTimes[sub.UUID])
+		response[idx] = toResponse(sub, subLessons[sub.UUID])
 	}
 	return response, nil
 }
 
-func (s *subscriptionRepo) findPreferredTimes(ctx context.Context, tx *sqlx.Tx, timeFilters TimeFilters) ([]DBSubscriptionTimes, error) {
-	query, args, err := timeFilters.buildQuery()
+func (s *subscriptionRepo) findLessons(ctx context.Context, tx *sqlx.Tx, subUUIDs []uuid.UUID) ([]DBSubscriptionLesson, error) {
+	query, args, err := squirrel.Select("*").
+		From("subscription_lessons").
+		Where(squirrel.Eq{"subscription_uuid": subUUIDs}).
+		OrderBy("lesson").
+		ToSql()
 	if err != nil {
-		return nil, &errs.ErrQueryCreation{Operation: "findPreferredTimes", Query: query, Err: err}
+		return nil, &errs.ErrQueryCreation{Operation: "findLessons", Query: query, Err: err}
 	}
-	var times []DBSubscriptionTimes
-	if err = tx.SelectContext(ctx, &times, query, args...); err != nil {
-		return nil, &errs.ErrQueryExecution{Operation: "findPreferredTimes", Query: query, Err: err}
+	var lessons []DBSubscriptionLesson
+	if err = tx.SelectContext(ctx, &lessons, query, args...); err != nil {
+		return nil, &errs.ErrQueryExecution{Operation: "findLessons", Query: query, Err: err}
 	}
-	return times, nil
+	return lessons, nil
 }
@@ -0,0 +1,51 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/testutil"
+	"github.com/Ademun/mining-lab-bot/pkg/errs"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.DB(t)
+
+	repo := NewRepo(db)
+	auditorium, weekday := 233, 1
+	first := RequestSubscription{UserID: 1, Type: polling.LabTypePerformance, LabNumber: 7, LabAuditorium: &auditorium, Weekday: &weekday, Lessons: []int{1}}
+	second := RequestSubscription{UserID: 1, Type: polling.LabTypePerformance, LabNumber: 8, LabAuditorium: &auditorium}
+	require.NoError(t, repo.Create(ctx, first))
+	require.NoError(t, repo.Create(ctx, second))
+
+	subs, err := repo.Find(ctx, SubFilters{UserID: 1, LabNumber: 7})
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	subUUID := subs[0].UUID
+
+	domain := polling.LabDomain("mechanics")
+	edited := RequestSubscription{UserID: 1, Type: polling.LabTypeDefence, LabNumber: 5, LabDomain: &domain, Weekday: &weekday, Lessons: []int{2, 3}}
+	require.NoError(t, repo.Update(ctx, subUUID, edited))
+
+	subs, err = repo.Find(ctx, SubFilters{UserID: 1, LabNumber: 5})
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, subUUID, subs[0].UUID)
+	assert.Equal(t, polling.LabTypeDefence, subs[0].LabType)
+	assert.Nil(t, subs[0].LabAuditorium)
+	assert.Equal(t, []int{2, 3}, subs[0].Lessons)
+
+	// Edits run into the same unique constraint as new subscriptions
+	err = repo.Update(ctx, subUUID, second)
+	assert.True(t, isDuplicateError(err))
+
+	// Subscriptions of other users can't be edited
+	edited.UserID = 2
+	assert.ErrorIs(t, repo.Update(ctx, subUUID, edited), errs.ErrSubscriptionNotFound)
+	assert.ErrorIs(t, repo.Update(ctx, uuid.New(), first), errs.ErrSubscriptionNotFound)
+}
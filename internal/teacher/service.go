@@ -5,9 +5,8 @@ import (
 	"log/slog"
 	"time"
 
-	"github.com/Ademun/mining-lab-bot/pkg/config"
+	"github.com/Ademun/mining-lab-bot/internal/calendar"
 	"github.com/Ademun/mining-lab-bot/pkg/logger"
-	"github.com/robfig/cron/v3"
 )
 
 type Service interface {
@@ -15,52 +14,31 @@ type Service interface {
 }
 
 type teacherService struct {
-	teacherRepo   Repo
-	options       config.TeacherConfig
-	weekNumber    int
-	cronScheduler *cron.Cron
+	teacherRepo Repo
+	calendar    calendar.Calendar
+	location    *time.Location
 }
 
-func New(repo Repo, opts *config.TeacherConfig) Service {
+func New(repo Repo, cal calendar.Calendar, loc *time.Location) Service {
 	return &teacherService{
 		teacherRepo: repo,
-		options:     *opts,
-		weekNumber:  opts.StartingWeek,
+		calendar:    cal,
+		location:    loc,
 	}
 }
 
-func (s *teacherService) Start() error {
-	slog.Info("Starting", "service", logger.ServiceTeacher)
-	c := cron.New(cron.WithLocation(time.Local))
-	_, err := c.AddFunc("0 0 * 0", func() {
-		slog.Info("Updating week number", "service", logger.ServiceTeacher)
-		if s.weekNumber == 1 {
-			s.weekNumber = 2
-			return
-		}
-		s.weekNumber = 1
-	})
-	if err != nil {
-		slog.Info("Cron error", "error", err, "service", logger.ServiceTeacher)
-	}
-	c.Start()
-	s.cronScheduler = c
-	slog.Info("Started", "service", logger.ServiceTeacher)
-	return nil
-}
-
-func (s *teacherService) Stop(ctx context.Context) {
-	<-ctx.Done()
-	s.cronScheduler.Stop()
-	slog.Info("Stopped", "service", logger.ServiceTeacher)
-}
-
+// FindTeachersForTime returns nobody on non-teaching days, since the regular schedule doesn't apply then
 func (s *teacherService) FindTeachersForTime(ctx context.Context, targetTime time.Time, auditorium int) []Teacher {
+	if !s.calendar.IsTeachingDay(targetTime) {
+		return nil
+	}
+	// The teachers' schedule is stored in campus wall clock time
+	local := targetTime.In(s.location)
 	filter := Filter{
-		WeekNumber: calculateWeekNumber(s.weekNumber, time.Now(), targetTime),
-		Weekday:    targetTime.Weekday(),
+		WeekNumber: s.calendar.WeekNumber(local),
+		Weekday:    local.Weekday(),
 		Auditorium: auditorium,
-		TargetTime: targetTime,
+		TargetTime: local,
 	}
 	teachers, err := s.teacherRepo.FindBySchedule(ctx, filter)
 	if err != nil {
@@ -69,31 +47,3 @@ func (s *teacherService) FindTeachersForTime(ctx context.Context, targetTime tim
 
 	return teachers
 }
-
-func calculateWeekNumber(currentWeek int, currentTime, targetTime time.Time) int {
-	currentWeekStart := getWeekMonday(currentTime)
-	targetWeekStart := getWeekMonday(targetTime)
-
-	weekDiff := int(targetWeekStart.Sub(currentWeekStart).Hours() / (24 * 7))
-
-	if weekDiff%2 == 0 {
-		return currentWeek
-	}
-
-	if currentWeek == 1 {
-		return 2
-	}
-	return 1
-}
-
-func getWeekMonday(t time.Time) time.Time {
-	weekday := int(t.Weekday())
-	if weekday == 0 {
-		weekday = 7
-	}
-
-	daysToSubtract := weekday - 1
-	monday := t.AddDate(0, 0, -daysToSubtract)
-
-	return time.Date(monday.Year(), monday.Month(), monday.Day(), 0, 0, 0, 0, monday.Location())
-}
@@ -0,0 +1,22 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Ademun/mining-lab-bot/internal/migrations"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+// DB opens an in-memory SQLite database with every migration applied.
+// A single connection keeps the database alive, each new one would get an empty database
+func DB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", ":memory:?_foreign_keys=on")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, migrations.Up(context.Background(), db))
+	return db
+}
@@ -0,0 +1,30 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// CampusTimezone is the campus time zone used across tests
+const CampusTimezone = "Europe/Moscow"
+
+// Campus loads the campus location
+func Campus(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation(CampusTimezone)
+	require.NoError(t, err)
+	return loc
+}
+
+// SetHostTimezone pretends the host runs in another time zone until the test ends.
+// Tests using it must not run in parallel, since time.Local is global
+func SetHostTimezone(t *testing.T, name string) {
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	prev := time.Local
+	time.Local = loc
+	t.Cleanup(func() {
+		time.Local = prev
+	})
+}
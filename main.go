@@ -7,11 +7,20 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/Ademun/mining-lab-bot/cmd"
+	"github.com/Ademun/mining-lab-bot/internal/alerting"
+	"github.com/Ademun/mining-lab-bot/internal/calendar"
+	"github.com/Ademun/mining-lab-bot/internal/catalog"
+	"github.com/Ademun/mining-lab-bot/internal/health"
+	"github.com/Ademun/mining-lab-bot/internal/history"
 	"github.com/Ademun/mining-lab-bot/internal/metrics"
+	"github.com/Ademun/mining-lab-bot/internal/migrations"
 	"github.com/Ademun/mining-lab-bot/internal/notification"
 	"github.com/Ademun/mining-lab-bot/internal/polling"
+	"github.com/Ademun/mining-lab-bot/internal/quarantine"
+	"github.com/Ademun/mining-lab-bot/internal/schedule"
 	"github.com/Ademun/mining-lab-bot/internal/subscription"
 	"github.com/Ademun/mining-lab-bot/internal/teacher"
 	"github.com/Ademun/mining-lab-bot/pkg/config"
@@ -27,13 +36,26 @@ func main() {
 
 	logger.Init(slog.LevelInfo)
 
-	cfg, err := config.Load("config.yaml")
+	db, err := sqlx.Open("sqlite3", "./dev.db?_foreign_keys=on")
 	if err != nil {
 		slog.Error("Fatal error", "error", err)
 		return
 	}
 
-	db, err := sqlx.Open("sqlite3", "./dev.db")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, db, os.Args[2:]); err != nil {
+			slog.Error("Fatal error", "error", err)
+		}
+		db.Close()
+		return
+	}
+
+	if err := migrations.Up(ctx, db); err != nil {
+		slog.Error("Fatal error", "error", err)
+		return
+	}
+
+	cfg, err := config.Load("config.yaml")
 	if err != nil {
 		slog.Error("Fatal error", "error", err)
 		return
@@ -45,19 +67,65 @@ func main() {
 		DB:       cfg.GlobalConfig.RedisDB,
 	})
 
-	metrics.Listen(cfg.GlobalConfig.MetricsEndpoint)
+	checker := health.NewChecker(cfg.GlobalConfig.PollStallTimeout)
+	checker.Register("sqlite", db.PingContext)
+	checker.Register("redis", func(ctx context.Context) error {
+		return cache.Ping(ctx).Err()
+	})
+	metrics.Listen(cfg.GlobalConfig.MetricsEndpoint, checker)
+
+	campus, err := cfg.GlobalConfig.Location()
+	if err != nil {
+		slog.Error("Fatal error", "error", err)
+		return
+	}
+
+	academicCalendar, err := calendar.New(&cfg.CalendarConfig, campus)
+	if err != nil {
+		slog.Error("Fatal error", "error", err)
+		return
+	}
+	if academicCalendar.DayKind(time.Now()) == calendar.DayOutOfSemester {
+		slog.Warn("Today is outside of the configured semester, update the calendar dates",
+			"semester_start", cfg.CalendarConfig.SemesterStart,
+			"semester_end", cfg.CalendarConfig.SemesterEnd)
+	}
 
 	subscriptionRepo := subscription.NewRepo(db)
 
-	subscriptionService := subscription.New(subscriptionRepo)
+	bellSchedule, err := schedule.New(&cfg.ScheduleConfig, campus)
+	if err != nil {
+		slog.Error("Fatal error", "error", err)
+		return
+	}
+
+	subscriptionService := subscription.New(subscriptionRepo, bellSchedule, campus)
+
+	parsingRules, err := polling.NewRules(&cfg.PollingConfig.Parsing)
+	if err != nil {
+		slog.Error("Fatal error", "error", err)
+		return
+	}
 
-	bot, err := cmd.NewBot(subscriptionService, &cfg.TelegramConfig, cache)
+	bot, err := cmd.NewBot(subscriptionService, bellSchedule, parsingRules, campus, cfg.PollingConfig.Dikidi.Companies, &cfg.TelegramConfig, cache)
 	if err != nil {
 		slog.Error("Fatal error", "error", err)
 		return
 	}
+	checker.Register("telegram", bot.Ping)
 
-	notificationService := notification.New(subscriptionService, bot, cache, &cfg.NotificationConfig)
+	outboxRepo := notification.NewOutboxRepo(db)
+	preferenceRepo := notification.NewPreferenceRepo(db)
+	heldRepo := notification.NewHeldRepo(db)
+
+	historyRepo := history.NewRepo(db)
+	historyService := history.New(historyRepo, campus, &cfg.HistoryConfig)
+	if err := historyService.Start(ctx); err != nil {
+		slog.Error("Fatal error", "error", err)
+		return
+	}
+
+	notificationService := notification.New(subscriptionService, bot, academicCalendar, campus, cache, outboxRepo, preferenceRepo, heldRepo, historyService, &cfg.NotificationConfig)
 
 	if err := notificationService.Start(ctx); err != nil {
 		slog.Error("Fatal error", "error", err)
@@ -65,12 +133,30 @@ func main() {
 	}
 
 	bot.SetNotificationService(notificationService)
+
+	quarantineRepo := quarantine.NewRepo(db)
+	quarantineService := quarantine.New(quarantineRepo)
+	bot.SetQuarantineService(quarantineService)
+	bot.SetHistoryService(historyService)
+
+	catalogRepo := catalog.NewRepo(db)
+	catalogService := catalog.New(catalogRepo)
+	bot.SetCatalogService(catalogService)
 	bot.Start(ctx)
 
 	teacherRepo := teacher.NewRepo(db)
-	teacherService := teacher.New(teacherRepo, &cfg.TeacherConfig)
+	teacherService := teacher.New(teacherRepo, academicCalendar, campus)
+
+	sources := make([]polling.SlotSource, 0, len(cfg.PollingConfig.Dikidi.Companies))
+	for _, company := range cfg.PollingConfig.Dikidi.Companies {
+		sources = append(sources, polling.NewDikidiSource(teacherService, quarantineService, catalogService, parsingRules, company, campus, &cfg.PollingConfig))
+	}
+
+	alertingService := alerting.New(bot, &cfg.AlertingConfig)
 
-	pollingService := polling.New(notificationService, teacherService, &cfg.PollingConfig)
+	pollingService := polling.New(notificationService, alertingService, subscriptionService, sources, academicCalendar, &cfg.PollingConfig)
+	bot.SetPollingService(pollingService)
+	checker.SetPoller(pollingService)
 	if err := pollingService.Start(ctx); err != nil {
 		slog.Error("Fatal error", "error", err)
 		return
@@ -83,6 +169,7 @@ func main() {
 
 	pollingService.Stop(ctx)
 	notificationService.Stop(ctx)
+	historyService.Stop(ctx)
 
 	if err := db.Close(); err != nil {
 		slog.Error("Fatal error", "error", err)
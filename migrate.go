@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Ademun/mining-lab-bot/internal/migrations"
+	"github.com/jmoiron/sqlx"
+)
+
+const migrateUsage = "usage: mining-bot migrate [status | up | down [steps]]"
+
+// runMigrate handles the "migrate" CLI mode. Without a subcommand it reports status
+func runMigrate(ctx context.Context, db *sqlx.DB, args []string) error {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "status":
+		return printMigrationStatus(ctx, db)
+	case "up":
+		if err := migrations.Up(ctx, db); err != nil {
+			return err
+		}
+		return printMigrationStatus(ctx, db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		if err := migrations.Down(ctx, db, steps); err != nil {
+			return err
+		}
+		return printMigrationStatus(ctx, db)
+	}
+	return fmt.Errorf("unknown migrate command: %s. %s", command, migrateUsage)
+}
+
+func printMigrationStatus(ctx context.Context, db *sqlx.DB) error {
+	statuses, err := migrations.Status(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
@@ -16,7 +16,10 @@ type Config struct {
 	PollingConfig      PollingConfig      `yaml:"polling"`
 	NotificationConfig NotificationConfig `yaml:"notification"`
 	TelegramConfig     TelegramConfig     `yaml:"telegram"`
-	TeacherConfig      TeacherConfig      `yaml:"teacher"`
+	CalendarConfig     CalendarConfig     `yaml:"calendar"`
+	ScheduleConfig     ScheduleConfig     `yaml:"schedule"`
+	AlertingConfig     AlertingConfig     `yaml:"alerting"`
+	HistoryConfig      HistoryConfig      `yaml:"history"`
 }
 
 type GlobalConfig struct {
@@ -24,20 +27,74 @@ type GlobalConfig struct {
 	RedisPass       string `yaml:"redis_pass"`
 	RedisDB         int    `yaml:"redis_db"`
 	MetricsEndpoint string `yaml:"metrics_endpoint"`
+	// Timezone is the IANA time zone of the campus. Dikidi times, lesson times and the academic calendar are all in it
+	Timezone string `yaml:"timezone"`
+	// PollStallTimeout is how long the poller may go without a successful poll before /healthz fails.
+	// It must be longer than the slowest poll rate
+	PollStallTimeout time.Duration `yaml:"poll_stall_timeout"`
+}
+
+// Location loads the campus time zone, so nothing depends on the TZ setting of the host
+func (c *GlobalConfig) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return nil, fmt.Errorf("campus time zone is not set")
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid campus time zone: %w", err)
+	}
+	return loc, nil
 }
 
 type PollingConfig struct {
-	ServiceURL          string        `yaml:"service_url"`
+	Dikidi              DikidiConfig  `yaml:"dikidi"`
 	Mode                PollingMode   `yaml:"mode"`
 	ServiceIDUpdateRate time.Duration `yaml:"service_id_update_rate"`
 	NormalPollRate      time.Duration `yaml:"normal_poll_rate"`
 	AggressivePollRate  time.Duration `yaml:"aggressive_poll_rate"`
+	// OffDayPollRate is used when no teaching days are ahead, e.g. during holidays. Zero disables it
+	OffDayPollRate      time.Duration `yaml:"off_day_poll_rate"`
 	NormalFetchRate     time.Duration `yaml:"normal_fetch_rate"`
 	AggressiveFetchRate time.Duration `yaml:"aggressive_fetch_rate"`
 	MinFetchRate        time.Duration `yaml:"min_fetch_rate"`
 	MaxFetchRate        time.Duration `yaml:"max_fetch_rate"`
 	BackoffFactor       float64       `yaml:"backoff_factor"`
 	RecoveryFactor      float64       `yaml:"recovery_factor"`
+	// BackgroundPollRate is how often services of labs without subscribers are polled, zero polls them on every tick
+	BackgroundPollRate time.Duration `yaml:"background_poll_rate"`
+	// DateConcurrency is how many dates of a service are fetched at once, all of them share the fetch rate
+	DateConcurrency int `yaml:"date_concurrency"`
+	// ContentHashTTL is how long a response that didn't change is skipped without parsing, zero disables skipping.
+	// It must stay below the notification cache TTL, so open slots are re-sent before the cache forgets them
+	ContentHashTTL time.Duration `yaml:"content_hash_ttl"`
+	Parsing        ParsingConfig `yaml:"parsing"`
+}
+
+// ParsingConfig holds the rules for reading lab slots out of master and service names.
+// Patterns are tried in order against the master name, then the service name, and must capture the value in their only group
+type ParsingConfig struct {
+	NumberPatterns     []string `yaml:"number_patterns"`
+	AuditoriumPatterns []string `yaml:"auditorium_patterns"`
+	// OrderPatterns are optional, most slots have no order
+	OrderPatterns []string `yaml:"order_patterns"`
+	// LabDomains are the domains students pick from, in the order they are offered
+	LabDomains []LabDomainConfig `yaml:"lab_domains"`
+	// Domains are matched against the service name, the first match wins. Unmatched slots get the unknown domain
+	Domains []DomainRuleConfig `yaml:"domains"`
+	// DefenceKeywords in the master name mark defence slots, the rest are performance slots
+	DefenceKeywords []string `yaml:"defence_keywords"`
+}
+
+// LabDomainConfig is a lab domain. The key is stored with subscriptions and slots, so it must not change once in use
+type LabDomainConfig struct {
+	Key   string `yaml:"key"`
+	Label string `yaml:"label"`
+}
+
+type DomainRuleConfig struct {
+	Pattern string `yaml:"pattern"`
+	// Domain is the key of one of LabDomains, or unknown
+	Domain string `yaml:"domain"`
 }
 
 func (s *PollingConfig) GetFetchRate() time.Duration {
@@ -54,6 +111,21 @@ func (s *PollingConfig) SetFetchRate(rate time.Duration) {
 	s.NormalFetchRate = rate
 }
 
+type DikidiConfig struct {
+	BaseURL string `yaml:"base_url"`
+	// BookingURLTemplate supports {company_id} and {service_id} placeholders
+	BookingURLTemplate string          `yaml:"booking_url_template"`
+	Companies          []CompanyConfig `yaml:"companies"`
+	// RecordDir enables saving raw responses for offline replay, when set
+	RecordDir string `yaml:"record_dir"`
+}
+
+type CompanyConfig struct {
+	ID         int    `yaml:"id"`
+	Name       string `yaml:"name"`
+	ServiceURL string `yaml:"service_url"`
+}
+
 type PollingMode string
 
 const (
@@ -65,6 +137,41 @@ type NotificationConfig struct {
 	RedisPrefix      string        `yaml:"redis_prefix"`
 	CacheTTL         time.Duration `yaml:"cache_ttl"`
 	NotificationRate rate.Limit    `yaml:"notification_rate"`
+	NotifyTimesTaken bool          `yaml:"notify_times_taken"`
+	// ResendCooldown is how long a user isn't notified about the same times again, it must be positive
+	ResendCooldown time.Duration `yaml:"resend_cooldown"`
+	// Outbox delivery with retries: delays grow from RetryBaseDelay up to RetryMaxDelay,
+	// after MaxAttempts failures the notification is moved to dead letters
+	OutboxPollInterval time.Duration `yaml:"outbox_poll_interval"`
+	RetryBaseDelay     time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay      time.Duration `yaml:"retry_max_delay"`
+	MaxAttempts        int           `yaml:"max_attempts"`
+	// DefaultTimezone is used for quiet hours of users who didn't choose one
+	DefaultTimezone string `yaml:"default_timezone"`
+	// QuietUrgentWithin lets through notifications about slots starting that soon after quiet hours end
+	QuietUrgentWithin time.Duration `yaml:"quiet_urgent_within"`
+	// Cron specs of digests, evaluated in the user's time zone
+	HourlyDigestSpec string `yaml:"hourly_digest_spec"`
+	DailyDigestSpec  string `yaml:"daily_digest_spec"`
+}
+
+// AlertingConfig sets when the admin is told about upstream outages and parser drift
+type AlertingConfig struct {
+	// FetchFailureThreshold is the number of polls in a row without a single response before an outage alert
+	FetchFailureThreshold int `yaml:"fetch_failure_threshold"`
+	// ParseErrorRatio is the share of responses failing to parse that means the markup changed.
+	// Polls with fewer than MinResponses responses are too small to judge
+	ParseErrorRatio float64 `yaml:"parse_error_ratio"`
+	MinResponses    int     `yaml:"min_responses"`
+	// ServiceDropRatio and SlotDropRatio are how much the counts may shrink against the last normal poll.
+	// Counts below MinBaseline are too small to judge
+	ServiceDropRatio float64 `yaml:"service_drop_ratio"`
+	SlotDropRatio    float64 `yaml:"slot_drop_ratio"`
+	MinBaseline      int     `yaml:"min_baseline"`
+	// RepeatInterval is how often an alert is repeated while the problem lasts, zero disables repeats
+	RepeatInterval time.Duration `yaml:"repeat_interval"`
+	// MinInterval caps alerts of all kinds, so a flapping source doesn't flood the admin
+	MinInterval time.Duration `yaml:"min_interval"`
 }
 
 type TelegramConfig struct {
@@ -72,8 +179,47 @@ type TelegramConfig struct {
 	AdminID  int
 }
 
-type TeacherConfig struct {
-	StartingWeek int `yaml:"starting_week"`
+// CalendarConfig describes the academic semester. Dates are in the 2006-01-02 format, ranges are inclusive
+type CalendarConfig struct {
+	SemesterStart string `yaml:"semester_start"`
+	SemesterEnd   string `yaml:"semester_end"`
+	// StartingWeek is the week number (1 or 2) of the week the semester starts on
+	StartingWeek int               `yaml:"starting_week"`
+	Holidays     []DateRangeConfig `yaml:"holidays"`
+	Exams        []DateRangeConfig `yaml:"exams"`
+}
+
+// ScheduleConfig is the bell schedule. Lessons are numbered by their position, starting from 1
+type ScheduleConfig struct {
+	Lessons []LessonConfig `yaml:"lessons"`
+	// Weekdays override the regular lessons for a weekday, keyed by lowercase English names, e.g. saturday
+	Weekdays map[string][]LessonConfig `yaml:"weekdays"`
+	// Changes override the schedule for a range of dates, the range is open-ended without To
+	Changes []ScheduleChangeConfig `yaml:"changes"`
+}
+
+type LessonConfig struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+type ScheduleChangeConfig struct {
+	Name    string         `yaml:"name"`
+	From    string         `yaml:"from"`
+	To      string         `yaml:"to"`
+	Lessons []LessonConfig `yaml:"lessons"`
+}
+
+type DateRangeConfig struct {
+	Name string `yaml:"name"`
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// HistoryConfig sets how the slot history tells that a slot time is gone
+type HistoryConfig struct {
+	// StaleAfter is how long an open time may be missing from successful polls before it counts as disappeared
+	StaleAfter time.Duration `yaml:"stale_after"`
 }
 
 func Load(configPath string) (*Config, error) {
@@ -91,9 +237,22 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &cfg, nil
 }
 
+// validate rejects values that would silently break the bot instead of failing at startup
+func (c *Config) validate() error {
+	// Ledger keys are stored with the cooldown as TTL, a zero one never expires
+	if c.NotificationConfig.ResendCooldown <= 0 {
+		return fmt.Errorf("notification resend_cooldown must be positive")
+	}
+	return nil
+}
+
 func (c *Config) loadFromEnv() error {
 	if err := godotenv.Load(); err != nil {
 		return fmt.Errorf("error loading .env file: %w", err)
@@ -7,6 +7,8 @@ import (
 
 var ErrSubscriptionExists = errors.New("subscription already exists")
 
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
 var ErrBeginTransaction = errors.New("failed to begin transaction")
 
 type ErrQueryCreation struct {
@@ -10,6 +10,11 @@ const (
 	ServiceNotification = "notification"
 	ServiceSubscription = "subscription"
 	ServiceTeacher      = "teacher"
+	ServiceMigrations   = "migrations"
+	ServiceHealth       = "health"
+	ServiceAlerting     = "alerting"
+	ServiceHistory      = "history"
+	ServiceCatalog      = "catalog"
 	TelegramBot         = "bot"
 )
 